@@ -0,0 +1,316 @@
+// Package alert detects sharp price movements in the polled odds: a single
+// outcome moving more than a threshold within a window, a steam move where
+// several lines of one market move the same way at once, and prices that
+// stray from the consensus of the other operators.
+package alert
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"test_task_app/config"
+)
+
+// Alert types.
+const (
+	TypeMove      = "move"
+	TypeSteam     = "steam"
+	TypeConsensus = "consensus"
+)
+
+// Alert is one detected movement. ChangePercent is negative when the price
+// shortened, positive when it drifted.
+type Alert struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Key       string `json:"key"`
+	EventID   int    `json:"event_id"`
+	Operator  string `json:"operator,omitempty"`
+	MatchName string `json:"match_name"`
+	Sport     string `json:"sport"`
+	League    string `json:"league,omitempty"`
+	Market    string `json:"market"`
+	// Codes are the outcome codes that moved, one per line for steam moves.
+	Codes []string `json:"codes"`
+	// From and To are the prices of a move or consensus alert, steam alerts
+	// carry the prices of every line in Moves and the mean ChangePercent.
+	From          float64 `json:"from,omitempty"`
+	To            float64 `json:"to,omitempty"`
+	ChangePercent float64 `json:"change_percent"`
+	Consensus     float64 `json:"consensus,omitempty"`
+	Moves         []Move  `json:"moves,omitempty"`
+	WindowSeconds int64   `json:"window_seconds"`
+	Time          int64   `json:"time"`
+}
+
+// Move is the price move of one line of a steam move.
+type Move struct {
+	Code          string  `json:"code"`
+	From          float64 `json:"from"`
+	To            float64 `json:"to"`
+	ChangePercent float64 `json:"change_percent"`
+}
+
+// Event identifies the event an update belongs to, Key is its storage key.
+type Event struct {
+	Key       string
+	EventID   int
+	Operator  string
+	MatchName string
+	Sport     string
+	League    string
+}
+
+// Price is an open outcome of an update. Market is its market code, moves of
+// outcomes sharing market and selection on different lines are related.
+type Price struct {
+	Code      string
+	Market    string
+	Selection string
+	Odds      float64
+}
+
+type point struct {
+	at   time.Time
+	odds float64
+}
+
+// Engine keeps the recent prices of every outcome and checks each update
+// against them.
+type Engine struct {
+	movePercent      float64
+	window           time.Duration
+	steamLines       int
+	consensusPercent float64
+
+	mu      sync.Mutex
+	history map[string][]point
+	// quotes holds the latest price per event, outcome code and operator.
+	quotes map[int]map[string]map[string]point
+	fired  map[string]time.Time
+	now    func() time.Time
+}
+
+func NewEngine(cfg config.Alerts) *Engine {
+	return &Engine{
+		movePercent:      cfg.AlertMovePercent,
+		window:           cfg.AlertWindow,
+		steamLines:       cfg.AlertSteamLines,
+		consensusPercent: cfg.AlertConsensusPercent,
+		history:          make(map[string][]point),
+		quotes:           make(map[int]map[string]map[string]point),
+		fired:            make(map[string]time.Time),
+		now:              time.Now,
+	}
+}
+
+// Observe records the prices of an update and returns the alerts it raises.
+// An alert of one type is raised at most once per window for an outcome, or
+// for a market and selection in case of steam moves.
+func (e *Engine) Observe(event Event, prices []Price) []Alert {
+	now := e.now()
+	cutoff := now.Add(-e.window)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var alerts []Alert
+	type related struct {
+		market string
+		moves  []Move
+	}
+	moved := make(map[string]map[bool]*related)
+
+	for _, price := range prices {
+		if price.Odds <= 1 {
+			continue
+		}
+		key := event.Key + "\x00" + price.Code
+		points := append(trim(e.history[key], cutoff), point{at: now, odds: price.Odds})
+		e.history[key] = points
+
+		base := points[0].odds
+		change := (price.Odds - base) / base * 100
+		if math.Abs(change) >= e.movePercent {
+			if e.fire(TypeMove, key, now) {
+				alerts = append(alerts, e.newAlert(TypeMove, event, price.Market, []string{price.Code}, base, price.Odds, change, now))
+			}
+			group := price.Market + ":" + price.Selection
+			if moved[group] == nil {
+				moved[group] = make(map[bool]*related)
+			}
+			r := moved[group][change < 0]
+			if r == nil {
+				r = &related{market: price.Market}
+				moved[group][change < 0] = r
+			}
+			r.moves = append(r.moves, Move{Code: price.Code, From: base, To: price.Odds, ChangePercent: round(change)})
+		}
+
+		if e.consensusPercent > 0 {
+			if alert, ok := e.checkConsensus(event, price, now, cutoff); ok {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+
+	groups := make([]string, 0, len(moved))
+	for group := range moved {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		for _, shortening := range []bool{true, false} {
+			r := moved[group][shortening]
+			if r == nil || len(r.moves) < e.steamLines {
+				continue
+			}
+			if !e.fire(TypeSteam, event.Key+"\x00"+group+fmt.Sprint(shortening), now) {
+				continue
+			}
+			sort.Slice(r.moves, func(i, j int) bool { return r.moves[i].Code < r.moves[j].Code })
+			codes := make([]string, len(r.moves))
+			changes := make([]float64, len(r.moves))
+			for i, move := range r.moves {
+				codes[i], changes[i] = move.Code, move.ChangePercent
+			}
+			alert := e.newAlert(TypeSteam, event, r.market, codes, 0, 0, mean(changes), now)
+			alert.Moves = r.moves
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts
+}
+
+// Prune drops the prices and cooldowns older than the window.
+func (e *Engine) Prune() {
+	cutoff := e.now().Add(-e.window)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key, points := range e.history {
+		if points = trim(points, cutoff); len(points) == 0 {
+			delete(e.history, key)
+		} else {
+			e.history[key] = points
+		}
+	}
+	for eventID, codes := range e.quotes {
+		for code, operators := range codes {
+			for operator, q := range operators {
+				if q.at.Before(cutoff) {
+					delete(operators, operator)
+				}
+			}
+			if len(operators) == 0 {
+				delete(codes, code)
+			}
+		}
+		if len(codes) == 0 {
+			delete(e.quotes, eventID)
+		}
+	}
+	for key, at := range e.fired {
+		if at.Before(cutoff) {
+			delete(e.fired, key)
+		}
+	}
+}
+
+// checkConsensus compares price with the median of the other operators'
+// recent prices for the same outcome.
+func (e *Engine) checkConsensus(event Event, price Price, now, cutoff time.Time) (Alert, bool) {
+	codes, ok := e.quotes[event.EventID]
+	if !ok {
+		codes = make(map[string]map[string]point)
+		e.quotes[event.EventID] = codes
+	}
+	operators, ok := codes[price.Code]
+	if !ok {
+		operators = make(map[string]point)
+		codes[price.Code] = operators
+	}
+	operators[event.Operator] = point{at: now, odds: price.Odds}
+
+	var others []float64
+	for operator, q := range operators {
+		if operator != event.Operator && !q.at.Before(cutoff) {
+			others = append(others, q.odds)
+		}
+	}
+	if len(others) == 0 {
+		return Alert{}, false
+	}
+	consensus := median(others)
+	deviation := (price.Odds - consensus) / consensus * 100
+	if math.Abs(deviation) < e.consensusPercent || !e.fire(TypeConsensus, event.Key+"\x00"+price.Code, now) {
+		return Alert{}, false
+	}
+	alert := e.newAlert(TypeConsensus, event, price.Market, []string{price.Code}, consensus, price.Odds, deviation, now)
+	alert.Consensus = consensus
+	return alert, true
+}
+
+// fire reports whether an alert may be raised, starting its cooldown.
+func (e *Engine) fire(alertType, key string, now time.Time) bool {
+	key = alertType + "\x00" + key
+	if at, ok := e.fired[key]; ok && now.Sub(at) < e.window {
+		return false
+	}
+	e.fired[key] = now
+	return true
+}
+
+func (e *Engine) newAlert(alertType string, event Event, market string, codes []string, from, to, change float64, now time.Time) Alert {
+	return Alert{
+		ID:            fmt.Sprintf("%s-%s-%s-%d", alertType, event.Key, codes[0], now.UnixNano()),
+		Type:          alertType,
+		Key:           event.Key,
+		EventID:       event.EventID,
+		Operator:      event.Operator,
+		MatchName:     event.MatchName,
+		Sport:         event.Sport,
+		League:        event.League,
+		Market:        market,
+		Codes:         codes,
+		From:          from,
+		To:            to,
+		ChangePercent: round(change),
+		WindowSeconds: int64(e.window / time.Second),
+		Time:          now.Unix(),
+	}
+}
+
+// round rounds a percentage to two decimals.
+func round(percent float64) float64 {
+	return math.Round(percent*100) / 100
+}
+
+func trim(points []point, cutoff time.Time) []point {
+	i := 0
+	for i < len(points) && points[i].at.Before(cutoff) {
+		i++
+	}
+	return points[i:]
+}
+
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,275 @@
+package alert
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"test_task_app/config"
+)
+
+// clock is the time of a test engine, tests move it forward.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestEngine(consensusPercent float64) (*Engine, *clock) {
+	c := &clock{now: time.Unix(1760000000, 0)}
+	e := NewEngine(config.Alerts{
+		AlertMovePercent:      5,
+		AlertWindow:           5 * time.Minute,
+		AlertSteamLines:       3,
+		AlertConsensusPercent: consensusPercent,
+	})
+	e.now = func() time.Time { return c.now }
+	return e, c
+}
+
+var testEvent = Event{Key: "unibet-ubbe-1", EventID: 1, Operator: "ubbe", MatchName: "Arsenal - Chelsea", Sport: "FOOTBALL"}
+
+func winPrice(odds float64) Price {
+	return Price{Code: "WIN:GOALS:FT:MATCH:HOME", Market: "WIN:GOALS:FT:MATCH", Selection: "HOME", Odds: odds}
+}
+
+func totalPrices(over15, over25, over35 float64) []Price {
+	return []Price{
+		{Code: "TOTAL:GOALS:FT:MATCH:OVER@1.5", Market: "TOTAL:GOALS:FT:MATCH", Selection: "OVER", Odds: over15},
+		{Code: "TOTAL:GOALS:FT:MATCH:OVER@2.5", Market: "TOTAL:GOALS:FT:MATCH", Selection: "OVER", Odds: over25},
+		{Code: "TOTAL:GOALS:FT:MATCH:OVER@3.5", Market: "TOTAL:GOALS:FT:MATCH", Selection: "OVER", Odds: over35},
+	}
+}
+
+func alertTypes(alerts []Alert) []string {
+	var types []string
+	for _, alert := range alerts {
+		types = append(types, alert.Type)
+	}
+	return types
+}
+
+func TestMoveThreshold(t *testing.T) {
+	tests := []struct {
+		name   string
+		odds   float64
+		alert  bool
+		change float64
+	}{
+		{"below threshold", 2.09, false, 0},
+		{"shortened", 1.9, true, -5},
+		{"drifted", 2.1, true, 5},
+		{"drifted far", 3, true, 50},
+		{"no price", 1, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, c := newTestEngine(0)
+			if alerts := e.Observe(testEvent, []Price{winPrice(2)}); len(alerts) != 0 {
+				t.Fatalf("first price raised %v", alertTypes(alerts))
+			}
+			c.advance(time.Minute)
+			alerts := e.Observe(testEvent, []Price{winPrice(tt.odds)})
+			if !tt.alert {
+				if len(alerts) != 0 {
+					t.Errorf("raised %v, want none", alertTypes(alerts))
+				}
+				return
+			}
+			if len(alerts) != 1 {
+				t.Fatalf("raised %v, want one move", alertTypes(alerts))
+			}
+			got := alerts[0]
+			if got.Type != TypeMove || got.From != 2 || got.To != tt.odds || got.ChangePercent != tt.change {
+				t.Errorf("got %s %.2f -> %.2f (%.2f%%), want move 2.00 -> %.2f (%.2f%%)", got.Type, got.From, got.To, got.ChangePercent, tt.odds, tt.change)
+			}
+			if got.Key != testEvent.Key || got.Market != "WIN:GOALS:FT:MATCH" || !reflect.DeepEqual(got.Codes, []string{"WIN:GOALS:FT:MATCH:HOME"}) {
+				t.Errorf("got key %q, market %q, codes %v", got.Key, got.Market, got.Codes)
+			}
+			if got.WindowSeconds != 300 || got.Time != c.now.Unix() {
+				t.Errorf("got window %d, time %d", got.WindowSeconds, got.Time)
+			}
+		})
+	}
+}
+
+func TestMoveWithinWindow(t *testing.T) {
+	e, c := newTestEngine(0)
+	// Small steps add up to a move against the oldest price in the window.
+	for _, odds := range []float64{2, 2.04, 2.08} {
+		if alerts := e.Observe(testEvent, []Price{winPrice(odds)}); len(alerts) != 0 {
+			t.Fatalf("%.2f raised %v", odds, alertTypes(alerts))
+		}
+		c.advance(time.Minute)
+	}
+	alerts := e.Observe(testEvent, []Price{winPrice(2.12)})
+	if len(alerts) != 1 || alerts[0].From != 2 {
+		t.Fatalf("got %+v, want a move from 2.00", alerts)
+	}
+
+	// Prices older than the window no longer count.
+	e, c = newTestEngine(0)
+	e.Observe(testEvent, []Price{winPrice(2)})
+	c.advance(6 * time.Minute)
+	e.Observe(testEvent, []Price{winPrice(2.08)})
+	c.advance(time.Minute)
+	if alerts := e.Observe(testEvent, []Price{winPrice(2.12)}); len(alerts) != 0 {
+		t.Errorf("raised %v against a price outside the window", alertTypes(alerts))
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	e, c := newTestEngine(0)
+	e.Observe(testEvent, []Price{winPrice(2)})
+	c.advance(time.Minute)
+	if alerts := e.Observe(testEvent, []Price{winPrice(2.2)}); len(alerts) != 1 {
+		t.Fatalf("raised %v, want one move", alertTypes(alerts))
+	}
+
+	// The outcome keeps moving, within the window it is not raised again.
+	c.advance(time.Minute)
+	if alerts := e.Observe(testEvent, []Price{winPrice(2.5)}); len(alerts) != 0 {
+		t.Errorf("raised %v during the cooldown", alertTypes(alerts))
+	}
+	// Other outcomes have their own cooldown.
+	other := Event{Key: "unibet-ubbe-2", EventID: 2, Operator: "ubbe"}
+	e.Observe(other, []Price{winPrice(2)})
+	if alerts := e.Observe(other, []Price{winPrice(2.5)}); len(alerts) != 1 {
+		t.Errorf("raised %v for another event, want one move", alertTypes(alerts))
+	}
+
+	// A window after the alert it is raised again.
+	c.advance(5 * time.Minute)
+	if alerts := e.Observe(testEvent, []Price{winPrice(2.8)}); len(alerts) != 1 {
+		t.Errorf("raised %v after the cooldown, want one move", alertTypes(alerts))
+	}
+}
+
+func TestSteam(t *testing.T) {
+	e, c := newTestEngine(0)
+	e.Observe(testEvent, totalPrices(1.3, 1.9, 3.2))
+	c.advance(time.Minute)
+	alerts := e.Observe(testEvent, totalPrices(1.2, 1.7, 2.8))
+
+	var steam []Alert
+	for _, alert := range alerts {
+		if alert.Type == TypeSteam {
+			steam = append(steam, alert)
+		}
+	}
+	if len(alerts) != 4 || len(steam) != 1 {
+		t.Fatalf("raised %v, want three moves and a steam move", alertTypes(alerts))
+	}
+	got := steam[0]
+	want := []Move{
+		{Code: "TOTAL:GOALS:FT:MATCH:OVER@1.5", From: 1.3, To: 1.2, ChangePercent: -7.69},
+		{Code: "TOTAL:GOALS:FT:MATCH:OVER@2.5", From: 1.9, To: 1.7, ChangePercent: -10.53},
+		{Code: "TOTAL:GOALS:FT:MATCH:OVER@3.5", From: 3.2, To: 2.8, ChangePercent: -12.5},
+	}
+	if !reflect.DeepEqual(got.Moves, want) {
+		t.Errorf("got moves %+v, want %+v", got.Moves, want)
+	}
+	if got.Market != "TOTAL:GOALS:FT:MATCH" || len(got.Codes) != 3 || got.Codes[0] != want[0].Code {
+		t.Errorf("got market %q, codes %v", got.Market, got.Codes)
+	}
+	if got.From != 0 || got.To != 0 || got.ChangePercent != -10.24 {
+		t.Errorf("got %.2f -> %.2f (%.2f%%), want no prices and a -10.24%% mean change", got.From, got.To, got.ChangePercent)
+	}
+
+	// Lines moving different ways or too few lines are no steam move.
+	e, c = newTestEngine(0)
+	e.Observe(testEvent, totalPrices(1.3, 1.9, 3.2))
+	c.advance(time.Minute)
+	for _, alert := range e.Observe(testEvent, totalPrices(1.2, 2.1, 2.8)) {
+		if alert.Type == TypeSteam {
+			t.Errorf("raised steam move %v for lines moving apart", alert.Codes)
+		}
+	}
+}
+
+func TestSteamCooldown(t *testing.T) {
+	e, c := newTestEngine(0)
+	e.Observe(testEvent, totalPrices(1.3, 1.9, 3.2))
+	c.advance(time.Minute)
+	e.Observe(testEvent, totalPrices(1.2, 1.7, 2.8))
+	c.advance(time.Minute)
+	if types := alertTypes(e.Observe(testEvent, totalPrices(1.1, 1.5, 2.4))); len(types) != 0 {
+		t.Errorf("raised %v during the cooldown", types)
+	}
+	c.advance(5 * time.Minute)
+	var steam int
+	for _, alert := range e.Observe(testEvent, totalPrices(1.01, 1.3, 2)) {
+		if alert.Type == TypeSteam {
+			steam++
+		}
+	}
+	if steam != 1 {
+		t.Errorf("raised %d steam moves after the cooldown, want 1", steam)
+	}
+}
+
+func TestConsensus(t *testing.T) {
+	e, c := newTestEngine(10)
+	for i, operator := range []string{"ubse", "ubdk", "ubnl"} {
+		event := Event{Key: "unibet-" + operator + "-1", EventID: 1, Operator: operator}
+		if alerts := e.Observe(event, []Price{winPrice(1.7 + float64(i)/10)}); len(alerts) != 0 {
+			t.Fatalf("%s raised %v", operator, alertTypes(alerts))
+		}
+	}
+	c.advance(time.Minute)
+
+	alerts := e.Observe(testEvent, []Price{winPrice(2)})
+	if len(alerts) != 1 || alerts[0].Type != TypeConsensus {
+		t.Fatalf("raised %v, want a consensus alert", alertTypes(alerts))
+	}
+	got := alerts[0]
+	if got.Consensus != 1.8 || got.From != 1.8 || got.To != 2 || got.ChangePercent != 11.11 {
+		t.Errorf("got consensus %.2f, %.2f -> %.2f (%.2f%%)", got.Consensus, got.From, got.To, got.ChangePercent)
+	}
+
+	// Within the threshold of the median nothing is raised.
+	e, _ = newTestEngine(10)
+	for i, operator := range []string{"ubse", "ubdk", "ubnl", "ubfr"} {
+		e.Observe(Event{Key: "unibet-" + operator + "-1", EventID: 1, Operator: operator}, []Price{winPrice(1.7 + float64(i)/10)})
+	}
+	if alerts := e.Observe(testEvent, []Price{winPrice(2)}); len(alerts) != 0 {
+		t.Errorf("raised %v within 10%% of the median", alertTypes(alerts))
+	}
+
+	// Quotes older than the window are not part of the consensus.
+	e, c = newTestEngine(10)
+	e.Observe(Event{Key: "unibet-ubse-1", EventID: 1, Operator: "ubse"}, []Price{winPrice(1.5)})
+	c.advance(6 * time.Minute)
+	if alerts := e.Observe(testEvent, []Price{winPrice(2)}); len(alerts) != 0 {
+		t.Errorf("raised %v against a stale quote", alertTypes(alerts))
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   float64
+	}{
+		{[]float64{2}, 2},
+		{[]float64{3, 1, 2}, 2},
+		{[]float64{4, 1, 3, 2}, 2.5},
+	}
+	for _, tt := range tests {
+		if got := median(tt.values); got != tt.want {
+			t.Errorf("median(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	e, c := newTestEngine(10)
+	e.Observe(testEvent, []Price{winPrice(2)})
+	e.Observe(testEvent, []Price{winPrice(2.5)})
+	c.advance(6 * time.Minute)
+	e.Prune()
+	if len(e.history) != 0 || len(e.quotes) != 0 || len(e.fired) != 0 {
+		t.Errorf("kept %d histories, %d quotes and %d cooldowns", len(e.history), len(e.quotes), len(e.fired))
+	}
+}
@@ -0,0 +1,135 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RecentFileName is the file inside the data directory the latest alerts are
+// written to, for the view.
+const RecentFileName = "alerts.json"
+
+// Notifier delivers alerts to the webhook and keeps the latest ones, off the
+// polling path so a slow receiver never delays updates.
+type Notifier struct {
+	queue     chan []Alert
+	webhook   string
+	client    *http.Client
+	path      string
+	maxRecent int
+	log       *logrus.Logger
+
+	mu     sync.Mutex
+	recent []Alert
+}
+
+// NewNotifier posts alerts to webhook when it is set and keeps the latest
+// maxRecent alerts in dir.
+func NewNotifier(dir, webhook string, timeout time.Duration, maxRecent int, log *logrus.Logger) *Notifier {
+	return &Notifier{
+		queue:     make(chan []Alert, 100),
+		webhook:   webhook,
+		client:    &http.Client{Timeout: timeout},
+		path:      filepath.Join(dir, RecentFileName),
+		maxRecent: maxRecent,
+		log:       log,
+	}
+}
+
+// Notify queues alerts for delivery, dropping them when the queue is full.
+func (n *Notifier) Notify(alerts []Alert) {
+	if len(alerts) == 0 {
+		return
+	}
+	select {
+	case n.queue <- alerts:
+	default:
+		n.log.Errorf("error queueing %d alerts: queue full", len(alerts))
+	}
+}
+
+// Recent returns the latest alerts, newest first.
+func (n *Notifier) Recent() []Alert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	recent := make([]Alert, len(n.recent))
+	for i, alert := range n.recent {
+		recent[len(n.recent)-1-i] = alert
+	}
+	return recent
+}
+
+// Run delivers queued alerts until ctx is done.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alerts := <-n.queue:
+			n.keep(alerts)
+			if err := n.save(); err != nil {
+				n.log.Errorf("error saving alerts: %v", err)
+			}
+			if n.webhook != "" {
+				if err := n.post(ctx, alerts); err != nil {
+					n.log.Errorf("error posting %d alerts to webhook: %v", len(alerts), err)
+				}
+			}
+		}
+	}
+}
+
+func (n *Notifier) keep(alerts []Alert) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.recent = append(n.recent, alerts...)
+	if len(n.recent) > n.maxRecent {
+		n.recent = append([]Alert(nil), n.recent[len(n.recent)-n.maxRecent:]...)
+	}
+}
+
+func (n *Notifier) save() error {
+	data, err := json.MarshalIndent(n.Recent(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := n.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, n.path)
+}
+
+// post sends the alerts as a JSON array.
+func (n *Notifier) post(ctx context.Context, alerts []Alert) error {
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
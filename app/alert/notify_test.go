@@ -0,0 +1,96 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNotifier(t *testing.T) {
+	posts := make(chan []Alert, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alerts []Alert
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Error(err)
+		}
+		posts <- alerts
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	dir := t.TempDir()
+	n := NewNotifier(dir, server.URL, time.Second, 2, log)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	batches := [][]Alert{
+		{{ID: "a", Type: TypeMove}, {ID: "b", Type: TypeSteam, Moves: []Move{{Code: "TOTAL:GOALS:FT:MATCH:OVER@2.5", From: 1.9, To: 1.7}}}},
+		{{ID: "c", Type: TypeConsensus}},
+	}
+	for _, batch := range batches {
+		n.Notify(batch)
+		select {
+		case got := <-posts:
+			if len(got) != len(batch) || got[0].ID != batch[0].ID {
+				t.Errorf("posted %+v, want %+v", got, batch)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no alerts posted")
+		}
+	}
+
+	// The two latest alerts are kept, newest first.
+	if got := ids(n.Recent()); got != "c,b" {
+		t.Errorf("recent alerts %s, want c,b", got)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, RecentFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved []Alert
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(saved); got != "c,b" {
+		t.Errorf("saved alerts %s, want c,b", got)
+	}
+	if len(saved[1].Moves) != 1 {
+		t.Errorf("saved steam alert lost its moves: %+v", saved[1])
+	}
+}
+
+func TestNotifierPostError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	n := NewNotifier(t.TempDir(), server.URL, time.Second, 10, logrus.New())
+	if err := n.post(context.Background(), []Alert{{ID: "a"}}); err == nil || err.Error() != "HTTP 500" {
+		t.Errorf("post() error = %v, want HTTP 500", err)
+	}
+}
+
+func ids(alerts []Alert) string {
+	var s string
+	for i, alert := range alerts {
+		if i > 0 {
+			s += ","
+		}
+		s += alert.ID
+	}
+	return s
+}
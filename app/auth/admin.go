@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// IssueRequest is the body of POST /admin/keys and POST /admin/tokens. TTL is
+// a duration such as 720h, keys without one do not expire.
+type IssueRequest struct {
+	Name string `json:"name"`
+	TTL  string `json:"ttl"`
+	Entitlements
+}
+
+// IssueResponse carries the new key or token, shown only this once.
+type IssueResponse struct {
+	Key       *Key   `json:"key,omitempty"`
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+// AdminHandler serves the admin API:
+//
+//	GET    /admin/keys       list the keys, revoked ones included
+//	POST   /admin/keys       issue a key
+//	DELETE /admin/keys/{id}  revoke a key and drop its connections
+//	POST   /admin/tokens     sign a JWT, when a JWT secret is configured
+func (a *Authenticator) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.keys.List())
+	})
+	mux.HandleFunc("POST /admin/keys", func(w http.ResponseWriter, r *http.Request) {
+		req, ttl, ok := readIssueRequest(w, r)
+		if !ok {
+			return
+		}
+		key, token, err := a.keys.Issue(req.Name, req.Entitlements, ttl)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, IssueResponse{Key: &key, Token: token, ExpiresAt: key.ExpiresAt})
+	})
+	mux.HandleFunc("DELETE /admin/keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		err := a.revoke(r.PathValue("id"))
+		switch {
+		case errors.Is(err, ErrKeyNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	mux.HandleFunc("POST /admin/tokens", func(w http.ResponseWriter, r *http.Request) {
+		if len(a.jwtSecret) == 0 {
+			http.Error(w, "no JWT secret configured", http.StatusNotImplemented)
+			return
+		}
+		req, ttl, ok := readIssueRequest(w, r)
+		if !ok {
+			return
+		}
+		if ttl <= 0 {
+			http.Error(w, "ttl is required for tokens", http.StatusBadRequest)
+			return
+		}
+		now := a.now()
+		claims := Claims{Subject: req.Name, IssuedAt: now.Unix(), ExpiresAt: now.Add(ttl).Unix(), Entitlements: req.Entitlements}
+		token, err := SignJWT(claims, a.jwtSecret)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, IssueResponse{Token: token, ExpiresAt: claims.ExpiresAt})
+	})
+	return a.AdminOnly(mux)
+}
+
+func readIssueRequest(w http.ResponseWriter, r *http.Request) (IssueRequest, time.Duration, bool) {
+	var req IssueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return req, 0, false
+	}
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return req, 0, false
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl < 0 {
+			http.Error(w, "invalid ttl", http.StatusBadRequest)
+			return req, 0, false
+		}
+	}
+	return req, ttl, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
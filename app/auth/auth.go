@@ -0,0 +1,308 @@
+// Package auth authenticates the clients of /ws and the REST endpoints with
+// API keys or JWTs, and enforces their entitlements and limits: the sports and
+// leagues they receive, their concurrent connections and message rate.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"test_task_app/config"
+)
+
+var (
+	ErrNoCredentials = errors.New("API key or token required")
+	ErrTooManyConns  = errors.New("too many connections")
+)
+
+// Principal is an authenticated client. ID is unique per key or token
+// subject and is what the limits are counted against.
+type Principal struct {
+	ID   string
+	Name string
+	Entitlements
+}
+
+// Allows reports whether the principal may receive events of the sport and
+// league.
+func (p Principal) Allows(sport, league string) bool {
+	return anyFold(p.Sports, sport) && anyFold(p.Leagues, league)
+}
+
+// Restricted reports whether the principal is limited to some sports or
+// leagues.
+func (p Principal) Restricted() bool {
+	return len(p.Sports) > 0 || len(p.Leagues) > 0
+}
+
+// anonymous is the principal of every request when auth is disabled.
+var anonymous = Principal{ID: "anonymous", Name: "anonymous"}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Authenticator checks credentials and keeps the connection counts and rate
+// buckets of every principal.
+type Authenticator struct {
+	enabled    bool
+	keys       *KeyStore
+	jwtSecret  []byte
+	adminToken string
+	origins    map[string]struct{}
+	defaults   Entitlements
+
+	mu       sync.Mutex
+	conns    map[string]int
+	buckets  map[string]*bucket
+	onRevoke []func(id string)
+	now      func() time.Time
+}
+
+func New(cfg config.Auth, keys *KeyStore) *Authenticator {
+	a := &Authenticator{
+		enabled:    cfg.AuthEnabled,
+		keys:       keys,
+		jwtSecret:  []byte(cfg.AuthJWTSecret),
+		adminToken: cfg.AuthAdminToken,
+		origins:    make(map[string]struct{}),
+		defaults: Entitlements{
+			MaxConnections:    cfg.AuthMaxConnections,
+			MessagesPerMinute: cfg.AuthMessagesPerMinute,
+		},
+		conns:   make(map[string]int),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	for _, origin := range cfg.AuthAllowedOrigins {
+		a.origins[strings.ToLower(strings.TrimRight(origin, "/"))] = struct{}{}
+	}
+	return a
+}
+
+// Authenticate returns the principal of a request. Credentials are read from
+// the X-API-Key header, a Bearer Authorization header, or the api_key and
+// access_token query parameters for browsers opening a websocket.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if !a.enabled {
+		return anonymous, nil
+	}
+
+	token := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token == "" {
+		token = strings.TrimSpace(bearer)
+	}
+	if token == "" {
+		token = r.URL.Query().Get("api_key")
+	}
+	if token == "" {
+		token = r.URL.Query().Get("access_token")
+	}
+	return a.AuthenticateToken(token)
+}
+
+// AuthenticateToken returns the principal of an API key or JWT, for
+// transports that carry credentials outside HTTP headers and queries.
+func (a *Authenticator) AuthenticateToken(token string) (Principal, error) {
+	if !a.enabled {
+		return anonymous, nil
+	}
+	if token == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	if strings.HasPrefix(token, keyPrefix) {
+		key, err := a.keys.Verify(token)
+		if err != nil {
+			return Principal{}, err
+		}
+		return a.principal(keyPrincipalID(key.ID), key.Name, key.Entitlements), nil
+	}
+	if len(a.jwtSecret) == 0 {
+		return Principal{}, ErrTokenInvalid
+	}
+	claims, err := ParseJWT(token, a.jwtSecret, a.now())
+	if err != nil {
+		return Principal{}, err
+	}
+	return a.principal("jwt:"+claims.Subject, claims.Subject, claims.Entitlements), nil
+}
+
+func (a *Authenticator) principal(id, name string, e Entitlements) Principal {
+	if e.MaxConnections <= 0 {
+		e.MaxConnections = a.defaults.MaxConnections
+	}
+	if e.MessagesPerMinute <= 0 {
+		e.MessagesPerMinute = a.defaults.MessagesPerMinute
+	}
+	return Principal{ID: id, Name: name, Entitlements: e}
+}
+
+// keyPrincipalID is the principal ID of the API key with the given ID.
+func keyPrincipalID(id string) string {
+	return "key:" + id
+}
+
+// CheckOrigin reports whether a browser may open a websocket from the origin
+// of the request: one of the allowed origins, or the server itself when none
+// are configured. Requests without an Origin header do not come from a
+// browser and are allowed.
+func (a *Authenticator) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if len(a.origins) == 0 {
+		return strings.EqualFold(u.Host, r.Host)
+	}
+	_, ok := a.origins[strings.ToLower(u.Scheme+"://"+u.Host)]
+	return ok
+}
+
+// OnRevoke registers fn to be called with the principal ID of every key
+// revoked through the admin API, so its open connections can be dropped.
+func (a *Authenticator) OnRevoke(fn func(id string)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.onRevoke = append(a.onRevoke, fn)
+}
+
+// revoke revokes a key and notifies the OnRevoke listeners.
+func (a *Authenticator) revoke(id string) error {
+	if err := a.keys.Revoke(id); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	listeners := append([]func(string){}, a.onRevoke...)
+	a.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(keyPrincipalID(id))
+	}
+	return nil
+}
+
+// Acquire counts a connection of the principal, returning a release func, or
+// ErrTooManyConns when it already holds as many as it may.
+func (a *Authenticator) Acquire(p Principal) (func(), error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if p.MaxConnections > 0 && a.conns[p.ID] >= p.MaxConnections {
+		return nil, ErrTooManyConns
+	}
+	a.conns[p.ID]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if a.conns[p.ID]--; a.conns[p.ID] <= 0 {
+				delete(a.conns, p.ID)
+			}
+		})
+	}, nil
+}
+
+// Allow takes one message from the principal's rate bucket, which holds a
+// minute worth of messages and refills continuously.
+func (a *Authenticator) Allow(p Principal) bool {
+	if p.MessagesPerMinute <= 0 {
+		return true
+	}
+	now := a.now()
+	limit := float64(p.MessagesPerMinute)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	b, ok := a.buckets[p.ID]
+	if !ok {
+		b = &bucket{tokens: limit, updated: now}
+		a.buckets[p.ID] = b
+	}
+	b.tokens += now.Sub(b.updated).Minutes() * limit
+	if b.tokens > limit {
+		b.tokens = limit
+	}
+	b.updated = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying the principal.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal Middleware stored in the request context.
+func FromContext(ctx context.Context) Principal {
+	if p, ok := ctx.Value(principalKey{}).(Principal); ok {
+		return p
+	}
+	return anonymous
+}
+
+// Middleware authenticates every request and counts it against the rate
+// limit of its principal.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if a.enabled && !a.Allow(p) {
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+	})
+}
+
+// AdminOnly requires the admin token as a Bearer Authorization header. The
+// handler is not served at all without an admin token.
+func (a *Authenticator) AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) != 1 {
+			http.Error(w, "admin token required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func anyFold(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
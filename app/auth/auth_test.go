@@ -0,0 +1,446 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"test_task_app/config"
+)
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+// resign replaces the header of a token and signs it again, so only the
+// header can make it invalid.
+func resign(token, header string) string {
+	parts := strings.Split(token, ".")
+	signed := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + parts[1]
+	return signed + "." + signature(signed, secret)
+}
+
+func TestParseJWT(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	sign := func(claims Claims) string {
+		token, err := SignJWT(claims, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := sign(Claims{Subject: "acme", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix(), Entitlements: Entitlements{Sports: []string{"FOOTBALL"}}})
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", valid, nil},
+		{"malformed", "not-a-jwt", ErrTokenInvalid},
+		{"other secret", strings.Join(parts[:2], ".") + "." + signature(parts[0]+"."+parts[1], []byte("other")), ErrTokenInvalid},
+		{"tampered payload", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`)) + "." + parts[2], ErrTokenInvalid},
+		{"alg none", resign(valid, `{"alg":"none","typ":"JWT"}`), ErrTokenInvalid},
+		{"alg HS512", resign(valid, `{"alg":"HS512","typ":"JWT"}`), ErrTokenInvalid},
+		{"no subject", sign(Claims{ExpiresAt: now.Add(time.Hour).Unix()}), ErrTokenInvalid},
+		{"no expiry", sign(Claims{Subject: "acme"}), ErrTokenInvalid},
+		{"expired", sign(Claims{Subject: "acme", ExpiresAt: now.Unix()}), ErrTokenExpired},
+		{"not yet valid", sign(Claims{Subject: "acme", NotBefore: now.Add(time.Minute).Unix(), ExpiresAt: now.Add(time.Hour).Unix()}), ErrTokenExpired},
+		{"valid from now", sign(Claims{Subject: "acme", NotBefore: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}), nil},
+	}
+	for _, tt := range tests {
+		claims, err := ParseJWT(tt.token, secret, now)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: ParseJWT() error %v, want %v", tt.name, err, tt.err)
+		}
+		if err == nil && claims.Subject != "acme" {
+			t.Errorf("%s: subject %q", tt.name, claims.Subject)
+		}
+	}
+
+	claims, _ := ParseJWT(valid, secret, now)
+	if !reflect.DeepEqual(claims.Sports, []string{"FOOTBALL"}) {
+		t.Errorf("entitlements %+v", claims.Entitlements)
+	}
+}
+
+func TestKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), KeysFileName)
+	now := time.Unix(1760000000, 0)
+	store, err := NewKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.now = func() time.Time { return now }
+
+	entitlements := Entitlements{Leagues: []string{"Premier League"}, MaxConnections: 2}
+	key, token, err := store.Issue("acme", entitlements, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Hash != "" || !strings.HasPrefix(token, keyPrefix+key.ID+"_") || key.ExpiresAt != 0 {
+		t.Errorf("Issue() = %+v, %q", key, token)
+	}
+	expiring, expiringToken, err := store.Issue("trial", Entitlements{}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expiring.ExpiresAt != now.Add(time.Hour).Unix() {
+		t.Errorf("expires at %d", expiring.ExpiresAt)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", token, nil},
+		{"valid until expiry", expiringToken, nil},
+		{"other secret", keyPrefix + key.ID + "_" + strings.Repeat("0", 32), ErrKeyNotFound},
+		{"unknown ID", keyPrefix + "ffffffff_" + strings.Repeat("0", 32), ErrKeyNotFound},
+		{"no prefix", strings.TrimPrefix(token, keyPrefix), ErrKeyNotFound},
+		{"no secret", keyPrefix + key.ID, ErrKeyNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := store.Verify(tt.token); !errors.Is(err, tt.err) {
+			t.Errorf("%s: Verify() error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	if got, _ := store.Verify(token); got.Name != "acme" || !reflect.DeepEqual(got.Entitlements, entitlements) {
+		t.Errorf("Verify() = %+v", got)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := store.Verify(expiringToken); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Verify() of an expired key: %v", err)
+	}
+	if err := store.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Verify(token); !errors.Is(err, ErrKeyRevoked) {
+		t.Errorf("Verify() of a revoked key: %v", err)
+	}
+	if err := store.Revoke("ffffffff"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Revoke() of an unknown key: %v", err)
+	}
+
+	// The keys are kept across restarts, with their revocation.
+	reloaded, err := NewKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := reloaded.List()
+	if len(keys) != 2 || keys[0].ID != key.ID || keys[0].RevokedAt != now.Unix() || keys[1].ID != expiring.ID {
+		t.Fatalf("List() after reload = %+v", keys)
+	}
+	for _, k := range keys {
+		if k.Hash != "" {
+			t.Errorf("List() exposes the hash of %s", k.ID)
+		}
+	}
+	if _, err := reloaded.Verify(token); !errors.Is(err, ErrKeyRevoked) {
+		t.Errorf("Verify() after reload: %v", err)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	a := New(config.Auth{}, nil)
+	now := time.Unix(1760000000, 0)
+	a.now = func() time.Time { return now }
+	p := Principal{ID: "key:1", Entitlements: Entitlements{MessagesPerMinute: 3}}
+
+	steps := []struct {
+		advance time.Duration
+		allowed int
+	}{
+		{0, 3},                // a full bucket
+		{10 * time.Second, 0}, // half a message refilled
+		{10 * time.Second, 1}, // one message refilled
+		{time.Hour, 3},        // never more than a minute worth
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		allowed := 0
+		for j := 0; j < 5; j++ {
+			if a.Allow(p) {
+				allowed++
+			}
+		}
+		if allowed != step.allowed {
+			t.Errorf("step %d: allowed %d messages, want %d", i, allowed, step.allowed)
+		}
+	}
+
+	// Buckets are per principal, and no limit allows everything.
+	if !a.Allow(Principal{ID: "key:2", Entitlements: Entitlements{MessagesPerMinute: 1}}) {
+		t.Error("another principal shares the bucket")
+	}
+	for i := 0; i < 100; i++ {
+		if !a.Allow(Principal{ID: "key:3"}) {
+			t.Fatal("unlimited principal was limited")
+		}
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	a := New(config.Auth{}, nil)
+	p := Principal{ID: "key:1", Entitlements: Entitlements{MaxConnections: 2}}
+
+	first, err := a.Acquire(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Acquire(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Acquire(p); !errors.Is(err, ErrTooManyConns) {
+		t.Errorf("third connection: %v, want %v", err, ErrTooManyConns)
+	}
+	if _, err := a.Acquire(Principal{ID: "key:2", Entitlements: Entitlements{MaxConnections: 1}}); err != nil {
+		t.Errorf("another principal: %v", err)
+	}
+
+	// Releasing twice frees a single connection.
+	first()
+	first()
+	if _, err := a.Acquire(p); err != nil {
+		t.Errorf("after release: %v", err)
+	}
+	if _, err := a.Acquire(p); !errors.Is(err, ErrTooManyConns) {
+		t.Errorf("after a double release: %v, want %v", err, ErrTooManyConns)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"no origin", nil, "", true},
+		{"same origin", nil, "http://odds.example.com:6003", true},
+		{"same origin in another case", nil, "http://ODDS.example.com:6003", true},
+		{"other origin", nil, "http://evil.example.com", false},
+		{"other port", nil, "http://odds.example.com", false},
+		{"allowed", []string{"https://app.example.com/"}, "https://app.example.com", true},
+		{"allowed in another case", []string{"https://App.Example.com"}, "https://app.example.com", true},
+		{"allowed on another scheme", []string{"https://app.example.com"}, "http://app.example.com", false},
+		{"same origin not allowed", []string{"https://app.example.com"}, "http://odds.example.com:6003", false},
+		{"invalid", nil, "http://%zz", false},
+	}
+	for _, tt := range tests {
+		a := New(config.Auth{AuthAllowedOrigins: tt.allowed}, nil)
+		r := httptest.NewRequest(http.MethodGet, "http://odds.example.com:6003/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := a.CheckOrigin(r); got != tt.want {
+			t.Errorf("%s: CheckOrigin() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPrincipalAllows(t *testing.T) {
+	tests := []struct {
+		name         string
+		entitlements Entitlements
+		sport        string
+		league       string
+		want         bool
+	}{
+		{"unrestricted", Entitlements{}, "FOOTBALL", "Premier League", true},
+		{"sport", Entitlements{Sports: []string{"football"}}, "FOOTBALL", "Premier League", true},
+		{"other sport", Entitlements{Sports: []string{"TENNIS"}}, "FOOTBALL", "Premier League", false},
+		{"league", Entitlements{Leagues: []string{"premier league", "La Liga"}}, "FOOTBALL", "Premier League", true},
+		{"other league", Entitlements{Leagues: []string{"La Liga"}}, "FOOTBALL", "Premier League", false},
+		{"sport and league", Entitlements{Sports: []string{"FOOTBALL"}, Leagues: []string{"La Liga"}}, "FOOTBALL", "Premier League", false},
+	}
+	for _, tt := range tests {
+		p := Principal{ID: "key:1", Entitlements: tt.entitlements}
+		if got := p.Allows(tt.sport, tt.league); got != tt.want {
+			t.Errorf("%s: Allows() = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := p.Restricted(); got != (len(tt.entitlements.Sports)+len(tt.entitlements.Leagues) > 0) {
+			t.Errorf("%s: Restricted() = %v", tt.name, got)
+		}
+	}
+}
+
+func newTestAuthenticator(t *testing.T, cfg config.Auth) *Authenticator {
+	t.Helper()
+
+	keys, err := NewKeyStore(filepath.Join(t.TempDir(), KeysFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(cfg, keys)
+}
+
+func TestMiddleware(t *testing.T) {
+	a := newTestAuthenticator(t, config.Auth{AuthEnabled: true, AuthJWTSecret: string(secret), AuthMaxConnections: 5, AuthMessagesPerMinute: 2})
+	now := time.Unix(1760000000, 0)
+	a.now = func() time.Time { return now }
+	_, key, err := a.keys.Issue("acme", Entitlements{MessagesPerMinute: 100}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := SignJWT(Claims{Subject: "partner", ExpiresAt: now.Add(time.Hour).Unix()}, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(FromContext(r.Context()).Name))
+	})
+	handler := a.Middleware(names)
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		code   int
+		body   string
+	}{
+		{"API key header", "/events", http.Header{"X-Api-Key": {key}}, http.StatusOK, "acme"},
+		{"bearer key", "/events", http.Header{"Authorization": {"Bearer " + key}}, http.StatusOK, "acme"},
+		{"key in query", "/events?api_key=" + key, nil, http.StatusOK, "acme"},
+		{"bearer JWT", "/events", http.Header{"Authorization": {"Bearer " + token}}, http.StatusOK, "partner"},
+		{"JWT in query", "/events?access_token=" + token, nil, http.StatusOK, "partner"},
+		{"no credentials", "/events", nil, http.StatusUnauthorized, ErrNoCredentials.Error()},
+		{"unknown key", "/events", http.Header{"X-Api-Key": {keyPrefix + "ffffffff_00"}}, http.StatusUnauthorized, ErrKeyNotFound.Error()},
+		{"invalid JWT", "/events?access_token=a.b.c", nil, http.StatusUnauthorized, ErrTokenInvalid.Error()},
+		// The JWT falls back to the default of 2 messages a minute.
+		{"rate limited", "/events?access_token=" + token, nil, http.StatusTooManyRequests, "rate limit exceeded"},
+	}
+	for _, tt := range tests {
+		w := get(tt.target, tt.header)
+		if w.Code != tt.code || strings.TrimSpace(w.Body.String()) != tt.body {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+
+	// Without auth every request is anonymous and unlimited.
+	handler = newTestAuthenticator(t, config.Auth{AuthMessagesPerMinute: 1}).Middleware(names)
+	for i := 0; i < 3; i++ {
+		if w := get("/events", nil); w.Code != http.StatusOK || w.Body.String() != "anonymous" {
+			t.Errorf("auth disabled: %d %q", w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	const adminToken = "admin-token-0123456789"
+	a := newTestAuthenticator(t, config.Auth{AuthEnabled: true, AuthAdminToken: adminToken, AuthJWTSecret: string(secret)})
+	now := time.Unix(1760000000, 0)
+	a.now = func() time.Time { return now }
+	a.keys.now = a.now
+	var revoked []string
+	a.OnRevoke(func(id string) { revoked = append(revoked, id) })
+	handler := a.AdminHandler()
+
+	do := func(method, target, body, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do(http.MethodPost, "/admin/keys", `{"name":"acme","ttl":"720h","sports":["FOOTBALL"]}`, adminToken)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("issue key: %d %s", w.Code, w.Body)
+	}
+	var issued IssueResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &issued); err != nil {
+		t.Fatal(err)
+	}
+	if issued.Key == nil || issued.Key.Name != "acme" || issued.Key.Hash != "" || issued.ExpiresAt == 0 {
+		t.Errorf("issued %+v", issued)
+	}
+	p, err := a.AuthenticateToken(issued.Token)
+	if err != nil || p.ID != keyPrincipalID(issued.Key.ID) || !reflect.DeepEqual(p.Sports, []string{"FOOTBALL"}) {
+		t.Errorf("issued key authenticates as %+v, %v", p, err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		token  string
+		code   int
+	}{
+		{"no admin token", http.MethodGet, "/admin/keys", "", "", http.StatusUnauthorized},
+		{"wrong admin token", http.MethodGet, "/admin/keys", "", "admin-token-wrong", http.StatusUnauthorized},
+		{"API key as admin token", http.MethodGet, "/admin/keys", "", issued.Token, http.StatusUnauthorized},
+		{"list", http.MethodGet, "/admin/keys", "", adminToken, http.StatusOK},
+		{"invalid body", http.MethodPost, "/admin/keys", `{`, adminToken, http.StatusBadRequest},
+		{"no name", http.MethodPost, "/admin/keys", `{}`, adminToken, http.StatusBadRequest},
+		{"invalid ttl", http.MethodPost, "/admin/keys", `{"name":"acme","ttl":"soon"}`, adminToken, http.StatusBadRequest},
+		{"negative ttl", http.MethodPost, "/admin/keys", `{"name":"acme","ttl":"-1h"}`, adminToken, http.StatusBadRequest},
+		{"revoke unknown", http.MethodDelete, "/admin/keys/ffffffff", "", adminToken, http.StatusNotFound},
+		{"token without ttl", http.MethodPost, "/admin/tokens", `{"name":"partner"}`, adminToken, http.StatusBadRequest},
+		{"token", http.MethodPost, "/admin/tokens", `{"name":"partner","ttl":"1h"}`, adminToken, http.StatusCreated},
+		{"revoke", http.MethodDelete, "/admin/keys/" + issued.Key.ID, "", adminToken, http.StatusNoContent},
+		{"revoke again", http.MethodDelete, "/admin/keys/" + issued.Key.ID, "", adminToken, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		if w := do(tt.method, tt.target, tt.body, tt.token); w.Code != tt.code {
+			t.Errorf("%s: %d %s, want %d", tt.name, w.Code, w.Body, tt.code)
+		}
+	}
+
+	if _, err := a.AuthenticateToken(issued.Token); !errors.Is(err, ErrKeyRevoked) {
+		t.Errorf("revoked key authenticates: %v", err)
+	}
+	if want := []string{keyPrincipalID(issued.Key.ID), keyPrincipalID(issued.Key.ID)}; !reflect.DeepEqual(revoked, want) {
+		t.Errorf("revoke listeners called with %v, want %v", revoked, want)
+	}
+
+	w = do(http.MethodGet, "/admin/keys", "", adminToken)
+	var keys []Key
+	if err := json.Unmarshal(w.Body.Bytes(), &keys); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].RevokedAt != now.Unix() || keys[0].Hash != "" {
+		t.Errorf("listed %+v", keys)
+	}
+
+	w = do(http.MethodPost, "/admin/tokens", `{"name":"partner","ttl":"1h","leagues":["La Liga"]}`, adminToken)
+	if err := json.Unmarshal(w.Body.Bytes(), &issued); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseJWT(issued.Token, secret, now)
+	if err != nil || claims.Subject != "partner" || claims.ExpiresAt != now.Add(time.Hour).Unix() || !reflect.DeepEqual(claims.Leagues, []string{"La Liga"}) {
+		t.Errorf("signed token claims %+v, %v", claims, err)
+	}
+
+	// Without a JWT secret no tokens are signed, without an admin token the
+	// API is not served at all.
+	handler = newTestAuthenticator(t, config.Auth{AuthAdminToken: adminToken}).AdminHandler()
+	if w := do(http.MethodPost, "/admin/tokens", `{"name":"partner","ttl":"1h"}`, adminToken); w.Code != http.StatusNotImplemented {
+		t.Errorf("token without a JWT secret: %d", w.Code)
+	}
+	handler = newTestAuthenticator(t, config.Auth{}).AdminHandler()
+	if w := do(http.MethodGet, "/admin/keys", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("admin API without an admin token: %d", w.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenInvalid = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// Claims of the JWTs accepted by the API. Tokens are signed with HS256 and
+// must expire, the entitlements are optional.
+type Claims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+	Entitlements
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// SignJWT returns the HS256 JWT of claims.
+func SignJWT(claims Claims, secret []byte) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + signature(signed, secret), nil
+}
+
+// ParseJWT verifies an HS256 JWT and returns its claims.
+func ParseJWT(token string, secret []byte, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrTokenInvalid
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Claims{}, ErrTokenInvalid
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &h); err != nil || h.Alg != "HS256" {
+		return Claims{}, ErrTokenInvalid
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(signature(parts[0]+"."+parts[1], secret))) {
+		return Claims{}, ErrTokenInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, ErrTokenInvalid
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" || claims.ExpiresAt == 0 {
+		return Claims{}, ErrTokenInvalid
+	}
+	if now.Unix() >= claims.ExpiresAt || now.Unix() < claims.NotBefore {
+		return Claims{}, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+func signature(signed string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
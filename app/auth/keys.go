@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeysFileName is the file inside the data directory the API keys are kept
+// in when auth_keys_file is not set.
+const KeysFileName = "api_keys.json"
+
+// keyPrefix starts every API key, followed by the key ID, an underscore and
+// the secret part.
+const keyPrefix = "odk_"
+
+var (
+	ErrKeyNotFound = errors.New("API key not found")
+	ErrKeyRevoked  = errors.New("API key revoked")
+	ErrKeyExpired  = errors.New("API key expired")
+)
+
+// Key is an issued API key. Only the SHA-256 of the secret is stored, the key
+// itself is shown once when it is issued.
+type Key struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Hash string `json:"hash,omitempty"`
+	Entitlements
+	CreatedAt int64 `json:"created_at"`
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	RevokedAt int64 `json:"revoked_at,omitempty"`
+}
+
+// Entitlements restrict what a client receives and how much of it. Empty
+// sport and league lists allow everything, zero limits fall back to the
+// configured defaults.
+type Entitlements struct {
+	Sports            []string `json:"sports,omitempty"`
+	Leagues           []string `json:"leagues,omitempty"`
+	MaxConnections    int      `json:"max_connections,omitempty"`
+	MessagesPerMinute int      `json:"messages_per_minute,omitempty"`
+}
+
+// KeyStore keeps the API keys in a JSON file, written on every change.
+type KeyStore struct {
+	path string
+	mu   sync.Mutex
+	keys map[string]*Key
+	now  func() time.Time
+}
+
+// NewKeyStore loads the keys kept at path, which need not exist yet.
+func NewKeyStore(path string) (*KeyStore, error) {
+	s := &KeyStore{path: path, keys: make(map[string]*Key), now: time.Now}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+	return s, nil
+}
+
+// Issue creates a key and returns it, without its hash, with its secret: the
+// only time the secret is available. ttl 0 issues a key that does not expire.
+func (s *KeyStore) Issue(name string, entitlements Entitlements, ttl time.Duration) (Key, string, error) {
+	secret, err := randomHex(16)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var id string
+	for id == "" || s.keys[id] != nil {
+		if id, err = randomHex(4); err != nil {
+			return Key{}, "", err
+		}
+	}
+	token := keyPrefix + id + "_" + secret
+
+	now := s.now()
+	k := &Key{
+		ID:           id,
+		Name:         name,
+		Hash:         hash(token),
+		Entitlements: entitlements,
+		CreatedAt:    now.Unix(),
+	}
+	if ttl > 0 {
+		k.ExpiresAt = now.Add(ttl).Unix()
+	}
+	s.keys[id] = k
+	if err := s.save(); err != nil {
+		delete(s.keys, id)
+		return Key{}, "", fmt.Errorf("error saving API keys: %w", err)
+	}
+	issued := *k
+	issued.Hash = ""
+	return issued, token, nil
+}
+
+// Revoke disables a key for good, connections using it are not cut.
+func (s *KeyStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if k.RevokedAt != 0 {
+		return nil
+	}
+	k.RevokedAt = s.now().Unix()
+	if err := s.save(); err != nil {
+		k.RevokedAt = 0
+		return fmt.Errorf("error saving API keys: %w", err)
+	}
+	return nil
+}
+
+// List returns every key without its hash, revoked ones included, ordered
+// by creation.
+func (s *KeyStore) List() []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.list()
+	for i := range keys {
+		keys[i].Hash = ""
+	}
+	return keys
+}
+
+// Verify returns the key a token belongs to when it is valid.
+func (s *KeyStore) Verify(token string) (Key, error) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(token, keyPrefix), "_")
+	if !ok || !strings.HasPrefix(token, keyPrefix) {
+		return Key{}, ErrKeyNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash(token))) != 1 {
+		return Key{}, ErrKeyNotFound
+	}
+	if k.RevokedAt != 0 {
+		return Key{}, ErrKeyRevoked
+	}
+	if k.ExpiresAt != 0 && s.now().Unix() >= k.ExpiresAt {
+		return Key{}, ErrKeyExpired
+	}
+	return *k, nil
+}
+
+func (s *KeyStore) list() []Key {
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, *k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt != keys[j].CreatedAt {
+			return keys[i].CreatedAt < keys[j].CreatedAt
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+func (s *KeyStore) save() error {
+	data, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,40 @@
+// Command alertreceiver is a local webhook receiver for trying out alert
+// delivery. It logs every alert posted to it; point alert_webhook_url at it:
+//
+//	alertreceiver -addr :8090   (alert_webhook_url: http://localhost:8090/alerts)
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+
+	"test_task_app/alert"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	status := flag.Int("status", http.StatusNoContent, "status code to answer with, to try out failing receivers")
+	flag.Parse()
+
+	http.HandleFunc("/alerts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var alerts []alert.Alert
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			log.Printf("Invalid alerts payload: %v", err)
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+		for _, a := range alerts {
+			log.Printf("%s %s %s %v: %.2f -> %.2f (%+.2f%%)", a.Type, a.MatchName, a.Market, a.Codes, a.From, a.To, a.ChangePercent)
+		}
+		w.WriteHeader(*status)
+	})
+
+	log.Printf("Alert receiver listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
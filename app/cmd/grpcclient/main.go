@@ -0,0 +1,83 @@
+// Command grpcclient tries out the gRPC odds API: it prints one event, or
+// subscribes to the updates matching the filter flags and logs them.
+//
+//	grpcclient -addr localhost:6004 -sport FOOTBALL -kinds WIN,TOTAL -snapshot
+//	API_KEY=odk_... grpcclient -addr localhost:6004 -sport FOOTBALL
+//	grpcclient -addr localhost:6004 -key unibet-ubbe-1020304050
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"test_task_app/oddspb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:6004", "gRPC address of the parser")
+	key := flag.String("key", "", "print the latest snapshot of this event and exit")
+	sports := flag.String("sport", "", "comma separated sports to subscribe to")
+	operators := flag.String("operator", "", "comma separated operators to subscribe to")
+	kinds := flag.String("kinds", "", "comma separated market kinds to keep")
+	snapshot := flag.Bool("snapshot", false, "start with the current snapshots")
+	apiKey := flag.String("api-key", os.Getenv("API_KEY"), "API key or JWT, when the parser requires one")
+	flag.Parse()
+
+	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Could not connect: %v", err)
+	}
+	defer conn.Close()
+	client := oddspb.NewOddsClient(conn)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if *apiKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", *apiKey)
+	}
+
+	filter := &oddspb.Filter{Sports: split(*sports), Operators: split(*operators), MarketKinds: split(*kinds)}
+	if *key != "" {
+		event, err := client.GetEvent(ctx, &oddspb.GetEventRequest{Key: *key, Filter: filter})
+		if err != nil {
+			log.Fatalf("Could not get event: %v", err)
+		}
+		os.Stdout.Write([]byte(protojson.Format(event) + "\n"))
+		return
+	}
+
+	stream, err := client.Subscribe(ctx, &oddspb.SubscribeRequest{Filter: filter, IncludeSnapshot: *snapshot})
+	if err != nil {
+		log.Fatalf("Could not subscribe: %v", err)
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Fatalf("Subscription ended: %v", err)
+			}
+			return
+		}
+		outcomes := 0
+		for _, m := range event.Markets {
+			outcomes += len(m.Outcomes)
+		}
+		log.Printf("%s %s %s: %d markets, %d outcomes", event.Key, event.Sport, event.MatchName, len(event.Markets), outcomes)
+	}
+}
+
+func split(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
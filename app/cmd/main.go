@@ -5,17 +5,35 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"time"
 
 	"syscall"
 
+	"test_task_app/alert"
+	"test_task_app/auth"
+	"test_task_app/competition"
 	"test_task_app/config"
+	"test_task_app/discovery"
+	"test_task_app/feed"
+	"test_task_app/filter"
+	"test_task_app/grpcapi"
+	"test_task_app/participant"
+	"test_task_app/retention"
 	"test_task_app/service"
+	"test_task_app/sink"
+	"test_task_app/status"
+	"test_task_app/storage"
+	"test_task_app/translation"
+	"test_task_app/wsapi"
 
-	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -29,19 +47,168 @@ var (
 )
 
 func main() {
-	cfg := config.NewConfig()
+	log.SetOutput(config.RedactingWriter{W: os.Stderr})
+
+	cfg, configPath, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	var requestSemaphore = make(chan struct{}, cfg.MatchesPerBatch)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	createDirForData(cfg.PathToData)
 
-	for _, sportMode := range cfg.SportsToParse {
-		go service.UpdateMatches(ctx, cfg, requestSemaphore, g_chanMatchesData, sportMode)
+	catalog, err := storage.OpenCatalog(cfg.PathToData)
+	if err != nil {
+		log.Fatalf("Could not open catalog: %v", err)
+	}
+
+	logger := service.SetLogrus(cfg.LogLevel)
+
+	go retention.NewManager(cfg, catalog, logger).Run(ctx)
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Could not open %s storage: %v", cfg.StorageBackend, err)
+	}
+	writer := storage.NewWriter(cfg, store, catalog, logger)
+
+	participants, err := participant.NewRegistry(cfg.PathToData, cfg.ParticipantAliases)
+	if err != nil {
+		log.Fatalf("Could not load participants: %v", err)
+	}
+
+	competitions, err := competition.NewResolver(cfg.PathToData)
+	if err != nil {
+		log.Fatalf("Could not load competitions: %v", err)
+	}
+
+	labels, err := translation.NewTranslator(cfg.PathToData)
+	if err != nil {
+		log.Fatalf("Could not load untranslated labels: %v", err)
+	}
+
+	unmapped, err := discovery.NewCollector(cfg.PathToData, cfg.UnmappedSamples)
+	if err != nil {
+		log.Fatalf("Could not load unmapped markets report: %v", err)
+	}
+	go unmapped.Run(ctx, cfg.UnmappedReportInterval, logger)
+
+	statuses := status.NewTracker()
+
+	var alerts *alert.Engine
+	var notifier *alert.Notifier
+	if cfg.AlertsEnabled {
+		alerts = alert.NewEngine(cfg.Alerts)
+		notifier = alert.NewNotifier(cfg.PathToData, cfg.AlertWebhookURL, cfg.AlertWebhookTimeout, cfg.AlertsKept, logger)
+		go notifier.Run(ctx)
+	}
+
+	sinks, err := sink.New(cfg.Sinks, logger)
+	if err != nil {
+		log.Fatalf("Could not connect sinks: %v", err)
+	}
+
+	hub := feed.NewHub()
+
+	keysFile := cfg.AuthKeysFile
+	if keysFile == "" {
+		keysFile = filepath.Join(cfg.PathToData, auth.KeysFileName)
+	}
+	keys, err := auth.NewKeyStore(keysFile)
+	if err != nil {
+		log.Fatalf("Could not load API keys: %v", err)
+	}
+	authenticator := auth.New(cfg.Auth, keys)
+
+	filters, err := filter.New(cfg.Filters)
+	if err != nil {
+		log.Fatalf("Invalid filters: %v", err)
+	}
+
+	pipeline := &service.Pipeline{
+		Filter:       filters,
+		Store:        writer,
+		Catalog:      catalog,
+		Participants: participants,
+		Competitions: competitions,
+		Labels:       labels,
+		Unmapped:     unmapped,
+		Status:       statuses,
+		StatusTTL:    cfg.CompleteAfter,
+		Alerts:       alerts,
+		Notifier:     notifier,
+		Sinks:        sinks,
+		Feed:         hub,
+		Log:          logger,
 	}
 
-	http.HandleFunc("/ws", websocketHandler)
+	supervisor := service.NewSupervisor(ctx, g_chanMatchesData, logger)
+	supervisor.Apply(cfg, pipeline)
+
+	wsServer := wsapi.New(cfg.Websocket, authenticator, hub, logger)
+	go wsServer.Run(ctx, g_chanMatchesData)
+	authenticator.OnRevoke(wsServer.Disconnect)
+
+	// Every REST endpoint is authenticated, /ws authenticates on its own.
+	api := http.NewServeMux()
+	api.HandleFunc("/stats/websocket", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wsServer.Stats())
+	})
+	api.HandleFunc("/stats/writer", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(writer.Stats())
+	})
+	api.HandleFunc("/stats/sinks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sinks.Stats())
+	})
+	api.HandleFunc("/labels/untranslated", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(labels.Report())
+	})
+	api.HandleFunc("/markets/unmapped", func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(unmapped.Report(r.URL.Query().Get("sport"), limit))
+	})
+	api.HandleFunc("/markets/status", func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(w, "key not specified", http.StatusBadRequest)
+			return
+		}
+		if p := auth.FromContext(r.Context()); p.Restricted() {
+			if data, ok := hub.Get(key); !ok || !p.Allows(data.Sport, data.League) {
+				http.Error(w, "event not found", http.StatusNotFound)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(statuses.History(key))
+	})
+	api.HandleFunc("/alerts", func(w http.ResponseWriter, r *http.Request) {
+		recent := []alert.Alert{}
+		if notifier != nil {
+			p := auth.FromContext(r.Context())
+			for _, a := range notifier.Recent() {
+				if p.Allows(a.Sport, a.League) {
+					recent = append(recent, a)
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(recent)
+	})
+	api.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		yaml.NewEncoder(w).Encode(supervisor.Config().Redacted())
+	})
+	http.Handle("/", authenticator.Middleware(api))
+	http.Handle("/admin/", authenticator.AdminHandler())
+	http.Handle("/ws", wsServer)
 	server := &http.Server{
 		Addr: fmt.Sprintf("%s:%d", cfg.Websocket.Host, cfg.Websocket.Port),
 	}
@@ -53,53 +220,74 @@ func main() {
 		}
 	}()
 
+	var grpcServer *grpc.Server
+	if cfg.GRPCEnabled {
+		listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", cfg.Websocket.Host, cfg.GRPCPort))
+		if err != nil {
+			log.Fatalf("Could not listen for gRPC: %v", err)
+		}
+		grpcAPI := grpcapi.New(hub, authenticator, cfg.GRPCSubscriberBuffer, logger)
+		grpcServer = grpc.NewServer(grpcAPI.Options()...)
+		grpcAPI.Register(grpcServer)
+		authenticator.OnRevoke(grpcAPI.Disconnect)
+		go func() {
+			log.Printf("gRPC server started on %s:%d", cfg.Websocket.Host, cfg.GRPCPort)
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("gRPC Serve(): %v", err)
+			}
+		}()
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-	<-stop
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	changes, err := config.Watch(ctx, configPath)
+	if err != nil {
+		log.Printf("Config file watching disabled, reload with SIGHUP: %v", err)
+	}
+
+	for running := true; running; {
+		select {
+		case <-stop:
+			running = false
+		case <-hup:
+			log.Println("SIGHUP received, reloading config")
+			reloadConfig(configPath, supervisor)
+		case <-changes:
+			log.Println("Config file changed, reloading config")
+			reloadConfig(configPath, supervisor)
+		}
+	}
 
 	log.Println("Shutting down server...")
+	wsServer.Close(5 * time.Second)
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server Shutdown Failed:%+v", err)
 	}
-	log.Println("Server exited")
-}
 
-func websocketHandler(w http.ResponseWriter, r *http.Request) {
-	upgrader := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
+	if grpcServer != nil {
+		// Subscriptions never end on their own, so the server is stopped
+		// rather than drained.
+		grpcServer.Stop()
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
-		return
+	// Stop the pollers first so nothing is enqueued while the writer drains.
+	cancel()
+	supervisor.Wait()
+	if err := writer.Close(); err != nil {
+		log.Printf("Error closing storage: %v", err)
 	}
-	defer conn.Close()
-
-	log.Println("New client connected")
-	broadcastData(conn)
-	log.Println("Client disconnected")
-}
-
-func broadcastData(conn *websocket.Conn) {
-
-	for {
-		data, err := json.Marshal(<-g_chanMatchesData)
-
-		if err != nil {
-			log.Printf("Error marshalling data: %v", err)
-			return
-		}
-
-		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-			log.Printf("WebSocket error: %v", err)
-			return
-		}
-
-		time.Sleep(5 * time.Second)
+	if err := sinks.Close(10 * time.Second); err != nil {
+		log.Printf("Error closing sinks: %v", err)
 	}
+	if err := unmapped.Save(); err != nil {
+		log.Printf("Error saving unmapped markets report: %v", err)
+	}
+	stats := writer.Stats()
+	log.Printf("Flushed odds writer: written=%d failed=%d", stats.Written, stats.Failed)
+	log.Println("Server exited")
 }
 
 func createDirForData(dirName string) {
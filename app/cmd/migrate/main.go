@@ -0,0 +1,255 @@
+// Command migrate splits legacy match-name keyed odds files ("Home vs Away.jsonl")
+// into event-ID keyed files and fills the storage catalog. Files keyed
+// <provider>-<id>, from before storage keys named the operator, are moved to
+// <provider>-<operator>-<id> of the default operator. Records already in an
+// event file are skipped, so a migration that was interrupted can be rerun.
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"test_task_app/config"
+	"test_task_app/helper"
+	"test_task_app/storage"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// keyedFile matches the files of current storage keys, <provider>-<operator>-<id>.
+var keyedFile = regexp.MustCompile(`^[a-z]+-[a-z0-9]+-\d+\.jsonl`)
+
+func main() {
+	dir := flag.String("dir", "", "data directory to migrate (defaults to path_to_data from config)")
+	remove := flag.Bool("delete", false, "delete legacy files instead of renaming them to *.migrated")
+	operator := flag.String("operator", "", "operator of the records stored without one (defaults to api_country_code from config)")
+	cfg := config.NewConfig()
+
+	if *dir == "" {
+		*dir = cfg.PathToData
+	}
+	if *operator == "" {
+		*operator = cfg.APICountryCode
+	}
+	if *operator == "" {
+		log.Fatal("No default operator: pass -operator or set api_country_code")
+	}
+
+	catalog, err := storage.OpenCatalog(*dir)
+	if err != nil {
+		log.Fatalf("Could not open catalog: %v", err)
+	}
+
+	files, err := legacyFiles(*dir)
+	if err != nil {
+		log.Fatalf("Could not read directory: %v", err)
+	}
+
+	outputs := make(map[string]*output)
+	defer func() {
+		for _, out := range outputs {
+			out.file.Close()
+		}
+	}()
+
+	for _, path := range files {
+		lines, skipped, err := migrateFile(path, *dir, *operator, catalog, outputs)
+		if err != nil {
+			log.Printf("Error migrating %s: %v", path, err)
+			continue
+		}
+
+		if *remove {
+			err = os.Remove(path)
+		} else {
+			err = os.Rename(path, path+".migrated")
+		}
+		if err != nil {
+			log.Printf("Error retiring %s: %v", path, err)
+		}
+		log.Printf("Migrated %s: %d records, %d already migrated", filepath.Base(path), lines, skipped)
+	}
+
+	// The entries of keys without operator moved with their records.
+	catalog.Prune(func(entry storage.CatalogEntry) bool { return entry.Operator != "" })
+	if err := catalog.Save(); err != nil {
+		log.Fatalf("Could not save catalog: %v", err)
+	}
+	log.Printf("Migrated %d files into %d event files", len(files), len(outputs))
+}
+
+// legacyFiles returns the name-keyed files and the files keyed without
+// operator in dir, including rotated and compressed segments. The segments of
+// a file come in rotation order before the file itself, so records are
+// replayed chronologically.
+func legacyFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.Contains(name, ".jsonl") || keyedFile.MatchString(name) {
+			continue
+		}
+		if strings.HasSuffix(name, ".migrated") || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if strings.HasSuffix(a, ".jsonl") && strings.HasPrefix(b, a+".") {
+			return false
+		}
+		if strings.HasSuffix(b, ".jsonl") && strings.HasPrefix(a, b+".") {
+			return true
+		}
+		return a < b
+	})
+	return files, nil
+}
+
+// output is an event file being migrated into, with the hashes of the
+// records it holds.
+type output struct {
+	file    *os.File
+	records map[[sha256.Size]byte]struct{}
+}
+
+// openOutput opens the event file of key for appending and reads the records
+// already in it.
+func openOutput(dir, key string) (*output, error) {
+	path := filepath.Join(dir, key+".jsonl")
+	out := &output{records: make(map[[sha256.Size]byte]struct{})}
+
+	existing, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+		for scanner.Scan() {
+			out.records[sha256.Sum256(scanner.Bytes())] = struct{}{}
+		}
+		existing.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if out.file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// migrateFile appends the records of a legacy file to their event files,
+// skipping those already there, and returns how many it wrote and skipped.
+// Records without operator are taken to be of the default operator.
+func migrateFile(path, dir, operator string, catalog *storage.Catalog, outputs map[string]*output) (int, int, error) {
+	reader, err := openLegacy(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+
+	lines, skipped := 0, 0
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var data helper.ProcessedData
+		if err := json.Unmarshal(scanner.Bytes(), &data); err != nil {
+			return lines, skipped, fmt.Errorf("line %d: %w", lines+skipped+1, err)
+		}
+		if data.EventID == 0 {
+			return lines, skipped, fmt.Errorf("line %d: missing event_id", lines+skipped+1)
+		}
+		if data.Provider == "" {
+			data.Provider = helper.ProviderUnibet
+		}
+		if data.Operator == "" {
+			data.Operator = operator
+		}
+
+		key := data.StorageKey()
+		out, ok := outputs[key]
+		if !ok {
+			if out, err = openOutput(dir, key); err != nil {
+				return lines, skipped, err
+			}
+			outputs[key] = out
+		}
+
+		record, err := json.Marshal(data)
+		if err != nil {
+			return lines, skipped, err
+		}
+		catalog.Update(data)
+		hash := sha256.Sum256(record)
+		if _, ok := out.records[hash]; ok {
+			skipped++
+			continue
+		}
+		if _, err := out.file.Write(append(record, '\n')); err != nil {
+			return lines, skipped, err
+		}
+		out.records[hash] = struct{}{}
+		lines++
+	}
+	return lines, skipped, scanner.Err()
+}
+
+func openLegacy(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{reader, file}, nil
+	case strings.HasSuffix(path, ".zst"):
+		decoder, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{decoder, closerFunc(func() error { decoder.Close(); return file.Close() })}, nil
+	}
+	return file, nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"reflect"
+
+	"test_task_app/config"
+	"test_task_app/filter"
+	"test_task_app/service"
+)
+
+// reloadConfig loads the config file again and hands it to the supervisor.
+// An invalid file is rejected as a whole and the active config stays in use.
+func reloadConfig(path string, supervisor *service.Supervisor) {
+	cfg, err := config.LoadPath(path)
+	if err != nil {
+		log.Printf("Config reload rejected, keeping the active config: %v", err)
+		return
+	}
+
+	active := supervisor.Config()
+	keepRestartOnlySettings(&cfg, active)
+
+	pipeline := *supervisor.Pipeline()
+	if !reflect.DeepEqual(active.Filters, cfg.Filters) {
+		filters, err := filter.New(cfg.Filters)
+		if err != nil {
+			log.Printf("Config reload rejected, keeping the active config: %v", err)
+			return
+		}
+		pipeline.Filter = filters
+	}
+
+	supervisor.Apply(cfg, &pipeline)
+	log.Printf("Config reloaded from %s", path)
+}
+
+// keepRestartOnlySettings restores the settings that are bound at startup,
+// warning about every change that needs a restart to take effect.
+func keepRestartOnlySettings(cfg *config.Config, active config.Config) {
+	if !reflect.DeepEqual(cfg.Websocket, active.Websocket) {
+		log.Println("websocket settings changed, restart the parser to apply them")
+		cfg.Websocket = active.Websocket
+	}
+	if !reflect.DeepEqual(cfg.Retention, active.Retention) {
+		log.Println("retention settings changed, restart the parser to apply them")
+		cfg.Retention = active.Retention
+	}
+	if !reflect.DeepEqual(cfg.Storage, active.Storage) {
+		log.Println("storage settings changed, restart the parser to apply them")
+		cfg.Storage = active.Storage
+	}
+	if !reflect.DeepEqual(cfg.Reports, active.Reports) {
+		log.Println("reports settings changed, restart the parser to apply them")
+		cfg.Reports = active.Reports
+	}
+	if !reflect.DeepEqual(cfg.Alerts, active.Alerts) {
+		log.Println("alerts settings changed, restart the parser to apply them")
+		cfg.Alerts = active.Alerts
+	}
+	if !reflect.DeepEqual(cfg.Sinks, active.Sinks) {
+		log.Println("sinks settings changed, restart the parser to apply them")
+		cfg.Sinks = active.Sinks
+	}
+	if cfg.GRPC != active.GRPC {
+		log.Println("grpc settings changed, restart the parser to apply them")
+		cfg.GRPC = active.GRPC
+	}
+	if !reflect.DeepEqual(cfg.Auth, active.Auth) {
+		log.Println("auth settings changed, restart the parser to apply them")
+		cfg.Auth = active.Auth
+	}
+	if cfg.PathToData != active.PathToData {
+		log.Println("path_to_data changed, restart the parser to apply it")
+		cfg.PathToData = active.PathToData
+	}
+	if cfg.ParticipantAliases != active.ParticipantAliases {
+		log.Println("participant_aliases_file changed, restart the parser to apply it")
+		cfg.ParticipantAliases = active.ParticipantAliases
+	}
+}
@@ -0,0 +1,85 @@
+// Command secrets edits the encrypted secrets file referenced from the config
+// as ${secret:name}. The passphrase is read from SECRETS_KEY.
+//
+//	secrets -file secrets.enc set NAME [VALUE]   (VALUE is read from stdin when omitted)
+//	secrets -file secrets.enc delete NAME
+//	secrets -file secrets.enc list
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"test_task_app/config"
+)
+
+func main() {
+	file := flag.String("file", "", "encrypted secrets file")
+	flag.Parse()
+
+	if *file == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*file, os.Getenv(config.SecretsKeyEnv), flag.Args(), os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run executes one command on the secrets file.
+func run(file, passphrase string, args []string, stdin io.Reader, stdout io.Writer) error {
+	secrets := make(map[string]string)
+	if _, err := os.Stat(file); err == nil {
+		secrets, err = config.ReadSecretsFile(file, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch args[0] {
+	case "set":
+		if len(args) < 2 || len(args) > 3 {
+			return errors.New("usage: set NAME [VALUE]")
+		}
+		value := ""
+		if len(args) == 3 {
+			value = args[2]
+		} else {
+			line, err := bufio.NewReader(stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("could not read value: %w", err)
+			}
+			value = strings.TrimRight(line, "\r\n")
+		}
+		secrets[args[1]] = value
+	case "delete":
+		if len(args) != 2 {
+			return errors.New("usage: delete NAME")
+		}
+		delete(secrets, args[1])
+	case "list":
+		names := make([]string, 0, len(secrets))
+		for name := range secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintln(stdout, name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q, want set, delete or list", args[0])
+	}
+
+	if err := config.WriteSecretsFile(file, passphrase, secrets); err != nil {
+		return fmt.Errorf("could not write secrets file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"test_task_app/config"
+)
+
+func TestRun(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secrets.enc")
+	steps := []struct {
+		args  []string
+		stdin string
+		want  string
+	}{
+		{args: []string{"set", "sink_webhook", "signing-secret"}},
+		{args: []string{"set", "admin_token"}, stdin: "from-stdin\n"},
+		{args: []string{"set", "stale", "x"}},
+		{args: []string{"delete", "stale"}},
+		{args: []string{"list"}, want: "admin_token\nsink_webhook\n"},
+	}
+	for _, step := range steps {
+		var stdout bytes.Buffer
+		if err := run(file, "passphrase", step.args, strings.NewReader(step.stdin), &stdout); err != nil {
+			t.Fatalf("%s: %v", step.args, err)
+		}
+		if stdout.String() != step.want {
+			t.Errorf("%s printed %q, want %q", step.args, stdout.String(), step.want)
+		}
+	}
+
+	// The config reads what the tool wrote.
+	secrets, err := config.ReadSecretsFile(file, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 2 || secrets["sink_webhook"] != "signing-secret" || secrets["admin_token"] != "from-stdin" {
+		t.Errorf("secrets %v", secrets)
+	}
+
+	for _, args := range [][]string{{"list"}, {"set", "x", "y"}} {
+		if err := run(file, "wrong", args, strings.NewReader(""), &bytes.Buffer{}); err == nil {
+			t.Errorf("%s with the wrong passphrase succeeded", args)
+		}
+	}
+	if err := run(file, "passphrase", []string{"rotate"}, strings.NewReader(""), &bytes.Buffer{}); err == nil {
+		t.Error("unknown command succeeded")
+	}
+}
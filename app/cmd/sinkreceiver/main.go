@@ -0,0 +1,58 @@
+// Command sinkreceiver is a local stand-in for a consumer of the webhook sink.
+// It checks the signature of every update posted to it and logs it; point
+// sink_webhook_url at it:
+//
+//	sinkreceiver -addr :8091 -secret s3cret   (sink_webhook_url: http://localhost:8091/odds)
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+
+	"test_task_app/helper"
+	"test_task_app/sink"
+)
+
+func main() {
+	addr := flag.String("addr", ":8091", "listen address")
+	secret := flag.String("secret", "", "shared secret, unsigned requests are accepted when empty")
+	failRate := flag.Float64("fail-rate", 0, "share of requests answered with 503, to try out retries")
+	flag.Parse()
+
+	http.HandleFunc("/odds", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "error reading body", http.StatusBadRequest)
+			return
+		}
+		if *secret != "" && !sink.Verify([]byte(*secret), r.Header.Get(sink.HeaderTimestamp), body, r.Header.Get(sink.HeaderSignature)) {
+			log.Printf("Invalid signature for %s", r.Header.Get(sink.HeaderKey))
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if rand.Float64() < *failRate {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var data helper.ProcessedData
+		if err := json.Unmarshal(body, &data); err != nil {
+			log.Printf("Invalid update payload: %v", err)
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+		log.Printf("%s %s %s: %d outcomes", r.Header.Get(sink.HeaderKey), data.Sport, data.MatchName, len(data.Outcomes))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("Sink receiver listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,207 @@
+package competition
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// regions maps countries, lower-cased, to their region.
+var regions = map[string]string{
+	"albania": "Europe", "andorra": "Europe", "armenia": "Europe", "austria": "Europe", "azerbaijan": "Europe",
+	"belarus": "Europe", "belgium": "Europe", "bosnia and herzegovina": "Europe", "bulgaria": "Europe",
+	"croatia": "Europe", "cyprus": "Europe", "czech republic": "Europe", "czechia": "Europe", "denmark": "Europe",
+	"england": "Europe", "estonia": "Europe", "faroe islands": "Europe", "finland": "Europe", "france": "Europe",
+	"georgia": "Europe", "germany": "Europe", "gibraltar": "Europe", "greece": "Europe", "hungary": "Europe",
+	"iceland": "Europe", "ireland": "Europe", "israel": "Europe", "italy": "Europe", "kazakhstan": "Europe",
+	"kosovo": "Europe", "latvia": "Europe", "liechtenstein": "Europe", "lithuania": "Europe", "luxembourg": "Europe",
+	"malta": "Europe", "moldova": "Europe", "monaco": "Europe", "montenegro": "Europe", "netherlands": "Europe", "north macedonia": "Europe",
+	"northern ireland": "Europe", "norway": "Europe", "poland": "Europe", "portugal": "Europe", "romania": "Europe",
+	"russia": "Europe", "san marino": "Europe", "scotland": "Europe", "serbia": "Europe", "slovakia": "Europe",
+	"slovenia": "Europe", "spain": "Europe", "sweden": "Europe", "switzerland": "Europe", "turkey": "Europe",
+	"ukraine": "Europe", "wales": "Europe",
+	"argentina": "South America", "bolivia": "South America", "brazil": "South America", "chile": "South America",
+	"colombia": "South America", "ecuador": "South America", "paraguay": "South America", "peru": "South America",
+	"uruguay": "South America", "venezuela": "South America",
+	"canada": "North America", "costa rica": "North America", "el salvador": "North America", "guatemala": "North America",
+	"honduras": "North America", "jamaica": "North America", "mexico": "North America", "usa": "North America",
+	"united states": "North America",
+	"algeria":       "Africa", "cameroon": "Africa", "egypt": "Africa", "ghana": "Africa", "ivory coast": "Africa",
+	"kenya": "Africa", "morocco": "Africa", "nigeria": "Africa", "senegal": "Africa", "south africa": "Africa",
+	"tunisia":   "Africa",
+	"australia": "Oceania", "new zealand": "Oceania",
+	"china": "Asia", "hong kong": "Asia", "india": "Asia", "indonesia": "Asia", "iran": "Asia", "japan": "Asia",
+	"jordan": "Asia", "qatar": "Asia", "saudi arabia": "Asia", "singapore": "Asia", "south korea": "Asia",
+	"korea republic": "Asia", "thailand": "Asia", "united arab emirates": "Asia", "uzbekistan": "Asia", "vietnam": "Asia",
+}
+
+// internationalRegions are path nodes that group competitions across countries.
+var internationalRegions = map[string]string{
+	"international":          RegionInternational,
+	"international clubs":    RegionInternational,
+	"world":                  RegionInternational,
+	"champions league":       "Europe",
+	"europa league":          "Europe",
+	"conference league":      "Europe",
+	"uefa":                   "Europe",
+	"europe":                 "Europe",
+	"copa libertadores":      "South America",
+	"copa sudamericana":      "South America",
+	"south america":          "South America",
+	"concacaf":               "North America",
+	"africa":                 "Africa",
+	"asia":                   "Asia",
+	"club friendly matches":  RegionInternational,
+	"international friendly": RegionInternational,
+}
+
+// tennisVenues lists tennis tournament names with the country they are played
+// in, covering the events where the path carries no country.
+var tennisVenues = venues(map[string]string{
+	"australian open": "Australia", "roland garros": "France", "french open": "France", "wimbledon": "England",
+	"us open": "USA", "indian wells": "USA", "miami": "USA", "monte carlo": "Monaco", "madrid": "Spain",
+	"rome": "Italy", "canada": "Canada", "montreal": "Canada", "toronto": "Canada", "cincinnati": "USA",
+	"shanghai": "China", "paris": "France", "beijing": "China", "wuhan": "China", "doha": "Qatar",
+	"dubai": "United Arab Emirates", "stuttgart": "Germany", "halle": "Germany", "queens club": "England",
+	"barcelona": "Spain", "hamburg": "Germany", "vienna": "Austria", "basel": "Switzerland", "rotterdam": "Netherlands",
+	"antwerp": "Belgium", "brisbane": "Australia", "adelaide": "Australia", "auckland": "New Zealand",
+	"acapulco": "Mexico", "rio de janeiro": "Brazil", "buenos aires": "Argentina", "tokyo": "Japan",
+})
+
+// venue matches a tennis tournament name as whole words, so "halle" does not
+// match "challenger".
+type venue struct {
+	pattern *regexp.Regexp
+	country string
+}
+
+// venues orders the venue names longest first, so the most specific name
+// wins when a tournament contains several.
+func venues(countries map[string]string) []venue {
+	names := make([]string, 0, len(countries))
+	for name := range countries {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+
+	list := make([]venue, len(names))
+	for i, name := range names {
+		list[i] = venue{pattern: regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`), country: countries[name]}
+	}
+	return list
+}
+
+var (
+	womenPattern = regexp.MustCompile(`(?i)\b(women|womens|woman|ladies|female|wta)\b|\(w\)`)
+	menPattern   = regexp.MustCompile(`(?i)\b(men|mens|atp)\b|\(m\)`)
+	mixedPattern = regexp.MustCompile(`(?i)\bmixed\b`)
+	qualifier    = regexp.MustCompile(`(?i)\s*(\((m|w)\)|-?\s*(qualification|qualifying|doubles))\s*$`)
+)
+
+// classify fills every field of a competition except ID from the path names.
+func classify(names []string) Competition {
+	c := Competition{
+		Sport:      names[0],
+		Country:    Unknown,
+		Region:     Unknown,
+		Tournament: names[len(names)-1],
+		Gender:     GenderMen,
+		Path:       names,
+	}
+
+	if strings.EqualFold(c.Sport, "tennis") {
+		classifyTennis(&c, names)
+		return c
+	}
+
+	for _, name := range names[1:] {
+		lower := strings.ToLower(name)
+		if region, ok := regions[lower]; ok {
+			c.Country = name
+			c.Region = region
+			break
+		}
+		if region, ok := internationalRegions[lower]; ok {
+			c.Region = region
+			c.Country = RegionInternational
+			break
+		}
+	}
+	if c.Region == Unknown && len(names) > 2 {
+		// Unknown middle nodes are countries we have no region for yet.
+		c.Country = names[1]
+	}
+
+	joined := strings.Join(names, " ")
+	if womenPattern.MatchString(joined) {
+		c.Gender = GenderWomen
+	}
+	return c
+}
+
+func classifyTennis(c *Competition, names []string) {
+	c.Region = RegionInternational
+	c.Gender = Unknown
+
+	if len(names) > 1 {
+		c.TourLevel = tourLevel(names[1])
+	}
+	if len(names) > 2 && c.TourLevel == Unknown {
+		c.TourLevel = tourLevel(names[len(names)-1])
+	}
+
+	joined := strings.Join(names[1:], " ")
+	switch {
+	case mixedPattern.MatchString(joined):
+		c.Gender = GenderMixed
+	case womenPattern.MatchString(joined):
+		c.Gender = GenderWomen
+	case menPattern.MatchString(joined), c.TourLevel == "ATP", c.TourLevel == "Challenger":
+		c.Gender = GenderMen
+	}
+
+	venue := strings.ToLower(qualifier.ReplaceAllString(c.Tournament, ""))
+	for _, v := range tennisVenues {
+		if v.pattern.MatchString(venue) {
+			c.Country = v.country
+			c.Region = countryRegion(v.country)
+			break
+		}
+	}
+}
+
+// tourLevel recognizes the tour from a tennis path node.
+func tourLevel(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "challenger"):
+		return "Challenger"
+	case strings.Contains(lower, "itf"):
+		return "ITF"
+	case strings.Contains(lower, "wta 125"):
+		return "WTA 125"
+	case strings.Contains(lower, "wta"):
+		return "WTA"
+	case strings.Contains(lower, "atp"):
+		return "ATP"
+	case strings.Contains(lower, "grand slam"):
+		return "Grand Slam"
+	case strings.Contains(lower, "davis cup"), strings.Contains(lower, "billie jean king"), strings.Contains(lower, "united cup"):
+		return "Team"
+	case strings.Contains(lower, "utr"), strings.Contains(lower, "exhibition"):
+		return "Exhibition"
+	}
+	return Unknown
+}
+
+func countryRegion(country string) string {
+	if region, ok := regions[strings.ToLower(country)]; ok {
+		return region
+	}
+	return Unknown
+}
@@ -0,0 +1,36 @@
+package competition
+
+import "testing"
+
+func TestClassifyTennis(t *testing.T) {
+	tests := []struct {
+		names     []string
+		country   string
+		region    string
+		tourLevel string
+		gender    string
+	}{
+		{[]string{"Tennis", "ATP", "Halle"}, "Germany", "Europe", "ATP", GenderMen},
+		{[]string{"Tennis", "Challenger", "Challenger Seoul"}, Unknown, RegionInternational, "Challenger", GenderMen},
+		{[]string{"Tennis", "Challenger", "Challenger Hamburg - Qualification"}, "Germany", "Europe", "Challenger", GenderMen},
+		{[]string{"Tennis", "ATP", "Rio de Janeiro"}, "Brazil", "South America", "ATP", GenderMen},
+		{[]string{"Tennis", "WTA", "Rome (W)"}, "Italy", "Europe", "WTA", GenderWomen},
+		{[]string{"Tennis", "ATP", "Romeo Cup"}, Unknown, RegionInternational, "ATP", GenderMen},
+		{[]string{"Tennis", "Grand Slam", "US Open Mixed Doubles"}, "USA", "North America", "Grand Slam", GenderMixed},
+		{[]string{"Tennis", "Grand Slam", "Australian Open"}, "Australia", "Oceania", "Grand Slam", Unknown},
+	}
+	for _, tt := range tests {
+		c := classify(tt.names)
+		if c.Country != tt.country || c.Region != tt.region || c.TourLevel != tt.tourLevel || c.Gender != tt.gender {
+			t.Errorf("classify(%q) = %s, %s, %s, %s, want %s, %s, %s, %s", tt.names, c.Country, c.Region, c.TourLevel, c.Gender,
+				tt.country, tt.region, tt.tourLevel, tt.gender)
+		}
+	}
+}
+
+func TestVenuesLongestFirst(t *testing.T) {
+	list := venues(map[string]string{"paris": "France", "paris hilton": "Nowhere", "rome": "Italy"})
+	if got := list[0].country; got != "Nowhere" {
+		t.Errorf("first venue is in %s, want the longest name first", got)
+	}
+}
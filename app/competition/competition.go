@@ -0,0 +1,187 @@
+// Package competition derives country, region, tournament, tour level and
+// gender from the Kambi event path (sport / country or tour / competition)
+// and keeps a catalog of the competitions seen so far.
+package competition
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CatalogFileName is the file inside the data directory listing every
+// competition seen so far.
+const CatalogFileName = "competitions.json"
+
+const (
+	Unknown = "Unknown"
+
+	GenderMen   = "Men"
+	GenderWomen = "Women"
+	GenderMixed = "Mixed"
+
+	RegionInternational = "International"
+)
+
+type Competition struct {
+	ID         string   `json:"id"`
+	Sport      string   `json:"sport"`
+	Country    string   `json:"country"`
+	Region     string   `json:"region"`
+	Tournament string   `json:"tournament"`
+	TourLevel  string   `json:"tour_level,omitempty"`
+	Gender     string   `json:"gender"`
+	Path       []string `json:"path"`
+}
+
+// Resolver maps event paths to competitions. The competition ID is derived
+// from the provider and the provider's ID of the deepest path node, so it
+// stays the same for every event of that competition.
+type Resolver struct {
+	path         string
+	mu           sync.Mutex
+	competitions map[string]Competition
+	dirty        bool
+}
+
+func NewResolver(dir string) (*Resolver, error) {
+	r := &Resolver{
+		path:         filepath.Join(dir, CatalogFileName),
+		competitions: make(map[string]Competition),
+	}
+
+	data, err := os.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var competitions []Competition
+	if err := json.Unmarshal(data, &competitions); err != nil {
+		return nil, err
+	}
+	for _, c := range competitions {
+		r.competitions[c.ID] = c
+	}
+	return r, nil
+}
+
+// Resolve returns the competition for the event path, registering it when
+// it is new. An empty path yields a competition with unknown fields.
+func (r *Resolver) Resolve(provider string, path []map[string]interface{}) Competition {
+	names, leafID := pathNames(path)
+	if len(names) == 0 {
+		return Competition{Country: Unknown, Region: Unknown, Tournament: Unknown, Gender: Unknown}
+	}
+
+	id := fmt.Sprintf("%s-%s", provider, leafID)
+	if leafID == "" {
+		id = fmt.Sprintf("%s-%s", provider, strings.ToLower(strings.Join(names, "/")))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.competitions[id]; ok && equalPath(c.Path, names) {
+		return c
+	}
+
+	c := classify(names)
+	c.ID = id
+	r.competitions[id] = c
+	r.dirty = true
+	return c
+}
+
+// Classify describes the competition of an event path without registering
+// it, the returned competition has no ID.
+func Classify(path []map[string]interface{}) Competition {
+	names, _ := pathNames(path)
+	if len(names) == 0 {
+		return Competition{Country: Unknown, Region: Unknown, Tournament: Unknown, Gender: Unknown}
+	}
+	return classify(names)
+}
+
+// Get returns the competition with the given ID.
+func (r *Resolver) Get(id string) (Competition, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.competitions[id]
+	return c, ok
+}
+
+// Save persists the catalog if competitions were added since the last save.
+func (r *Resolver) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.dirty {
+		return nil
+	}
+
+	competitions := make([]Competition, 0, len(r.competitions))
+	for _, c := range r.competitions {
+		competitions = append(competitions, c)
+	}
+	sort.Slice(competitions, func(i, j int) bool { return competitions[i].ID < competitions[j].ID })
+
+	data, err := json.MarshalIndent(competitions, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return err
+	}
+	r.dirty = false
+	return nil
+}
+
+// pathNames returns the English names of the path nodes and the provider ID
+// of the deepest node.
+func pathNames(path []map[string]interface{}) ([]string, string) {
+	var names []string
+	var leafID string
+	for _, node := range path {
+		name, _ := node["englishName"].(string)
+		if name == "" {
+			name, _ = node["name"].(string)
+		}
+		if name == "" {
+			continue
+		}
+		names = append(names, strings.TrimSpace(name))
+		switch id := node["id"].(type) {
+		case float64:
+			leafID = fmt.Sprintf("%d", int64(id))
+		case string:
+			leafID = id
+		default:
+			leafID = ""
+		}
+	}
+	return names, leafID
+}
+
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
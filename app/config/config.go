@@ -1,7 +1,9 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"time"
 
@@ -12,14 +14,47 @@ type (
 	Config struct {
 		Websocket  `yaml:"websocket"`
 		Unibet     `yaml:"unibet"`
+		Retention  `yaml:"retention"`
+		Storage    `yaml:"storage"`
+		Filters    `yaml:"filters"`
+		Reports    `yaml:"reports"`
+		Alerts     `yaml:"alerts"`
+		Sinks      `yaml:"sinks"`
+		GRPC       `yaml:"grpc"`
+		Auth       `yaml:"auth"`
 		Timeout    time.Duration `yaml:"timeout_on_external_service"`
 		PathToData string        `yaml:"path_to_data"`
-		LogLevel   string        `yaml:"log_level"`
+		// ParticipantAliases is an optional YAML file of alternative participant names.
+		ParticipantAliases string `yaml:"participant_aliases_file"`
+		// SecretsFile is the encrypted file ${secret:name} references are read from.
+		SecretsFile string `yaml:"secrets_file" env:"SECRETS_FILE"`
+		LogLevel    string `yaml:"log_level"`
 	}
 
 	Websocket struct {
 		Host string `yaml:"websocket_host"`
 		Port int    `yaml:"websocket_port"`
+		// WebsocketCompression offers permessage-deflate to the clients that
+		// ask for it, at a level from -2 (Huffman only) to 9.
+		WebsocketCompression      bool `yaml:"websocket_compression"`
+		WebsocketCompressionLevel int  `yaml:"websocket_compression_level" env-default:"1"`
+		// WebsocketSendBuffer is how many batches may wait for a client
+		// before it is disconnected as too slow.
+		WebsocketSendBuffer   int           `yaml:"websocket_send_buffer" env-default:"32"`
+		WebsocketWriteTimeout time.Duration `yaml:"websocket_write_timeout" env-default:"10s"`
+		// WebsocketPingInterval is how often clients are pinged, a client
+		// that sends nothing, pongs included, for WebsocketPongTimeout is
+		// disconnected.
+		WebsocketPingInterval time.Duration `yaml:"websocket_ping_interval" env-default:"20s"`
+		WebsocketPongTimeout  time.Duration `yaml:"websocket_pong_timeout" env-default:"60s"`
+		// WebsocketMaxMessageSize limits the messages clients may send.
+		WebsocketMaxMessageSize int64 `yaml:"websocket_max_message_size" env-default:"4096"`
+		// WebsocketReplayBuffer is how many recent batches are kept for
+		// clients resuming from a sequence number, older resumes get a
+		// snapshot. WebsocketResumeWait is how long a new client may take to
+		// ask for a resume before it is sent a snapshot.
+		WebsocketReplayBuffer int           `yaml:"websocket_replay_buffer" env-default:"256"`
+		WebsocketResumeWait   time.Duration `yaml:"websocket_resume_wait" env-default:"500ms"`
 	}
 
 	Unibet struct {
@@ -30,23 +65,226 @@ type (
 		Market                 string        `yaml:"market"`
 		ClientID               string        `yaml:"client_id"`
 		ChannelID              string        `yaml:"channel_id"`
-		Proxies                []string      `yaml:"proxies"`
+		Proxies                []string      `yaml:"proxies" secret:"optional"`
 		LiveUpdateInterval     time.Duration `yaml:"live_update_interval"`
 		PrematchUpdateInterval time.Duration `yaml:"prematch_update_interval"`
+		OutrightUpdateInterval time.Duration `yaml:"outright_update_interval" env-default:"15m"`
 		MatchesPerBatch        int           `yaml:"matches_per_batch"`
 		SportsToParse          []SportMode   `yaml:"sports_to_parse"`
 		UserAgent              string        `yaml:"user_agent"`
 		RawURLfetchMatch       string        `yaml:"raw_url_fetch_match"`
 		RawURLgetMatchesIsLive string        `yaml:"raw_url_get_matches_is_live"`
 		RawURLgetMatches       string        `yaml:"raw_url_get_matches"`
+		RawURLgetOutrights     string        `yaml:"raw_url_get_outrights" env-default:"%s/listView/%s/all/all/all/competitions.json"`
+		// Offerings lists the Kambi operators to poll. Unset fields fall back
+		// to the settings above, an empty list polls api_country_code alone.
+		Offerings []Offering `yaml:"offerings"`
 	}
 
+	// Offering is one Kambi operator offering, e.g. "ubbe" for Unibet
+	// Belgium, polled with its own locale, sports and intervals.
+	Offering struct {
+		Operator               string            `yaml:"operator"`
+		CountryCode            string            `yaml:"country_code"`
+		Lang                   string            `yaml:"lang"`
+		Market                 string            `yaml:"market"`
+		Headers                map[string]string `yaml:"headers"`
+		SportsToParse          []SportMode       `yaml:"sports_to_parse"`
+		LiveUpdateInterval     time.Duration     `yaml:"live_update_interval"`
+		PrematchUpdateInterval time.Duration     `yaml:"prematch_update_interval"`
+		OutrightUpdateInterval time.Duration     `yaml:"outright_update_interval"`
+	}
+
+	Retention struct {
+		RetentionEnabled       bool          `yaml:"retention_enabled"`
+		RetentionCheckInterval time.Duration `yaml:"retention_check_interval" env-default:"1m"`
+		RotateMaxFileSizeMB    int64         `yaml:"rotate_max_file_size_mb"`
+		RotateDaily            bool          `yaml:"rotate_daily"`
+		CompleteAfter          time.Duration `yaml:"complete_after" env-default:"6h"`
+		Compression            string        `yaml:"compression" env-default:"gzip"`
+		MaxAge                 time.Duration `yaml:"max_age"`
+		DiskQuotaMB            int64         `yaml:"disk_quota_mb"`
+	}
+
+	Storage struct {
+		StorageBackend string `yaml:"storage_backend" env-default:"file"`
+		SQLitePath     string `yaml:"sqlite_path"`
+		SQLDriver      string `yaml:"sql_driver" env-default:"pgx"`
+		SQLDSN         string `yaml:"sql_dsn" env:"STORAGE_SQL_DSN" secret:"optional"`
+		Writer         `yaml:"writer"`
+	}
+
+	Writer struct {
+		WriterQueueSize     int           `yaml:"writer_queue_size" env-default:"10000"`
+		WriterBatchSize     int           `yaml:"writer_batch_size" env-default:"500"`
+		WriterFlushInterval time.Duration `yaml:"writer_flush_interval" env-default:"1s"`
+	}
+
+	Filters struct {
+		// DefaultAction applies to events no rule matched: "include" or "exclude".
+		DefaultAction string                   `yaml:"default_action" env-default:"include"`
+		StartWindow   map[string]time.Duration `yaml:"start_window"`
+		MinMarkets    int                      `yaml:"min_markets"`
+		LiveStates    []string                 `yaml:"live_states"`
+		Rules         []FilterRule             `yaml:"rules"`
+	}
+
+	// FilterRule matches an event when every criterion that is set matches;
+	// within a list any value is enough. Rules are evaluated in order and the
+	// first matching one decides.
+	FilterRule struct {
+		Name             string   `yaml:"name"`
+		Action           string   `yaml:"action"`
+		Sports           []string `yaml:"sports"`
+		Modes            []string `yaml:"modes"`
+		Operators        []string `yaml:"operators"`
+		Leagues          []string `yaml:"leagues"`
+		Countries        []string `yaml:"countries"`
+		PathContains     []string `yaml:"path_contains"`
+		ParticipantRegex string   `yaml:"participant_regex"`
+	}
+
+	Reports struct {
+		// UnmappedReportInterval is how often the unmapped markets report is written.
+		UnmappedReportInterval time.Duration `yaml:"unmapped_report_interval" env-default:"5m"`
+		// UnmappedSamples is how many sample bet offers are kept per unmapped market.
+		UnmappedSamples int `yaml:"unmapped_samples" env-default:"3"`
+	}
+
+	// Alerts configures the detection of sharp price movements.
+	Alerts struct {
+		AlertsEnabled bool `yaml:"alerts_enabled"`
+		// AlertMovePercent is the price change within AlertWindow that raises
+		// a move alert, and a steam alert when AlertSteamLines lines of one
+		// market move the same way.
+		AlertMovePercent float64       `yaml:"alert_move_percent" env-default:"5"`
+		AlertWindow      time.Duration `yaml:"alert_window" env-default:"5m"`
+		AlertSteamLines  int           `yaml:"alert_steam_lines" env-default:"3"`
+		// AlertConsensusPercent is how far a price may stray from the median
+		// of the other operators, 0 disables consensus alerts.
+		AlertConsensusPercent float64 `yaml:"alert_consensus_percent" env-default:"10"`
+		// AlertWebhookURL receives every alert as a JSON POST when set.
+		AlertWebhookURL     string        `yaml:"alert_webhook_url" env:"ALERT_WEBHOOK_URL"`
+		AlertWebhookTimeout time.Duration `yaml:"alert_webhook_timeout" env-default:"5s"`
+		// AlertsKept is how many recent alerts are kept for the view and /alerts.
+		AlertsKept int `yaml:"alerts_kept" env-default:"200"`
+	}
+
+	// Sinks publish every processed update to external consumers, a sink is
+	// enabled by setting its address.
+	Sinks struct {
+		// SinkQueueSize is how many updates may wait per sink before new ones
+		// are dropped for it.
+		SinkQueueSize int `yaml:"sink_queue_size" env-default:"1000"`
+		// SinkWebhookURL receives every update as a JSON POST, signed with
+		// SinkWebhookSecret when it is set.
+		SinkWebhookURL     string        `yaml:"sink_webhook_url" env:"SINK_WEBHOOK_URL"`
+		SinkWebhookSecret  string        `yaml:"sink_webhook_secret" env:"SINK_WEBHOOK_SECRET"`
+		SinkWebhookTimeout time.Duration `yaml:"sink_webhook_timeout" env-default:"5s"`
+		SinkWebhookRetries int           `yaml:"sink_webhook_retries" env-default:"3"`
+		SinkWebhookBackoff time.Duration `yaml:"sink_webhook_backoff" env-default:"500ms"`
+		// SinkNATSSubject is the subject prefix, updates are published on
+		// <prefix>.<sport>.<operator>.
+		SinkNATSURL     string `yaml:"sink_nats_url" env:"SINK_NATS_URL"`
+		SinkNATSSubject string `yaml:"sink_nats_subject" env-default:"odds"`
+		// SinkKafkaBrokers are host:port addresses, updates are keyed by event.
+		SinkKafkaBrokers []string `yaml:"sink_kafka_brokers" env:"SINK_KAFKA_BROKERS" env-separator:","`
+		SinkKafkaTopic   string   `yaml:"sink_kafka_topic" env-default:"odds"`
+		// SinkRedisURL is a redis:// URL, updates are appended to
+		// SinkRedisStream trimmed to about SinkRedisMaxLen entries.
+		SinkRedisURL    string `yaml:"sink_redis_url" env:"SINK_REDIS_URL"`
+		SinkRedisStream string `yaml:"sink_redis_stream" env-default:"odds"`
+		SinkRedisMaxLen int64  `yaml:"sink_redis_max_len" env-default:"100000"`
+	}
+
+	// GRPC serves the odds API on websocket_host next to the websocket.
+	GRPC struct {
+		GRPCEnabled bool `yaml:"grpc_enabled"`
+		GRPCPort    int  `yaml:"grpc_port" env-default:"6004"`
+		// GRPCSubscriberBuffer is how many updates a subscription may fall
+		// behind before it is ended as too slow.
+		GRPCSubscriberBuffer int `yaml:"grpc_subscriber_buffer" env-default:"256"`
+	}
+
+	// Auth protects /ws and the REST endpoints with API keys or JWTs.
+	Auth struct {
+		AuthEnabled bool `yaml:"auth_enabled"`
+		// AuthKeysFile holds the issued API keys, api_keys.json in
+		// path_to_data when empty.
+		AuthKeysFile string `yaml:"auth_keys_file"`
+		// AuthJWTSecret verifies HS256 JWTs, none are accepted when it is empty.
+		AuthJWTSecret string `yaml:"auth_jwt_secret" env:"AUTH_JWT_SECRET"`
+		// AuthAdminToken guards the /admin/ API, which is off when it is empty.
+		AuthAdminToken string `yaml:"auth_admin_token" env:"AUTH_ADMIN_TOKEN"`
+		// AuthAllowedOrigins are the origins browsers may open /ws from, only
+		// the server's own origin is allowed when it is empty.
+		AuthAllowedOrigins []string `yaml:"auth_allowed_origins"`
+		// AuthMaxConnections and AuthMessagesPerMinute apply to the keys and
+		// tokens that do not set their own limits.
+		AuthMaxConnections    int `yaml:"auth_max_connections" env-default:"5"`
+		AuthMessagesPerMinute int `yaml:"auth_messages_per_minute" env-default:"120"`
+	}
+
+	// SportMode is a sport and what to poll of it: Live or PreMatch matches,
+	// or the Outright markets of its competitions.
 	SportMode struct {
 		Sport string `yaml:"sport"`
 		Mode  string `yaml:"mode"`
 	}
 )
 
+// Targets returns the offerings to poll with every unset field filled from
+// the top level unibet settings.
+func (u Unibet) Targets() []Offering {
+	if len(u.Offerings) == 0 {
+		return []Offering{u.defaultOffering()}
+	}
+
+	targets := make([]Offering, len(u.Offerings))
+	for i, o := range u.Offerings {
+		d := u.defaultOffering()
+		if o.Operator == "" {
+			o.Operator = d.Operator
+		}
+		if o.CountryCode == "" {
+			o.CountryCode = d.CountryCode
+		}
+		if o.Lang == "" {
+			o.Lang = d.Lang
+		}
+		if o.Market == "" {
+			o.Market = d.Market
+		}
+		if len(o.SportsToParse) == 0 {
+			o.SportsToParse = d.SportsToParse
+		}
+		if o.LiveUpdateInterval == 0 {
+			o.LiveUpdateInterval = d.LiveUpdateInterval
+		}
+		if o.PrematchUpdateInterval == 0 {
+			o.PrematchUpdateInterval = d.PrematchUpdateInterval
+		}
+		if o.OutrightUpdateInterval == 0 {
+			o.OutrightUpdateInterval = d.OutrightUpdateInterval
+		}
+		targets[i] = o
+	}
+	return targets
+}
+
+func (u Unibet) defaultOffering() Offering {
+	return Offering{
+		Operator:               u.APICountryCode,
+		CountryCode:            u.CountryCode,
+		Lang:                   u.Lang,
+		Market:                 u.Market,
+		SportsToParse:          u.SportsToParse,
+		LiveUpdateInterval:     u.LiveUpdateInterval,
+		PrematchUpdateInterval: u.PrematchUpdateInterval,
+		OutrightUpdateInterval: u.OutrightUpdateInterval,
+	}
+}
+
 // Reads the configuration from the specified path.
 func NewConfig() Config {
 
@@ -58,19 +296,49 @@ func NewConfig() Config {
 	return MustLoadPath(configPath)
 }
 
+// Load reads and validates the configuration from the path given by the
+// -config flag or CONFIG_PATH, and returns that path for later reloads.
+func Load() (Config, string, error) {
+	configPath := fetchConfigPath()
+	if configPath == "" {
+		return Config{}, "", errors.New("config path is empty: pass -config or set CONFIG_PATH")
+	}
+
+	cfg, err := LoadPath(configPath)
+	return cfg, configPath, err
+}
+
 func MustLoadPath(configPath string) Config {
+	cfg, err := LoadPath(configPath)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return cfg
+}
+
+// LoadPath reads and validates the configuration at configPath.
+func LoadPath(configPath string) (Config, error) {
 	// check if file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		panic("config file does not exist: " + configPath)
+		return Config{}, fmt.Errorf("config file does not exist: %s", configPath)
 	}
 
 	var cfg Config
 
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
-		panic("cannot read config: " + err.Error())
+		return Config{}, fmt.Errorf("cannot read config %s: %w", configPath, err)
 	}
 
-	return cfg
+	if err := resolveSecrets(&cfg); err != nil {
+		return Config{}, fmt.Errorf("cannot resolve secrets in %s:\n%w", configPath, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config %s:\n%s", configPath, Redact(err.Error()))
+	}
+
+	return cfg, nil
 }
 
 func fetchConfigPath() string {
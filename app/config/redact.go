@@ -0,0 +1,56 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+)
+
+const redacted = "REDACTED"
+
+var dsnPassword = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+
+// Redacted returns a copy of the config that is safe to show: credentials in
+// proxy, sink and SQL URLs are masked, as are the webhook, JWT and admin
+// secrets and every resolved secret.
+func (c Config) Redacted() Config {
+	proxies := make([]string, len(c.Proxies))
+	for i, proxy := range c.Proxies {
+		proxies[i] = RedactURL(proxy)
+	}
+	c.Proxies = proxies
+	c.SQLDSN = RedactURL(c.SQLDSN)
+	c.AlertWebhookURL = RedactURL(c.AlertWebhookURL)
+	c.SinkWebhookURL = RedactURL(c.SinkWebhookURL)
+	c.SinkNATSURL = RedactURL(c.SinkNATSURL)
+	c.SinkRedisURL = RedactURL(c.SinkRedisURL)
+	if c.SinkWebhookSecret != "" {
+		c.SinkWebhookSecret = redacted
+	}
+	if c.AuthJWTSecret != "" {
+		c.AuthJWTSecret = redacted
+	}
+	if c.AuthAdminToken != "" {
+		c.AuthAdminToken = redacted
+	}
+	redactStrings(&c)
+	return c
+}
+
+// RedactURL masks the user info of a URL, or the password of a key=value DSN.
+func RedactURL(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return dsnPassword.ReplaceAllString(raw, "${1}"+redacted)
+	}
+	if u.User != nil {
+		u.User = url.UserPassword(redacted, redacted)
+	}
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", redacted)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
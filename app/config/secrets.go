@@ -0,0 +1,298 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// SecretsKeyEnv holds the passphrase of the encrypted secrets file.
+const SecretsKeyEnv = "SECRETS_KEY"
+
+// secretRef matches ${env:NAME}, ${file:/path} and ${secret:name} inside any
+// string setting.
+var secretRef = regexp.MustCompile(`\$\{(env|file|secret):([^}]+)\}`)
+
+// optionalTag, as secret:"optional", marks settings that may be left empty: a
+// reference in them that cannot be resolved empties the setting, or drops
+// the list entry, instead of failing the load.
+const optionalTag = "optional"
+
+// Parameters of the scrypt key derivation of the secrets file.
+const (
+	saltSize = 16
+	scryptN  = 1 << 15
+	scryptR  = 8
+	scryptP  = 1
+)
+
+// knownSecrets collects every resolved secret value so it can be masked in
+// logs, errors and the config endpoint.
+var knownSecrets = struct {
+	sync.RWMutex
+	values map[string]struct{}
+}{values: make(map[string]struct{})}
+
+func rememberSecret(value string) {
+	// Very short values would mask unrelated text all over the logs.
+	if len(value) < 4 {
+		return
+	}
+	knownSecrets.Lock()
+	knownSecrets.values[value] = struct{}{}
+	knownSecrets.Unlock()
+}
+
+// Redact masks every known secret value in s.
+func Redact(s string) string {
+	knownSecrets.RLock()
+	defer knownSecrets.RUnlock()
+
+	if len(knownSecrets.values) == 0 {
+		return s
+	}
+	// Longest first, so a secret containing another one is masked whole.
+	values := make([]string, 0, len(knownSecrets.values))
+	for value := range knownSecrets.values {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		s = strings.ReplaceAll(s, value, redacted)
+	}
+	return s
+}
+
+// RedactingWriter masks known secrets in everything written through it, it
+// is meant to wrap log outputs.
+type RedactingWriter struct {
+	W io.Writer
+}
+
+func (rw RedactingWriter) Write(p []byte) (int, error) {
+	if _, err := rw.W.Write([]byte(Redact(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// resolveSecrets replaces secret references in every string of cfg. Settings
+// tagged optional whose references cannot be resolved are emptied with a
+// warning.
+func resolveSecrets(cfg *Config) error {
+	var secrets map[string]string
+	var errs []error
+
+	transformStrings(reflect.ValueOf(cfg).Elem(), "", false, func(path string, optional bool, value string) string {
+		failed := len(errs)
+		resolved := secretRef.ReplaceAllStringFunc(value, func(ref string) string {
+			parts := secretRef.FindStringSubmatch(ref)
+			source, name := parts[1], strings.TrimSpace(parts[2])
+
+			var resolved string
+			switch source {
+			case "env":
+				v, ok := os.LookupEnv(name)
+				if !ok || (optional && v == "") {
+					errs = append(errs, fmt.Errorf("%s: environment variable %s is not set", path, name))
+					return ref
+				}
+				resolved = v
+			case "file":
+				data, err := os.ReadFile(name)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: cannot read secret file: %w", path, err))
+					return ref
+				}
+				resolved = strings.TrimRight(string(data), "\r\n")
+			case "secret":
+				if secrets == nil {
+					loaded, err := ReadSecretsFile(cfg.SecretsFile, os.Getenv(SecretsKeyEnv))
+					if err != nil {
+						errs = append(errs, fmt.Errorf("%s: %w", path, err))
+						return ref
+					}
+					secrets = loaded
+				}
+				v, ok := secrets[name]
+				if !ok {
+					errs = append(errs, fmt.Errorf("%s: secret %q is not in %s", path, name, cfg.SecretsFile))
+					return ref
+				}
+				resolved = v
+			}
+			rememberSecret(resolved)
+			return resolved
+		})
+		if optional && len(errs) > failed {
+			log.Printf("Leaving optional setting empty: %v", errs[failed])
+			errs = errs[:failed]
+			return ""
+		}
+		return resolved
+	})
+	return errors.Join(errs...)
+}
+
+// redactStrings masks known secrets in every string of cfg.
+func redactStrings(cfg *Config) {
+	transformStrings(reflect.ValueOf(cfg).Elem(), "", false, func(_ string, _ bool, value string) string {
+		return Redact(value)
+	})
+}
+
+// transformStrings applies fn to every string reachable from v, cloning
+// slices and maps on the way so the caller's copy never shares them. Fields
+// tagged optional, and everything below them, are passed to fn as optional,
+// and their list entries fn empties are dropped.
+func transformStrings(v reflect.Value, path string, optional bool, fn func(path string, optional bool, value string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(fn(path, optional, v.String()))
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" {
+				name = field.Name
+			}
+			fieldOptional := optional || field.Tag.Get("secret") == optionalTag
+			transformStrings(v.Field(i), joinPath(path, name), fieldOptional, fn)
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		clone := reflect.MakeSlice(v.Type(), 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.Index(i))
+			transformStrings(value, fmt.Sprintf("%s[%d]", path, i), optional, fn)
+			if optional && value.Kind() == reflect.String && value.String() == "" && v.Index(i).String() != "" {
+				continue
+			}
+			clone = reflect.Append(clone, value)
+		}
+		v.Set(clone)
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		clone := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+			transformStrings(value, joinPath(path, fmt.Sprint(iter.Key().Interface())), optional, fn)
+			clone.SetMapIndex(iter.Key(), value)
+		}
+		v.Set(clone)
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// ReadSecretsFile decrypts the local secrets file, a base64 encoded
+// AES-256-GCM sealed JSON object of name to value. The key is derived from
+// passphrase with scrypt, the random salt precedes the nonce in the file.
+func ReadSecretsFile(path, passphrase string) (map[string]string, error) {
+	if path == "" {
+		return nil, errors.New("secrets_file is not configured")
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("%s is not set, cannot decrypt %s", SecretsKeyEnv, path)
+	}
+
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read secrets file: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("secrets file %s is not base64: %w", path, err)
+	}
+
+	if len(sealed) < saltSize {
+		return nil, fmt.Errorf("secrets file %s is truncated", path)
+	}
+	salt, sealed := sealed[:saltSize], sealed[saltSize:]
+	gcm, err := secretsCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("secrets file %s is truncated", path)
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt %s, wrong %s?", path, SecretsKeyEnv)
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("secrets file %s is not a JSON object: %w", path, err)
+	}
+	return secrets, nil
+}
+
+// WriteSecretsFile encrypts secrets into path, see ReadSecretsFile.
+func WriteSecretsFile(path, passphrase string, secrets map[string]string) error {
+	if passphrase == "" {
+		return fmt.Errorf("%s is not set", SecretsKeyEnv)
+	}
+
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	gcm, err := secretsCipher(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	sealed := gcm.Seal(append(salt, nonce...), nonce, plain, nil)
+	return os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(sealed)+"\n"), 0600)
+}
+
+func secretsCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,275 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	modes        = map[string]struct{}{"Live": {}, "PreMatch": {}, "Outright": {}}
+	compressions = map[string]struct{}{"": {}, "none": {}, "gzip": {}, "zstd": {}}
+	backends     = map[string]struct{}{"": {}, "file": {}, "sqlite": {}, "sql": {}}
+	actions      = map[string]struct{}{"include": {}, "exclude": {}}
+	// operatorCode keeps operator codes safe to use in storage keys.
+	operatorCode = regexp.MustCompile(`^[a-z0-9]+$`)
+)
+
+// Validate checks the whole config and reports every problem at once, each
+// prefixed with the YAML path of the offending setting.
+func (c Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Websocket.Port <= 0 || c.Websocket.Port > 65535 {
+		add("websocket.websocket_port: %d is not a valid port", c.Websocket.Port)
+	}
+	if c.WebsocketCompression && (c.WebsocketCompressionLevel < -2 || c.WebsocketCompressionLevel > 9) {
+		add("websocket.websocket_compression_level: %d is not between -2 and 9", c.WebsocketCompressionLevel)
+	}
+	if c.WebsocketSendBuffer <= 0 {
+		add("websocket.websocket_send_buffer: must be positive")
+	}
+	if c.WebsocketWriteTimeout <= 0 {
+		add("websocket.websocket_write_timeout: must be positive")
+	}
+	if c.WebsocketPingInterval <= 0 {
+		add("websocket.websocket_ping_interval: must be positive")
+	}
+	if c.WebsocketPongTimeout <= c.WebsocketPingInterval {
+		add("websocket.websocket_pong_timeout: must be longer than websocket_ping_interval")
+	}
+	if c.WebsocketMaxMessageSize <= 0 {
+		add("websocket.websocket_max_message_size: must be positive")
+	}
+	if c.WebsocketReplayBuffer < 0 {
+		add("websocket.websocket_replay_buffer: must not be negative")
+	}
+	if c.WebsocketResumeWait < 0 || c.WebsocketResumeWait >= c.WebsocketPongTimeout {
+		add("websocket.websocket_resume_wait: must be between 0 and websocket_pong_timeout")
+	}
+
+	if u, err := url.Parse(c.UnibetAPIBase); err != nil || u.Scheme == "" || u.Host == "" {
+		add("unibet.unibet_api_base: %q is not an absolute URL", c.UnibetAPIBase)
+	}
+	for i, proxy := range c.Proxies {
+		if u, err := url.Parse(proxy); err != nil || u.Host == "" {
+			add("unibet.proxies[%d]: not a valid proxy URL", i)
+		}
+	}
+	if c.MatchesPerBatch <= 0 {
+		add("unibet.matches_per_batch: must be positive")
+	}
+
+	// Offerings inherit unset fields from the top level, so the effective
+	// targets are checked and reported under the setting that was missed.
+	prefix := "unibet"
+	operators := make(map[string]struct{})
+	for i, o := range c.Targets() {
+		if len(c.Offerings) > 0 {
+			prefix = fmt.Sprintf("unibet.offerings[%d]", i)
+		}
+		if o.Operator == "" {
+			if len(c.Offerings) > 0 {
+				add("%s.operator: must be set", prefix)
+			} else {
+				add("unibet.api_country_code: must be set")
+			}
+		} else if !operatorCode.MatchString(o.Operator) {
+			add("%s.operator: %q must be lowercase letters and digits", prefix, o.Operator)
+		} else if _, ok := operators[o.Operator]; ok {
+			add("%s.operator: %s is listed twice", prefix, o.Operator)
+		}
+		operators[o.Operator] = struct{}{}
+		if o.Lang == "" {
+			add("%s.lang: must be set", prefix)
+		}
+		if o.Market == "" {
+			add("%s.market: must be set", prefix)
+		}
+		if o.LiveUpdateInterval <= 0 {
+			add("%s.live_update_interval: must be positive", prefix)
+		}
+		if o.PrematchUpdateInterval <= 0 {
+			add("%s.prematch_update_interval: must be positive", prefix)
+		}
+		if o.OutrightUpdateInterval <= 0 {
+			add("%s.outright_update_interval: must be positive", prefix)
+		}
+		if len(o.SportsToParse) == 0 {
+			add("%s.sports_to_parse: at least one sport is required", prefix)
+		}
+		seen := make(map[SportMode]struct{})
+		for j, sm := range o.SportsToParse {
+			if sm.Sport == "" {
+				add("%s.sports_to_parse[%d].sport: must be set", prefix, j)
+			}
+			if _, ok := modes[sm.Mode]; !ok {
+				add("%s.sports_to_parse[%d].mode: %q must be Live, PreMatch or Outright", prefix, j, sm.Mode)
+			}
+			if _, ok := seen[sm]; ok {
+				add("%s.sports_to_parse[%d]: %s %s is listed twice", prefix, j, sm.Sport, sm.Mode)
+			}
+			seen[sm] = struct{}{}
+		}
+	}
+	for name, raw := range map[string]string{
+		"raw_url_fetch_match":         c.RawURLfetchMatch,
+		"raw_url_get_matches_is_live": c.RawURLgetMatchesIsLive,
+		"raw_url_get_matches":         c.RawURLgetMatches,
+		"raw_url_get_outrights":       c.RawURLgetOutrights,
+	} {
+		if !strings.Contains(raw, "%") {
+			add("unibet.%s: must be a format string", name)
+		}
+	}
+
+	if c.Timeout <= 0 {
+		add("timeout_on_external_service: must be positive")
+	}
+	if c.PathToData == "" {
+		add("path_to_data: must be set")
+	}
+
+	if c.RetentionEnabled && c.RetentionCheckInterval <= 0 {
+		add("retention.retention_check_interval: must be positive")
+	}
+	if _, ok := compressions[c.Compression]; !ok {
+		add("retention.compression: %q must be gzip, zstd or none", c.Compression)
+	}
+	if c.RotateMaxFileSizeMB < 0 || c.DiskQuotaMB < 0 || c.MaxAge < 0 {
+		add("retention: sizes and ages must not be negative")
+	}
+
+	if _, ok := backends[c.StorageBackend]; !ok {
+		add("storage.storage_backend: %q must be file, sqlite or sql", c.StorageBackend)
+	}
+	if c.StorageBackend == "sql" && c.SQLDSN == "" {
+		add("storage.sql_dsn: must be set for the sql backend")
+	}
+	if c.WriterQueueSize <= 0 {
+		add("storage.writer.writer_queue_size: must be positive")
+	}
+	if c.WriterFlushInterval <= 0 {
+		add("storage.writer.writer_flush_interval: must be positive")
+	}
+
+	if _, ok := actions[strings.ToLower(c.DefaultAction)]; !ok && c.DefaultAction != "" {
+		add("filters.default_action: %q must be include or exclude", c.DefaultAction)
+	}
+	for mode := range c.StartWindow {
+		if _, ok := modes[mode]; !ok {
+			add("filters.start_window.%s: unknown mode", mode)
+		}
+	}
+	for i, rule := range c.Rules {
+		if _, ok := actions[strings.ToLower(rule.Action)]; !ok {
+			add("filters.rules[%d].action: %q must be include or exclude", i, rule.Action)
+		}
+		if _, err := regexp.Compile(rule.ParticipantRegex); err != nil {
+			add("filters.rules[%d].participant_regex: %v", i, err)
+		}
+	}
+
+	if c.AlertsEnabled {
+		if c.AlertMovePercent <= 0 {
+			add("alerts.alert_move_percent: must be positive")
+		}
+		if c.AlertWindow <= 0 {
+			add("alerts.alert_window: must be positive")
+		}
+		if c.AlertSteamLines < 2 {
+			add("alerts.alert_steam_lines: must be at least 2")
+		}
+		if c.AlertConsensusPercent < 0 {
+			add("alerts.alert_consensus_percent: must not be negative")
+		}
+		if c.AlertWebhookURL != "" {
+			if u, err := url.Parse(c.AlertWebhookURL); err != nil || u.Scheme == "" || u.Host == "" {
+				add("alerts.alert_webhook_url: not an absolute URL")
+			}
+		}
+		if c.AlertWebhookTimeout <= 0 {
+			add("alerts.alert_webhook_timeout: must be positive")
+		}
+		if c.AlertsKept <= 0 {
+			add("alerts.alerts_kept: must be positive")
+		}
+	}
+
+	if c.SinkQueueSize <= 0 {
+		add("sinks.sink_queue_size: must be positive")
+	}
+	if c.SinkWebhookURL != "" {
+		if u, err := url.Parse(c.SinkWebhookURL); err != nil || u.Scheme == "" || u.Host == "" {
+			add("sinks.sink_webhook_url: not an absolute URL")
+		}
+		if c.SinkWebhookTimeout <= 0 {
+			add("sinks.sink_webhook_timeout: must be positive")
+		}
+		if c.SinkWebhookRetries < 0 {
+			add("sinks.sink_webhook_retries: must not be negative")
+		}
+		if c.SinkWebhookBackoff <= 0 {
+			add("sinks.sink_webhook_backoff: must be positive")
+		}
+	}
+	if c.SinkNATSURL != "" && c.SinkNATSSubject == "" {
+		add("sinks.sink_nats_subject: must not be empty")
+	}
+	if len(c.SinkKafkaBrokers) > 0 && c.SinkKafkaTopic == "" {
+		add("sinks.sink_kafka_topic: must not be empty")
+	}
+	if c.SinkRedisURL != "" {
+		if u, err := url.Parse(c.SinkRedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+			add("sinks.sink_redis_url: not a redis:// URL")
+		}
+		if c.SinkRedisStream == "" {
+			add("sinks.sink_redis_stream: must not be empty")
+		}
+	}
+
+	if c.GRPCEnabled {
+		if c.GRPCPort <= 0 || c.GRPCPort > 65535 {
+			add("grpc.grpc_port: %d is not a valid port", c.GRPCPort)
+		} else if c.GRPCPort == c.Websocket.Port {
+			add("grpc.grpc_port: %d is already the websocket port", c.GRPCPort)
+		}
+		if c.GRPCSubscriberBuffer <= 0 {
+			add("grpc.grpc_subscriber_buffer: must be positive")
+		}
+	}
+
+	if c.AuthEnabled {
+		if c.AuthJWTSecret != "" && len(c.AuthJWTSecret) < 32 {
+			add("auth.auth_jwt_secret: must be at least 32 characters")
+		}
+		if c.AuthMaxConnections <= 0 {
+			add("auth.auth_max_connections: must be positive")
+		}
+		if c.AuthMessagesPerMinute <= 0 {
+			add("auth.auth_messages_per_minute: must be positive")
+		}
+	}
+	if c.AuthAdminToken != "" && len(c.AuthAdminToken) < 16 {
+		add("auth.auth_admin_token: must be at least 16 characters")
+	}
+	for i, origin := range c.AuthAllowedOrigins {
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+			add("auth.auth_allowed_origins[%d]: %q is not an origin such as https://example.com", i, origin)
+		}
+	}
+
+	if c.UnmappedReportInterval <= 0 {
+		add("reports.unmapped_report_interval: must be positive")
+	}
+	if c.UnmappedSamples < 0 {
+		add("reports.unmapped_samples: must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadLocal loads the local config, which must be valid as shipped.
+func loadLocal(t *testing.T) Config {
+	t.Helper()
+
+	cfg, err := LoadPath("local.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestShippedConfigsAreValid(t *testing.T) {
+	for _, path := range []string{"config.yaml", "local.yaml"} {
+		if _, err := LoadPath(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string
+	}{
+		{"valid", func(c *Config) {}, nil},
+		{
+			"websocket",
+			func(c *Config) {
+				c.Websocket.Port = 70000
+				c.WebsocketPongTimeout = c.WebsocketPingInterval
+			},
+			[]string{
+				"websocket.websocket_port: 70000 is not a valid port",
+				"websocket.websocket_pong_timeout: must be longer than websocket_ping_interval",
+			},
+		},
+		{
+			"default offering",
+			func(c *Config) {
+				c.Offerings = nil
+				c.APICountryCode = ""
+				c.SportsToParse = []SportMode{{Sport: "football", Mode: "Live"}, {Sport: "football", Mode: "Live"}, {Mode: "Later"}}
+			},
+			[]string{
+				"unibet.api_country_code: must be set",
+				"unibet.sports_to_parse[1]: football Live is listed twice",
+				"unibet.sports_to_parse[2].sport: must be set",
+				`unibet.sports_to_parse[2].mode: "Later" must be Live, PreMatch or Outright`,
+			},
+		},
+		{
+			"offerings",
+			func(c *Config) {
+				c.Offerings = []Offering{{Operator: "ubbe"}, {Operator: "ubbe"}, {Operator: "UB-DK"}}
+			},
+			[]string{
+				"unibet.offerings[1].operator: ubbe is listed twice",
+				`unibet.offerings[2].operator: "UB-DK" must be lowercase letters and digits`,
+			},
+		},
+		{
+			"storage",
+			func(c *Config) {
+				c.StorageBackend = "sql"
+				c.SQLDSN = ""
+				c.Compression = "lz4"
+			},
+			[]string{
+				"storage.sql_dsn: must be set for the sql backend",
+				`retention.compression: "lz4" must be gzip, zstd or none`,
+			},
+		},
+		{
+			"filters",
+			func(c *Config) {
+				c.StartWindow = map[string]time.Duration{"Soon": time.Hour}
+				c.Rules = []FilterRule{{Action: "drop", ParticipantRegex: "("}}
+			},
+			[]string{
+				"filters.start_window.Soon: unknown mode",
+				`filters.rules[0].action: "drop" must be include or exclude`,
+				"filters.rules[0].participant_regex: error parsing regexp",
+			},
+		},
+		{
+			"sinks and grpc",
+			func(c *Config) {
+				c.SinkRedisURL = "http://localhost:6379"
+				c.GRPCEnabled = true
+				c.Websocket.Port, c.GRPCPort = 6003, 6003
+			},
+			[]string{
+				"sinks.sink_redis_url: not a redis:// URL",
+				"grpc.grpc_port: 6003 is already the websocket port",
+			},
+		},
+		{
+			"auth",
+			func(c *Config) {
+				c.AuthEnabled = true
+				c.AuthJWTSecret = "short"
+				c.AuthAdminToken = "short"
+				c.AuthAllowedOrigins = []string{"example.com"}
+			},
+			[]string{
+				"auth.auth_jwt_secret: must be at least 32 characters",
+				"auth.auth_admin_token: must be at least 16 characters",
+				`auth.auth_allowed_origins[0]: "example.com" is not an origin`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadLocal(t)
+			tt.change(&cfg)
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate() accepted an invalid config")
+			}
+			// Every problem is reported, one per line.
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.want) {
+				t.Errorf("got %d errors, want %d:\n%v", len(lines), len(tt.want), err)
+			}
+			for _, want := range tt.want {
+				found := false
+				for _, line := range lines {
+					found = found || strings.HasPrefix(line, want)
+				}
+				if !found {
+					t.Errorf("no error starting with %q in:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadPathRejectsInvalidConfig(t *testing.T) {
+	data, err := os.ReadFile("local.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := strings.Replace(string(data), "path_to_data:", "path_to_data: \"\" #", 1)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(invalid), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadPath(path)
+	if err == nil || !strings.Contains(err.Error(), "path_to_data: must be set") {
+		t.Errorf("LoadPath() = %v, want the path_to_data error", err)
+	}
+	if _, err := LoadPath(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadPath() of a missing file succeeded")
+	}
+}
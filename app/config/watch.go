@@ -0,0 +1,61 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// settleDelay groups the bursts of events editors and config management
+// tools produce when replacing a file into a single reload.
+const settleDelay = 500 * time.Millisecond
+
+// Watch notifies on the returned channel whenever the file at path changes.
+// The directory is watched rather than the file so that atomic replaces
+// (write to temp file, rename) and Kubernetes ConfigMap symlink swaps are seen.
+func Watch(ctx context.Context, path string) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	name := filepath.Clean(path)
+
+	go func() {
+		defer watcher.Close()
+
+		var settle <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == name || filepath.Base(event.Name) == "..data" {
+					settle = time.After(settleDelay)
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			case <-settle:
+				settle = nil
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
@@ -0,0 +1,273 @@
+// Package discovery collects statistics about the bet offers no canonical
+// market could be found for, so the markets worth supporting next can be
+// picked from what the feed actually carries.
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"test_task_app/translation"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReportFileName is the file inside the data directory the report is written to.
+const ReportFileName = "unmapped_markets.json"
+
+const (
+	// maxTrackedEvents caps the event IDs remembered per market, the least
+	// recently seen is forgotten first.
+	maxTrackedEvents = 1000
+	// eventIdleTime is how long an event ID is remembered after it was last
+	// seen, events still in the feed are seen every update.
+	eventIdleTime = 6 * time.Hour
+)
+
+// Observation is one bet offer with outcomes standardization discarded.
+type Observation struct {
+	Sport   string
+	EventID int
+	// Label is the translated criterion label, EnglishLabel the one Kambi sent.
+	Label        string
+	EnglishLabel string
+	HomeName     string
+	AwayName     string
+	Order        []interface{}
+	// OutcomeTypes are the types of the discarded outcomes.
+	OutcomeTypes []string
+	// Partial is set when other outcomes of the offer were mapped.
+	Partial bool
+	Offer   interface{}
+}
+
+// Market aggregates the observations of one sport and masked label.
+type Market struct {
+	Sport        string           `json:"sport"`
+	Label        string           `json:"label"`
+	EnglishLabel string           `json:"english_label"`
+	Offers       int64            `json:"offers"`
+	Partial      int64            `json:"partial"`
+	Events       int              `json:"events"`
+	OutcomeTypes map[string]int64 `json:"outcome_types"`
+	Orders       map[string]int64 `json:"orders"`
+	FirstSeen    int64            `json:"first_seen"`
+	LastSeen     int64            `json:"last_seen"`
+	Samples      []Sample         `json:"samples"`
+}
+
+// Sample is the raw bet offer of one event.
+type Sample struct {
+	EventID int             `json:"event_id"`
+	Offer   json.RawMessage `json:"offer"`
+}
+
+// Collector aggregates observations in memory and writes them out as a report.
+type Collector struct {
+	path       string
+	maxSamples int
+	mu         sync.Mutex
+	markets    map[string]*Market
+	// events holds when the recently seen event IDs of each market were last
+	// seen. An event that was forgotten, or counted before a restart, is
+	// counted again when it comes back.
+	events map[string]map[int]int64
+	now    func() time.Time
+}
+
+// NewCollector loads the previous report from dir so the statistics survive
+// restarts, starting empty if it does not exist yet.
+func NewCollector(dir string, maxSamples int) (*Collector, error) {
+	c := &Collector{
+		path:       filepath.Join(dir, ReportFileName),
+		maxSamples: maxSamples,
+		markets:    make(map[string]*Market),
+		events:     make(map[string]map[int]int64),
+		now:        time.Now,
+	}
+
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var markets []Market
+	if err := json.Unmarshal(data, &markets); err != nil {
+		return nil, err
+	}
+	for i := range markets {
+		key := marketKey(markets[i].Sport, markets[i].Label)
+		c.markets[key] = &markets[i]
+		c.events[key] = make(map[int]int64)
+	}
+	return c, nil
+}
+
+// Record adds an observation to the statistics.
+func (c *Collector) Record(o Observation) {
+	label := translation.Mask(o.Label, o.HomeName, o.AwayName)
+	if label == "" {
+		label = translation.Mask(o.EnglishLabel, o.HomeName, o.AwayName)
+	}
+	order, _ := json.Marshal(o.Order)
+	now := c.now().Unix()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := marketKey(o.Sport, label)
+	m, ok := c.markets[key]
+	if !ok {
+		m = &Market{
+			Sport:        o.Sport,
+			Label:        label,
+			EnglishLabel: translation.Mask(o.EnglishLabel, o.HomeName, o.AwayName),
+			FirstSeen:    now,
+		}
+		c.markets[key] = m
+		c.events[key] = make(map[int]int64)
+	}
+	if m.OutcomeTypes == nil {
+		m.OutcomeTypes = make(map[string]int64)
+	}
+	if m.Orders == nil {
+		m.Orders = make(map[string]int64)
+	}
+
+	m.Offers++
+	if o.Partial {
+		m.Partial++
+	}
+	for _, outcomeType := range o.OutcomeTypes {
+		m.OutcomeTypes[outcomeType]++
+	}
+	m.Orders[string(order)]++
+	m.LastSeen = now
+
+	events := c.events[key]
+	_, seen := events[o.EventID]
+	if !seen && len(events) >= maxTrackedEvents {
+		forgetOldest(events)
+	}
+	events[o.EventID] = now
+	if !seen {
+		m.Events++
+		if len(m.Samples) < c.maxSamples {
+			if offer, err := json.Marshal(o.Offer); err == nil {
+				m.Samples = append(m.Samples, Sample{EventID: o.EventID, Offer: offer})
+			}
+		}
+	}
+}
+
+// Report returns the unmapped markets of sport, or of every sport when it
+// is empty, most frequent first. limit caps the result when positive.
+func (c *Collector) Report(sport string, limit int) []Market {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	markets := make([]Market, 0, len(c.markets))
+	for _, m := range c.markets {
+		if sport != "" && !strings.EqualFold(m.Sport, sport) {
+			continue
+		}
+		markets = append(markets, copyMarket(m))
+	}
+	sort.Slice(markets, func(i, j int) bool {
+		if markets[i].Offers != markets[j].Offers {
+			return markets[i].Offers > markets[j].Offers
+		}
+		return marketKey(markets[i].Sport, markets[i].Label) < marketKey(markets[j].Sport, markets[j].Label)
+	})
+	if limit > 0 && len(markets) > limit {
+		markets = markets[:limit]
+	}
+	return markets
+}
+
+// Save writes the full report to the data directory.
+func (c *Collector) Save() error {
+	data, err := json.MarshalIndent(c.Report("", 0), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Run writes the report every interval until ctx is done.
+func (c *Collector) Run(ctx context.Context, interval time.Duration, log *logrus.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.prune()
+			if err := c.Save(); err != nil {
+				log.Errorf("error saving unmapped markets report: %v", err)
+			}
+		}
+	}
+}
+
+// prune forgets the event IDs not seen for eventIdleTime.
+func (c *Collector) prune() {
+	cutoff := c.now().Add(-eventIdleTime).Unix()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, events := range c.events {
+		for id, seen := range events {
+			if seen < cutoff {
+				delete(events, id)
+			}
+		}
+	}
+}
+
+// forgetOldest removes the least recently seen event ID.
+func forgetOldest(events map[int]int64) {
+	first := true
+	var oldest int
+	var oldestSeen int64
+	for id, seen := range events {
+		if first || seen < oldestSeen {
+			oldest, oldestSeen, first = id, seen, false
+		}
+	}
+	delete(events, oldest)
+}
+
+func copyMarket(m *Market) Market {
+	copied := *m
+	copied.OutcomeTypes = make(map[string]int64, len(m.OutcomeTypes))
+	for k, v := range m.OutcomeTypes {
+		copied.OutcomeTypes[k] = v
+	}
+	copied.Orders = make(map[string]int64, len(m.Orders))
+	for k, v := range m.Orders {
+		copied.Orders[k] = v
+	}
+	copied.Samples = append([]Sample(nil), m.Samples...)
+	return copied
+}
+
+func marketKey(sport, label string) string {
+	return sport + "\x00" + label
+}
@@ -0,0 +1,45 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordBoundsEvents(t *testing.T) {
+	c, err := NewCollector(t.TempDir(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1760000000, 0)
+	c.now = func() time.Time { return now }
+	record := func(id int) {
+		c.Record(Observation{Sport: "FOOTBALL", EventID: id, Label: "Corners", Offer: map[string]int{"id": id}})
+		now = now.Add(time.Second)
+	}
+
+	for id := 1; id <= maxTrackedEvents+10; id++ {
+		record(id)
+	}
+	record(maxTrackedEvents + 10)
+	m := c.Report("", 0)[0]
+	key := marketKey(m.Sport, m.Label)
+	if got := len(c.events[key]); got != maxTrackedEvents {
+		t.Errorf("tracked %d events, want %d", got, maxTrackedEvents)
+	}
+	if m.Events != maxTrackedEvents+10 || m.Offers != maxTrackedEvents+11 || len(m.Samples) != 3 {
+		t.Errorf("events %d, offers %d, samples %d", m.Events, m.Offers, len(m.Samples))
+	}
+
+	// The least recently seen events were forgotten and count again.
+	record(1)
+	if m := c.Report("", 0)[0]; m.Events != maxTrackedEvents+11 {
+		t.Errorf("events %d after a forgotten event came back, want %d", m.Events, maxTrackedEvents+11)
+	}
+
+	now = now.Add(eventIdleTime)
+	record(2)
+	c.prune()
+	if got := len(c.events[key]); got != 1 {
+		t.Errorf("tracked %d events after pruning, want 1", got)
+	}
+}
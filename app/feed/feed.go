@@ -0,0 +1,158 @@
+// Package feed keeps the latest snapshot of every event and fans the updates
+// out to in-process subscribers, such as the gRPC API.
+package feed
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"test_task_app/helper"
+)
+
+// ErrSlowSubscriber ends a subscription that fell a whole buffer behind.
+var ErrSlowSubscriber = errors.New("subscriber too slow, updates were dropped")
+
+type entry struct {
+	data    helper.ProcessedData
+	updated time.Time
+}
+
+// Hub holds the latest snapshot per storage key.
+type Hub struct {
+	mu     sync.RWMutex
+	latest map[string]entry
+	subs   map[*Subscription]struct{}
+	now    func() time.Time
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		latest: make(map[string]entry),
+		subs:   make(map[*Subscription]struct{}),
+		now:    time.Now,
+	}
+}
+
+// Subscription receives every update published after it was created. C is
+// closed when the subscription ends, Err tells why.
+type Subscription struct {
+	C   <-chan helper.ProcessedData
+	c   chan helper.ProcessedData
+	hub *Hub
+	err error
+}
+
+// Publish stores the update as the latest snapshot of its event and hands it
+// to every subscriber. A subscriber whose buffer is full is dropped rather
+// than delaying the others.
+func (h *Hub) Publish(data helper.ProcessedData) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.latest[data.StorageKey()] = entry{data: data, updated: h.now()}
+	for s := range h.subs {
+		select {
+		case s.c <- data:
+		default:
+			h.end(s, ErrSlowSubscriber)
+		}
+	}
+}
+
+// Get returns the latest snapshot of the event with the given storage key.
+func (h *Hub) Get(key string) (helper.ProcessedData, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	e, ok := h.latest[key]
+	return e.data, ok
+}
+
+// Snapshot returns the latest snapshot of every event, ordered by key.
+func (h *Hub) Snapshot() []helper.ProcessedData {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.snapshot()
+}
+
+func (h *Hub) snapshot() []helper.ProcessedData {
+	keys := make([]string, 0, len(h.latest))
+	for key := range h.latest {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	snapshot := make([]helper.ProcessedData, 0, len(keys))
+	for _, key := range keys {
+		snapshot = append(snapshot, h.latest[key].data)
+	}
+	return snapshot
+}
+
+// Subscribe starts a subscription buffering up to buffer updates. When
+// withSnapshot is set it also returns the current snapshots, taken so that
+// no update is missed or seen twice between them and the subscription.
+func (h *Hub) Subscribe(buffer int, withSnapshot bool) (*Subscription, []helper.ProcessedData) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := make(chan helper.ProcessedData, buffer)
+	s := &Subscription{C: c, c: c, hub: h}
+	h.subs[s] = struct{}{}
+
+	var snapshot []helper.ProcessedData
+	if withSnapshot {
+		snapshot = h.snapshot()
+	}
+	return s, snapshot
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.end(s, nil)
+}
+
+// Err returns why the subscription ended, nil when it was closed.
+func (s *Subscription) Err() error {
+	s.hub.mu.RLock()
+	defer s.hub.mu.RUnlock()
+
+	return s.err
+}
+
+// Subscribers returns how many subscriptions are active.
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subs)
+}
+
+// Prune forgets the events that were not updated for the given duration.
+func (h *Hub) Prune(olderThan time.Duration) {
+	cutoff := h.now().Add(-olderThan)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key, e := range h.latest {
+		if e.updated.Before(cutoff) {
+			delete(h.latest, key)
+		}
+	}
+}
+
+func (h *Hub) end(s *Subscription, err error) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	s.err = err
+	close(s.c)
+}
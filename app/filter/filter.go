@@ -0,0 +1,241 @@
+// Package filter decides which listed events are worth fetching, based on
+// the rules in the filters section of the config.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"test_task_app/competition"
+	"test_task_app/config"
+)
+
+const (
+	ActionInclude = "include"
+	ActionExclude = "exclude"
+
+	modeLive = "Live"
+)
+
+// Candidate is what is known about an event from the list view, before its
+// bet offers are fetched.
+type Candidate struct {
+	ID       int
+	Operator string
+	Sport    string
+	Mode     string
+	League   string
+	Country  string
+	Path     []string
+	HomeName string
+	AwayName string
+	Start    time.Time
+	State    string
+	Markets  int
+}
+
+type rule struct {
+	name        string
+	action      string
+	sports      []string
+	modes       []string
+	operators   []string
+	leagues     []string
+	countries   []string
+	path        []string
+	participant *regexp.Regexp
+}
+
+type Engine struct {
+	defaultAction string
+	startWindow   map[string]time.Duration
+	minMarkets    int
+	liveStates    map[string]struct{}
+	rules         []rule
+	now           func() time.Time
+}
+
+// New compiles the filter config, reporting invalid actions and regexes.
+func New(cfg config.Filters) (*Engine, error) {
+	e := &Engine{
+		defaultAction: strings.ToLower(cfg.DefaultAction),
+		startWindow:   make(map[string]time.Duration),
+		minMarkets:    cfg.MinMarkets,
+		liveStates:    make(map[string]struct{}),
+		now:           time.Now,
+	}
+	if e.defaultAction == "" {
+		e.defaultAction = ActionInclude
+	}
+	if e.defaultAction != ActionInclude && e.defaultAction != ActionExclude {
+		return nil, fmt.Errorf("filters.default_action: unknown action %q", cfg.DefaultAction)
+	}
+
+	for mode, window := range cfg.StartWindow {
+		e.startWindow[strings.ToLower(mode)] = window
+	}
+	for _, state := range cfg.LiveStates {
+		e.liveStates[strings.ToUpper(state)] = struct{}{}
+	}
+
+	for i, r := range cfg.Rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		action := strings.ToLower(r.Action)
+		if action != ActionInclude && action != ActionExclude {
+			return nil, fmt.Errorf("filters.rules[%s]: unknown action %q", name, r.Action)
+		}
+
+		compiled := rule{
+			name:      name,
+			action:    action,
+			sports:    lower(r.Sports),
+			modes:     lower(r.Modes),
+			operators: lower(r.Operators),
+			leagues:   lower(r.Leagues),
+			countries: lower(r.Countries),
+			path:      lower(r.PathContains),
+		}
+		if r.ParticipantRegex != "" {
+			re, err := regexp.Compile(r.ParticipantRegex)
+			if err != nil {
+				return nil, fmt.Errorf("filters.rules[%s]: participant_regex: %w", name, err)
+			}
+			compiled.participant = re
+		}
+		e.rules = append(e.rules, compiled)
+	}
+	return e, nil
+}
+
+// Allow reports whether the event should be fetched and, if not, why.
+func (e *Engine) Allow(c Candidate) (bool, string) {
+	if window, ok := e.startWindow[strings.ToLower(c.Mode)]; ok && window > 0 && !c.Start.IsZero() {
+		if !c.Start.Before(e.now().Add(window)) {
+			return false, "starts outside window"
+		}
+	}
+	if e.minMarkets > 0 && c.Markets < e.minMarkets {
+		return false, fmt.Sprintf("only %d markets", c.Markets)
+	}
+	if c.Mode == modeLive && len(e.liveStates) > 0 {
+		if _, ok := e.liveStates[strings.ToUpper(c.State)]; !ok {
+			return false, fmt.Sprintf("state %s", c.State)
+		}
+	}
+
+	for _, r := range e.rules {
+		if r.matches(c) {
+			return r.action == ActionInclude, "rule " + r.name
+		}
+	}
+	return e.defaultAction == ActionInclude, "default"
+}
+
+func (r rule) matches(c Candidate) bool {
+	if len(r.sports) > 0 && !containsFold(r.sports, c.Sport) {
+		return false
+	}
+	if len(r.modes) > 0 && !containsFold(r.modes, c.Mode) {
+		return false
+	}
+	if len(r.operators) > 0 && !containsFold(r.operators, c.Operator) {
+		return false
+	}
+	if len(r.leagues) > 0 && !containsFold(r.leagues, c.League) {
+		return false
+	}
+	if len(r.countries) > 0 && !containsFold(r.countries, c.Country) {
+		return false
+	}
+	if len(r.path) > 0 {
+		joined := strings.ToLower(strings.Join(c.Path, "/"))
+		found := false
+		for _, part := range r.path {
+			if strings.Contains(joined, part) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.participant != nil && !r.participant.MatchString(c.HomeName) && !r.participant.MatchString(c.AwayName) {
+		return false
+	}
+	return true
+}
+
+// FromListEvent builds a candidate from one entry of a Kambi listView
+// response of the given operator.
+func FromListEvent(entry map[string]interface{}, operator, mode string) Candidate {
+	eventData, _ := entry["event"].(map[string]interface{})
+	c := Candidate{Operator: operator, Mode: mode}
+	if eventData == nil {
+		return c
+	}
+
+	if id, ok := eventData["id"].(float64); ok {
+		c.ID = int(id)
+	}
+	c.Sport, _ = eventData["sport"].(string)
+	c.HomeName, _ = eventData["homeName"].(string)
+	c.AwayName, _ = eventData["awayName"].(string)
+	c.State, _ = eventData["state"].(string)
+	c.League, _ = eventData["group"].(string)
+	if start, ok := eventData["start"].(string); ok {
+		c.Start, _ = time.Parse(time.RFC3339, start)
+	}
+
+	var path []map[string]interface{}
+	if nodes, ok := eventData["path"].([]interface{}); ok {
+		for _, node := range nodes {
+			if m, ok := node.(map[string]interface{}); ok {
+				path = append(path, m)
+			}
+		}
+	}
+	comp := competition.Classify(path)
+	c.Path = comp.Path
+	c.Country = comp.Country
+	if c.League == "" {
+		c.League = comp.Tournament
+	}
+
+	// The list view carries only the main offers, the counters cover the rest.
+	c.Markets = countOf(eventData["nonLiveBoCount"]) + countOf(eventData["liveBoCount"])
+	if offers, ok := entry["betOffers"].([]interface{}); ok && len(offers) > c.Markets {
+		c.Markets = len(offers)
+	}
+	return c
+}
+
+func countOf(value interface{}) int {
+	if n, ok := value.(float64); ok {
+		return int(n)
+	}
+	return 0
+}
+
+func lower(values []string) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = strings.ToLower(value)
+	}
+	return result
+}
+
+func containsFold(list []string, value string) bool {
+	value = strings.ToLower(value)
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"test_task_app/config"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	premierLeague := Candidate{
+		Operator: "ubbe",
+		Sport:    "FOOTBALL",
+		Mode:     "PreMatch",
+		League:   "Premier League",
+		Country:  "England",
+		Path:     []string{"Football", "England", "Premier League"},
+		HomeName: "Arsenal",
+		AwayName: "Chelsea",
+		Start:    now.Add(time.Hour),
+		Markets:  40,
+	}
+	with := func(change func(c *Candidate)) Candidate {
+		c := premierLeague
+		change(&c)
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		filters   config.Filters
+		candidate Candidate
+		want      bool
+		reason    string
+	}{
+		{"no rules", config.Filters{}, premierLeague, true, "default"},
+		{"default exclude", config.Filters{DefaultAction: "Exclude"}, premierLeague, false, "default"},
+		{
+			"include by league",
+			config.Filters{DefaultAction: "exclude", Rules: []config.FilterRule{{Name: "epl", Action: "include", Leagues: []string{"premier league"}}}},
+			premierLeague, true, "rule epl",
+		},
+		{
+			"exclude by country",
+			config.Filters{Rules: []config.FilterRule{{Action: "exclude", Countries: []string{"ENGLAND"}}}},
+			premierLeague, false, "rule #1",
+		},
+		{
+			"country of another rule",
+			config.Filters{Rules: []config.FilterRule{{Action: "exclude", Countries: []string{"Spain"}}}},
+			premierLeague, true, "default",
+		},
+		{
+			"exclude by path",
+			config.Filters{Rules: []config.FilterRule{{Name: "england", Action: "exclude", PathContains: []string{"football/england"}}}},
+			premierLeague, false, "rule england",
+		},
+		{
+			"every criterion must match",
+			config.Filters{Rules: []config.FilterRule{{Action: "exclude", Countries: []string{"England"}, Modes: []string{"Live"}}}},
+			premierLeague, true, "default",
+		},
+		{
+			"first matching rule decides",
+			config.Filters{Rules: []config.FilterRule{
+				{Name: "arsenal", Action: "include", ParticipantRegex: "^Arsenal$"},
+				{Name: "england", Action: "exclude", Countries: []string{"England"}},
+			}},
+			premierLeague, true, "rule arsenal",
+		},
+		{
+			"participant regex on the away team",
+			config.Filters{Rules: []config.FilterRule{{Name: "esports", Action: "exclude", ParticipantRegex: "(?i)esport"}}},
+			with(func(c *Candidate) { c.AwayName = "Chelsea (Esports)" }), false, "rule esports",
+		},
+		{
+			"participant regex not matching",
+			config.Filters{Rules: []config.FilterRule{{Name: "esports", Action: "exclude", ParticipantRegex: "(?i)esport"}}},
+			premierLeague, true, "default",
+		},
+		{
+			"inside the start window",
+			config.Filters{StartWindow: map[string]time.Duration{"PreMatch": 24 * time.Hour}},
+			premierLeague, true, "default",
+		},
+		{
+			"outside the start window",
+			config.Filters{StartWindow: map[string]time.Duration{"prematch": 24 * time.Hour}},
+			with(func(c *Candidate) { c.Start = now.Add(25 * time.Hour) }), false, "starts outside window",
+		},
+		{
+			"start window of another mode",
+			config.Filters{StartWindow: map[string]time.Duration{"Live": time.Hour}},
+			with(func(c *Candidate) { c.Start = now.Add(25 * time.Hour) }), true, "default",
+		},
+		{
+			"start unknown",
+			config.Filters{StartWindow: map[string]time.Duration{"PreMatch": time.Hour}},
+			with(func(c *Candidate) { c.Start = time.Time{} }), true, "default",
+		},
+		{
+			"too few markets",
+			config.Filters{MinMarkets: 50},
+			premierLeague, false, "only 40 markets",
+		},
+		{
+			"live state accepted",
+			config.Filters{LiveStates: []string{"started"}},
+			with(func(c *Candidate) { c.Mode, c.State = "Live", "STARTED" }), true, "default",
+		},
+		{
+			"live state rejected",
+			config.Filters{LiveStates: []string{"STARTED"}},
+			with(func(c *Candidate) { c.Mode, c.State = "Live", "NOT_STARTED" }), false, "state NOT_STARTED",
+		},
+		{
+			"live states only apply to live",
+			config.Filters{LiveStates: []string{"STARTED"}},
+			with(func(c *Candidate) { c.State = "NOT_STARTED" }), true, "default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.now = func() time.Time { return now }
+			got, reason := e.Allow(tt.candidate)
+			if got != tt.want || reason != tt.reason {
+				t.Errorf("Allow() = %v, %q, want %v, %q", got, reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters config.Filters
+	}{
+		{"default action", config.Filters{DefaultAction: "maybe"}},
+		{"rule action", config.Filters{Rules: []config.FilterRule{{Action: "drop"}}}},
+		{"participant regex", config.Filters{Rules: []config.FilterRule{{Action: "exclude", ParticipantRegex: "(esport"}}}},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.filters); err == nil {
+			t.Errorf("%s: New() accepted an invalid config", tt.name)
+		}
+	}
+}
+
+func TestFromListEvent(t *testing.T) {
+	entry := map[string]interface{}{
+		"event": map[string]interface{}{
+			"id":             float64(1020304050),
+			"sport":          "FOOTBALL",
+			"homeName":       "Arsenal",
+			"awayName":       "Chelsea",
+			"state":          "NOT_STARTED",
+			"start":          "2025-10-09T18:00:00Z",
+			"nonLiveBoCount": float64(30),
+			"liveBoCount":    float64(2),
+			"path": []interface{}{
+				map[string]interface{}{"id": float64(1), "englishName": "Football"},
+				map[string]interface{}{"id": float64(2), "englishName": "England"},
+				map[string]interface{}{"id": float64(3), "englishName": "Premier League"},
+			},
+		},
+		"betOffers": []interface{}{map[string]interface{}{}},
+	}
+
+	c := FromListEvent(entry, "ubbe", "PreMatch")
+	if c.ID != 1020304050 || c.Operator != "ubbe" || c.Mode != "PreMatch" || c.Sport != "FOOTBALL" ||
+		c.HomeName != "Arsenal" || c.AwayName != "Chelsea" || c.State != "NOT_STARTED" {
+		t.Errorf("FromListEvent() = %+v", c)
+	}
+	if !c.Start.Equal(time.Date(2025, 10, 9, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf("start %v", c.Start)
+	}
+	if c.League != "Premier League" || c.Country != "England" || len(c.Path) != 3 {
+		t.Errorf("league %q, country %q, path %q", c.League, c.Country, c.Path)
+	}
+	if c.Markets != 32 {
+		t.Errorf("markets %d, want 32", c.Markets)
+	}
+
+	if c := FromListEvent(map[string]interface{}{}, "ubbe", "Live"); c.ID != 0 || c.Mode != "Live" {
+		t.Errorf("FromListEvent() of an entry without event = %+v", c)
+	}
+}
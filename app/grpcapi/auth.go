@@ -0,0 +1,128 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"test_task_app/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// errRevoked ends the streams of a revoked key.
+var errRevoked = errors.New("credentials revoked")
+
+// streams tracks the open streams of every principal so they can be ended
+// when its key is revoked.
+type streams struct {
+	mu      sync.Mutex
+	cancels map[string]map[*context.CancelCauseFunc]struct{}
+}
+
+func (s *streams) add(id string, cancel context.CancelCauseFunc) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancels[id] == nil {
+		s.cancels[id] = make(map[*context.CancelCauseFunc]struct{})
+	}
+	s.cancels[id][&cancel] = struct{}{}
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.cancels[id], &cancel)
+		if len(s.cancels[id]) == 0 {
+			delete(s.cancels, id)
+		}
+	}
+}
+
+// Options returns the server options that authenticate every call the same
+// way as /ws: an API key or JWT in the x-api-key or authorization metadata,
+// counted against the principal's message rate, streams also against its
+// connections.
+func (s *Server) Options() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	}
+}
+
+// Disconnect ends the streams of the principal with the given ID, such as
+// those of a revoked key.
+func (s *Server) Disconnect(id string) {
+	s.streams.mu.Lock()
+	defer s.streams.mu.Unlock()
+
+	for cancel := range s.streams.cancels[id] {
+		(*cancel)(errRevoked)
+	}
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	p, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(auth.NewContext(ctx, p), req)
+}
+
+func (s *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	p, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	release, err := s.auth.Acquire(p)
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancelCause(auth.NewContext(stream.Context(), p))
+	defer cancel(nil)
+	defer s.streams.add(p.ID, cancel)()
+
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticate returns the principal of a call, rate limited like a REST
+// request.
+func (s *Server) authenticate(ctx context.Context) (auth.Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		token = values[0]
+	} else if values := md.Get("authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+		token = strings.TrimSpace(token)
+	}
+
+	p, err := s.auth.AuthenticateToken(token)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoCredentials) {
+			return auth.Principal{}, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return auth.Principal{}, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if !s.auth.Allow(p) {
+		return auth.Principal{}, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return p, nil
+}
+
+// authenticatedStream carries the principal in its context and ends when
+// the principal is disconnected.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
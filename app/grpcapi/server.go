@@ -0,0 +1,116 @@
+// Package grpcapi serves the odds over gRPC: unary lookups of the latest
+// snapshots and server-streaming subscriptions, see oddspb/odds.proto. Calls
+// are authenticated and limited to the principal's entitlements.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"test_task_app/auth"
+	"test_task_app/feed"
+	"test_task_app/oddspb"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements oddspb.OddsServer on top of the feed hub.
+type Server struct {
+	oddspb.UnimplementedOddsServer
+	hub     *feed.Hub
+	auth    *auth.Authenticator
+	buffer  int
+	log     *logrus.Logger
+	streams streams
+}
+
+// New serves the snapshots of hub, each subscription buffering up to buffer
+// updates before it is ended as too slow. The server must be created with
+// Options for calls to be authenticated.
+func New(hub *feed.Hub, authenticator *auth.Authenticator, buffer int, log *logrus.Logger) *Server {
+	return &Server{
+		hub:     hub,
+		auth:    authenticator,
+		buffer:  buffer,
+		log:     log,
+		streams: streams{cancels: make(map[string]map[*context.CancelCauseFunc]struct{})},
+	}
+}
+
+// Register adds the odds service to a gRPC server.
+func (s *Server) Register(server *grpc.Server) {
+	oddspb.RegisterOddsServer(server, s)
+}
+
+func (s *Server) GetEvent(ctx context.Context, req *oddspb.GetEventRequest) (*oddspb.Event, error) {
+	p := auth.FromContext(ctx)
+	if req.Key != "" {
+		data, ok := s.hub.Get(req.Key)
+		if !ok || !p.Allows(data.Sport, data.League) {
+			return nil, status.Errorf(codes.NotFound, "event %s not found", req.Key)
+		}
+		return oddspb.NewEvent(data, req.Filter), nil
+	}
+	if req.EventId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "key or event_id is required")
+	}
+	for _, data := range s.hub.Snapshot() {
+		if int64(data.EventID) == req.EventId && (req.Operator == "" || data.Operator == req.Operator) && p.Allows(data.Sport, data.League) {
+			return oddspb.NewEvent(data, req.Filter), nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "event %d not found", req.EventId)
+}
+
+func (s *Server) ListEvents(ctx context.Context, req *oddspb.ListEventsRequest) (*oddspb.ListEventsResponse, error) {
+	p := auth.FromContext(ctx)
+	resp := &oddspb.ListEventsResponse{}
+	for _, data := range s.hub.Snapshot() {
+		if req.Filter.Matches(data) && p.Allows(data.Sport, data.League) {
+			resp.Events = append(resp.Events, oddspb.NewEvent(data, req.Filter))
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) Subscribe(req *oddspb.SubscribeRequest, stream oddspb.Odds_SubscribeServer) error {
+	p := auth.FromContext(stream.Context())
+	sub, snapshot := s.hub.Subscribe(s.buffer, req.IncludeSnapshot)
+	defer sub.Close()
+
+	for _, data := range snapshot {
+		if !req.Filter.Matches(data) || !p.Allows(data.Sport, data.League) {
+			continue
+		}
+		if err := stream.Send(oddspb.NewEvent(data, req.Filter)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			if errors.Is(context.Cause(stream.Context()), errRevoked) {
+				return status.Error(codes.PermissionDenied, errRevoked.Error())
+			}
+			return nil
+		case data, ok := <-sub.C:
+			if !ok {
+				if err := sub.Err(); err != nil {
+					s.log.Errorf("error streaming to gRPC subscriber: %v", err)
+					return status.Error(codes.ResourceExhausted, err.Error())
+				}
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			if !req.Filter.Matches(data) || !p.Allows(data.Sport, data.League) {
+				continue
+			}
+			if err := stream.Send(oddspb.NewEvent(data, req.Filter)); err != nil {
+				return err
+			}
+		}
+	}
+}
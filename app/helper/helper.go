@@ -1,26 +1,47 @@
 package helper
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"test_task_app/alert"
+	"test_task_app/discovery"
+	"test_task_app/market"
+	"test_task_app/participant"
+	"test_task_app/status"
+	"test_task_app/translation"
 )
 
+// ProviderUnibet is the provider name used in storage keys for Kambi/Unibet events.
+const ProviderUnibet = "unibet"
+
 type Outcome struct {
 	TypeName   string                   `json:"type_name"`
 	Type       string                   `json:"type"`
+	Label      string                   `json:"label,omitempty"`
 	Line       float64                  `json:"line"`
 	Odds       float64                  `json:"odds"`
 	BetOfferID int                      `json:"betOfferId"`
 	ID         int                      `json:"id"`
 	Criterion  map[string]interface{}   `json:"criterion"`
 	Path       []map[string]interface{} `json:"path"`
+	Market     *market.Market           `json:"market,omitempty"`
+	// Participant is the player a player market outcome is about, with
+	// Kambi's ID for them.
+	Participant   string `json:"participant,omitempty"`
+	ParticipantID int    `json:"participantId,omitempty"`
+	// Status is OPEN, SUSPENDED or CLOSED, StatusSince when the outcome
+	// entered it.
+	Status      string `json:"status,omitempty"`
+	StatusSince int64  `json:"status_since,omitempty"`
 }
 
 type Event struct {
-	ID       int                    `json:"id"`
+	ID       int                      `json:"id"`
+	Name     string                   `json:"name"`
 	HomeName string                   `json:"homeName"`
 	AwayName string                   `json:"awayName"`
 	Start    string                   `json:"start"`
@@ -41,27 +62,90 @@ type RawData struct {
 }
 
 type ProcessedData struct {
-	EventID   int     `json:"event_id"`
-	MatchName string    `json:"match_name"`
-	StartTime int64     `json:"start_time"`
-	HomeTeam  string    `json:"home_team"`
-	AwayTeam  string    `json:"away_team"`
-	Sport     string    `json:"sport"`
-	League    string    `json:"league"`
-	Country   string    `json:"country"`
-	Outcomes  []Outcome `json:"outcomes"`
-	Time      int64     `json:"time"`
-	Type      string    `json:"type"`
+	Provider  string `json:"provider"`
+	Operator  string `json:"operator"` // Kambi offering the odds were taken from, e.g. "ubbe"
+	EventID   int    `json:"event_id"`
+	MatchName string `json:"match_name"`
+	StartTime int64  `json:"start_time"`
+	HomeTeam  string `json:"home_team"`
+	AwayTeam  string `json:"away_team"`
+	HomeID    string `json:"home_id"`
+	AwayID    string `json:"away_id"`
+	Sport     string `json:"sport"`
+	League    string `json:"league"`
+	Country   string `json:"country"`
+	// Competition details resolved from the event path.
+	CompetitionID string    `json:"competition_id"`
+	Region        string    `json:"region"`
+	TourLevel     string    `json:"tour_level,omitempty"`
+	Gender        string    `json:"gender"`
+	Outcomes      []Outcome `json:"outcomes"`
+	Time          int64     `json:"time"`
+	Type          string    `json:"type"`
+	// Players lists the participants of the player and outright markets in
+	// Outcomes.
+	Players []Player `json:"players,omitempty"`
+	// Markets is the status of every market seen for the event, markets
+	// taken down since are CLOSED. StatusChanges are the transitions of
+	// markets and outcomes since the previous update.
+	Markets       []status.Market `json:"markets,omitempty"`
+	StatusChanges []status.Change `json:"status_changes,omitempty"`
+	// Alerts are the sharp price movements detected in this update.
+	Alerts []alert.Alert `json:"alerts,omitempty"`
+}
+
+// Player is a participant of player or outright markets, ID is the
+// participant ID the markets carry and ProviderID Kambi's ID for them.
+type Player struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	ProviderID int    `json:"provider_id,omitempty"`
+}
+
+// outcomeStatus returns the status of an outcome, suspended when its offer
+// is, or "" when the feed gives none.
+func outcomeStatus(offer BetOffer, outcome Outcome) string {
+	raw, _ := outcome.Criterion["status"].(string)
+	if raw == "" {
+		raw = outcome.Status
+	}
+	switch {
+	case raw == "":
+		return ""
+	case offer.Suspended || raw == status.Suspended:
+		return status.Suspended
+	case raw == status.Open:
+		return status.Open
+	}
+	return status.Closed
+}
+
+// addPlayer lists a player of the player markets once.
+func (pd *ProcessedData) addPlayer(player Player) {
+	for _, listed := range pd.Players {
+		if listed.ID == player.ID {
+			return
+		}
+	}
+	pd.Players = append(pd.Players, player)
 }
 
-func fixName(name string) string {
-	splitedName := strings.SplitN(name, ",", 2)
-	if len(splitedName) > 1 {
-		name = strings.TrimSpace(splitedName[1] + " " + splitedName[0])
+// StorageKey identifies the event in storage independently of team names,
+// so rematches of the same teams never share a file. Kambi event IDs are
+// shared by all operators, the operator keeps their prices apart.
+func (pd ProcessedData) StorageKey() string {
+	if pd.Operator == "" {
+		return fmt.Sprintf("%s-%d", pd.Provider, pd.EventID)
 	}
-	return name
+	return fmt.Sprintf("%s-%s-%d", pd.Provider, pd.Operator, pd.EventID)
 }
 
+var (
+	setIndexPattern  = regexp.MustCompile(`\bset ([1-5])\b`)
+	gameIndexPattern = regexp.MustCompile(`\bgame ([1-9][0-9]?)\b`)
+	scorePattern     = regexp.MustCompile(`^\s*([0-9]+)\s*[-:]\s*([0-9]+)\s*$`)
+)
+
 func containsOnlyAllowedWords(label string, allowedWords []string) bool {
 	words := strings.Fields(strings.ToLower(label))
 	allowedSet := make(map[string]struct{}, len(allowedWords))
@@ -76,144 +160,592 @@ func containsOnlyAllowedWords(label string, allowedWords []string) bool {
 	return true
 }
 
-func standardizeOutcome(outcome Outcome, criterion map[string]interface{}, homePlayer, awayPlayer, sport string) *string {
-	label, _ := criterion["englishLabel"].(string)
-	label = strings.ToLower(label)
+// footballPeriod finds the half a football market label refers to.
+func footballPeriod(label string) market.Period {
+	if strings.Contains(label, "first half") {
+		return market.PeriodFirstHalf
+	} else if strings.Contains(label, "second half") {
+		return market.PeriodSecondHalf
+	}
+	return market.PeriodFullTime
+}
+
+func selectionFromLabel(label string) (market.Selection, bool) {
+	switch label {
+	case "1":
+		return market.SelectionHome, true
+	case "X":
+		return market.SelectionDraw, true
+	case "2":
+		return market.SelectionAway, true
+	}
+	return "", false
+}
+
+// setIndex finds the set a label refers to, falling back to the criterion
+// order which carries the set number for set markets.
+func setIndex(label string, order []interface{}) (int, bool) {
+	if match := setIndexPattern.FindStringSubmatch(label); match != nil {
+		set, _ := strconv.Atoi(match[1])
+		return set, true
+	}
+	if len(order) == 1 {
+		if set, ok := order[0].(float64); ok && set >= 1 && set <= 5 {
+			return int(set), true
+		}
+	}
+	return 0, false
+}
+
+// gameIndex finds the set and game a game market refers to, from the label
+// ("set 2 game 5", "game 5 - set 2") or from an order of [set, game].
+func gameIndex(label string, order []interface{}) (int, int, bool) {
+	if match := gameIndexPattern.FindStringSubmatch(label); match != nil {
+		if set, ok := setIndex(label, order); ok {
+			game, _ := strconv.Atoi(match[1])
+			return set, game, true
+		}
+	}
+	if len(order) == 2 {
+		set, setOK := order[0].(float64)
+		game, gameOK := order[1].(float64)
+		if setOK && gameOK && set >= 1 && set <= 5 && game >= 1 {
+			return int(set), int(game), true
+		}
+	}
+	return 0, 0, false
+}
+
+// playerScope reports whose statistic a label counts when it names one of
+// the players.
+func playerScope(sport, label, homePlayer, awayPlayer string) (market.Scope, bool) {
+	for _, name := range []string{homePlayer, participant.DisplayName(sport, homePlayer)} {
+		if name != "" && strings.Contains(label, strings.ToLower(name)) {
+			return market.ScopeHome, true
+		}
+	}
+	for _, name := range []string{awayPlayer, participant.DisplayName(sport, awayPlayer)} {
+		if name != "" && strings.Contains(label, strings.ToLower(name)) {
+			return market.ScopeAway, true
+		}
+	}
+	return "", false
+}
+
+func homeAwaySelection(outcomeType string) (market.Selection, bool) {
+	switch outcomeType {
+	case "OT_ONE", "OT_HOME":
+		return market.SelectionHome, true
+	case "OT_TWO", "OT_AWAY":
+		return market.SelectionAway, true
+	}
+	return "", false
+}
+
+func overUnderSelection(outcomeType string) (market.Selection, bool) {
+	switch outcomeType {
+	case "OT_OVER":
+		return market.SelectionOver, true
+	case "OT_UNDER":
+		return market.SelectionUnder, true
+	}
+	return "", false
+}
+
+func yesNoSelection(outcomeType string) (market.Selection, bool) {
+	switch outcomeType {
+	case "OT_YES":
+		return market.SelectionYes, true
+	case "OT_NO":
+		return market.SelectionNo, true
+	}
+	return "", false
+}
+
+// outcomeLabel returns the label of an outcome such as "1", "1X", "1/X" or
+// "2-1". Kambi sends it in the outcome criterion, some payloads on the
+// outcome itself.
+func outcomeLabel(outcome Outcome) string {
+	if label, _ := outcome.Criterion["label"].(string); label != "" {
+		return label
+	}
+	return outcome.Label
+}
+
+// resultSelection reads a 1X2 result from the outcome type or label.
+func resultSelection(outcome Outcome) (market.Selection, bool) {
+	switch outcome.Type {
+	case "OT_ONE", "OT_HOME":
+		return market.SelectionHome, true
+	case "OT_CROSS":
+		return market.SelectionDraw, true
+	case "OT_TWO", "OT_AWAY":
+		return market.SelectionAway, true
+	}
+	return selectionFromLabel(strings.ToUpper(strings.TrimSpace(outcomeLabel(outcome))))
+}
+
+func doubleChanceSelection(outcome Outcome) (market.Selection, bool) {
+	switch outcome.Type {
+	case "OT_ONE_OR_CROSS":
+		return market.SelectionHomeDraw, true
+	case "OT_ONE_OR_TWO":
+		return market.SelectionHomeAway, true
+	case "OT_CROSS_OR_TWO":
+		return market.SelectionDrawAway, true
+	}
+	switch strings.ToUpper(strings.TrimSpace(outcomeLabel(outcome))) {
+	case "1X":
+		return market.SelectionHomeDraw, true
+	case "12":
+		return market.SelectionHomeAway, true
+	case "X2":
+		return market.SelectionDrawAway, true
+	}
+	return "", false
+}
+
+// halfTimeFullTimeSelection reads an outcome label such as "1/X".
+func halfTimeFullTimeSelection(outcome Outcome) (market.Selection, bool) {
+	halfTime, fullTime, found := strings.Cut(strings.ToUpper(outcomeLabel(outcome)), "/")
+	if !found {
+		return "", false
+	}
+	halfTimeSelection, halfTimeOK := selectionFromLabel(strings.TrimSpace(halfTime))
+	fullTimeSelection, fullTimeOK := selectionFromLabel(strings.TrimSpace(fullTime))
+	if !halfTimeOK || !fullTimeOK {
+		return "", false
+	}
+	return market.HalfTimeFullTimeSelection(halfTimeSelection, fullTimeSelection), true
+}
+
+// scoreSelection reads a correct score outcome label such as "6-4".
+func scoreSelection(outcome Outcome) (market.Selection, bool) {
+	match := scorePattern.FindStringSubmatch(outcomeLabel(outcome))
+	if match == nil {
+		return "", false
+	}
+	home, _ := strconv.Atoi(match[1])
+	away, _ := strconv.Atoi(match[2])
+	return market.ScoreSelection(home, away), true
+}
+
+// standardizeTennisDetail maps the game level tennis markets: game winners,
+// correct scores, tie-breaks and player game totals. ok is false when the
+// label is none of them.
+func standardizeTennisDetail(outcome Outcome, label string, order []interface{}, homePlayer, awayPlayer string) (m *market.Market, ok bool) {
+	switch {
+	case strings.Contains(label, "correct score"):
+		// Correct score in sets for the match, in games for a set.
+		base := market.Market{Kind: market.KindCorrectScore, Stat: market.StatSets, Period: market.PeriodFullTime, Scope: market.ScopeMatch}
+		if set, found := setIndex(label, order); found {
+			base.Stat, base.Period = market.StatGames, market.SetPeriod(set)
+		}
+		selection, found := scoreSelection(outcome)
+		if !found {
+			return nil, true
+		}
+		base.Selection = selection
+		return &base, true
+
+	case strings.Contains(label, "tie-break"):
+		set, hasSet := setIndex(label, order)
+		if selection, found := yesNoSelection(outcome.Type); found {
+			period := market.PeriodFullTime
+			if hasSet {
+				period = market.SetPeriod(set)
+			}
+			return &market.Market{Kind: market.KindYesNo, Stat: market.StatTieBreaks, Period: period, Scope: market.ScopeMatch, Selection: selection}, true
+		}
+		if selection, found := homeAwaySelection(outcome.Type); found && hasSet {
+			return &market.Market{Kind: market.KindWinner, Stat: market.StatPoints, Period: market.TieBreakPeriod(set), Scope: market.ScopeMatch, Selection: selection}, true
+		}
+		return nil, true
+
+	case strings.Contains(label, "total") && strings.Contains(label, "games"):
+		scope, scoped := playerScope("Tennis", label, homePlayer, awayPlayer)
+		if !scoped {
+			return nil, false
+		}
+		period := market.PeriodFullTime
+		if set, found := setIndex(label, nil); found {
+			period = market.SetPeriod(set)
+		}
+		selection, found := overUnderSelection(outcome.Type)
+		if !found {
+			return nil, true
+		}
+		return &market.Market{Kind: market.KindTotal, Stat: market.StatGames, Period: period, Scope: scope, Selection: selection}, true
+
+	case strings.Contains(label, "game") && !strings.Contains(label, "games") && !strings.Contains(label, "handicap") &&
+		!strings.Contains(label, "point") && !strings.Contains(label, "total"):
+		// A game is won on points, so its winner is WIN:POINTS for that game.
+		set, game, found := gameIndex(label, order)
+		if !found {
+			// A next game market without the set and game it is about has
+			// no period. Claiming it keeps the generic branches from
+			// mistaking it for the match winner, its outcomes are reported
+			// as unmapped.
+			return nil, strings.Contains(label, "next game")
+		}
+		selection, found := homeAwaySelection(outcome.Type)
+		if !found {
+			return nil, true
+		}
+		return &market.Market{Kind: market.KindWinner, Stat: market.StatPoints, Period: market.GamePeriod(set, game), Scope: market.ScopeMatch, Selection: selection}, true
+	}
+	return nil, false
+}
+
+// footballStat finds the statistic a football label counts.
+func footballStat(label string) market.Stat {
+	if strings.Contains(label, "corner") {
+		return market.StatCorners
+	} else if strings.Contains(label, "card") {
+		return market.StatCards
+	}
+	return market.StatGoals
+}
+
+// standardizeFootballDetail maps the football markets beyond 1X2, goal
+// totals and asian handicaps: both teams to score, double chance, draw no
+// bet, European handicap, half time/full time, correct score and the corner
+// and card markets. ok is false when the label is none of them.
+func standardizeFootballDetail(outcome Outcome, label, selectionLabel, homePlayer, awayPlayer string) (m *market.Market, ok bool) {
+	period := footballPeriod(label)
+	stat := footballStat(label)
+
+	switch {
+	case strings.Contains(label, "both teams to score"):
+		selection, found := yesNoSelection(outcome.Type)
+		if !found {
+			if selectionLabel == "yes" {
+				selection, found = market.SelectionYes, true
+			} else if selectionLabel == "no" {
+				selection, found = market.SelectionNo, true
+			}
+		}
+		if !found {
+			return nil, true
+		}
+		return &market.Market{Kind: market.KindBothTeamsScore, Stat: market.StatGoals, Period: period, Scope: market.ScopeMatch, Selection: selection}, true
+
+	case strings.Contains(label, "double chance"):
+		selection, found := doubleChanceSelection(outcome)
+		if !found {
+			return nil, true
+		}
+		return &market.Market{Kind: market.KindDoubleChance, Stat: market.StatGoals, Period: period, Scope: market.ScopeMatch, Selection: selection}, true
+
+	case strings.Contains(label, "draw no bet"):
+		selection, found := resultSelection(outcome)
+		if !found || selection == market.SelectionDraw {
+			return nil, true
+		}
+		return &market.Market{Kind: market.KindDrawNoBet, Stat: market.StatGoals, Period: period, Scope: market.ScopeMatch, Selection: selection}, true
+
+	case strings.Contains(label, "3-way handicap") || strings.Contains(label, "european handicap"):
+		selection, found := resultSelection(outcome)
+		if !found {
+			return nil, true
+		}
+		return &market.Market{Kind: market.KindHandicap3Way, Stat: stat, Period: period, Scope: market.ScopeMatch, Selection: selection}, true
+
+	case strings.Contains(label, "half time/full time"):
+		selection, found := halfTimeFullTimeSelection(outcome)
+		if !found {
+			return nil, true
+		}
+		return &market.Market{Kind: market.KindHalfTimeFullTime, Stat: market.StatGoals, Period: market.PeriodFullTime, Scope: market.ScopeMatch, Selection: selection}, true
+
+	case strings.Contains(label, "correct score"):
+		selection, found := scoreSelection(outcome)
+		if !found {
+			return nil, true
+		}
+		return &market.Market{Kind: market.KindCorrectScore, Stat: market.StatGoals, Period: period, Scope: market.ScopeMatch, Selection: selection}, true
+
+	case stat != market.StatGoals:
+		// Corner and card markets are never goal markets, unknown ones are
+		// discarded here rather than matched by the goal branches.
+		base := market.Market{Stat: stat, Period: period, Scope: market.ScopeMatch}
+		switch {
+		case strings.Contains(label, "total"):
+			if strings.Contains(label, " by ") {
+				scope, scoped := playerScope("Football", label, homePlayer, awayPlayer)
+				if !scoped {
+					return nil, true
+				}
+				base.Scope = scope
+			}
+			selection, found := overUnderSelection(outcome.Type)
+			if !found {
+				if strings.Contains(selectionLabel, "over") {
+					selection, found = market.SelectionOver, true
+				} else if strings.Contains(selectionLabel, "under") {
+					selection, found = market.SelectionUnder, true
+				}
+			}
+			if !found {
+				return nil, true
+			}
+			base.Kind, base.Selection = market.KindTotal, selection
+		case strings.Contains(label, "handicap"):
+			selection, found := homeAwaySelection(outcome.Type)
+			if !found {
+				return nil, true
+			}
+			base.Kind, base.Selection = market.KindHandicap, selection
+		case strings.Contains(label, "most") || strings.Contains(label, "1x2"):
+			selection, found := resultSelection(outcome)
+			if !found {
+				return nil, true
+			}
+			base.Kind, base.Selection = market.KindWinner, selection
+		default:
+			return nil, true
+		}
+		return &base, true
+	}
+	return nil, false
+}
+
+// playerStat finds the statistic of a player market label. ok is false when
+// the label is not a player market.
+func playerStat(label string) (stat market.Stat, ok bool) {
+	switch {
+	case strings.Contains(label, "goalscorer"):
+		return market.StatGoals, true
+	case strings.Contains(label, "shots on target"):
+		return market.StatShotsOnTarget, true
+	case strings.Contains(label, "shots"):
+		return market.StatShots, true
+	case strings.Contains(label, "assists"):
+		return market.StatAssists, true
+	case strings.Contains(label, "booked"):
+		return market.StatCards, true
+	case strings.Contains(label, "aces"):
+		return market.StatAces, true
+	case strings.Contains(label, "double faults"):
+		return market.StatDoubleFaults, true
+	case strings.Contains(label, "player") && strings.Contains(label, "goals"):
+		return market.StatGoals, true
+	}
+	return "", false
+}
+
+// propPlayer finds the player a player market outcome is about: the outcome
+// participant or the outcome label of goalscorer and booking markets. It is
+// empty for team and match statistics and for outcomes such as "No
+// goalscorer".
+func propPlayer(outcome Outcome, label string) string {
+	if name, _ := outcome.Criterion["participant"].(string); name != "" {
+		return name
+	}
+	if outcome.Participant != "" {
+		return outcome.Participant
+	}
+	if strings.Contains(label, "goalscorer") || strings.Contains(label, "booked") {
+		if noPlayer(outcome) {
+			return ""
+		}
+		return outcomeLabel(outcome)
+	}
+	return ""
+}
+
+// noPlayer reports whether a goalscorer or booking outcome backs nobody,
+// such as "No goalscorer" or "None".
+func noPlayer(outcome Outcome) bool {
+	english, _ := outcome.Criterion["englishLabel"].(string)
+	for _, label := range []string{english, outcomeLabel(outcome)} {
+		label = strings.ToLower(strings.TrimSpace(label))
+		if label == "none" || label == "no" || strings.HasPrefix(label, "no ") {
+			return true
+		}
+	}
+	return false
+}
+
+// standardizePlayerProp maps player markets such as anytime goalscorer,
+// player shots, aces and double faults to a player scoped market carrying
+// the participant ID. The same statistics counted for a team or for one
+// side of a tennis match get the home or away scope. ok is false when the
+// label is not a player market.
+func standardizePlayerProp(outcome Outcome, label, selectionLabel, homePlayer, awayPlayer, sport string) (m *market.Market, ok bool) {
+	stat, ok := playerStat(label)
+	if !ok {
+		return nil, false
+	}
+	if strings.Contains(label, "first goalscorer") || strings.Contains(label, "last goalscorer") {
+		// First and last goalscorer have no canonical market yet.
+		return nil, true
+	}
+
+	base := market.Market{Stat: stat, Period: footballPeriod(label), Scope: market.ScopeMatch}
+	if strings.EqualFold(sport, "Tennis") {
+		base.Period = market.PeriodFullTime
+		if set, found := setIndex(label, nil); found {
+			base.Period = market.SetPeriod(set)
+		}
+	}
+
+	if name := propPlayer(outcome, label); name != "" {
+		base.Scope = market.ScopePlayer
+		base.Participant = participant.ID(sport, participant.Normalize(sport, name))
+	} else if scope, found := playerScope(sport, label, homePlayer, awayPlayer); found {
+		base.Scope = scope
+	} else if stat != market.StatAces && stat != market.StatDoubleFaults {
+		// Only the aces and double faults of a match are counted for both
+		// players together.
+		return nil, true
+	}
+
+	if selection, found := overUnderSelection(outcome.Type); found {
+		base.Kind, base.Selection = market.KindTotal, selection
+	} else if strings.Contains(selectionLabel, "over") {
+		base.Kind, base.Selection = market.KindTotal, market.SelectionOver
+	} else if strings.Contains(selectionLabel, "under") {
+		base.Kind, base.Selection = market.KindTotal, market.SelectionUnder
+	} else if selection, found := yesNoSelection(outcome.Type); found && base.Scope == market.ScopePlayer {
+		base.Kind, base.Selection = market.KindYesNo, selection
+	} else if (stat == market.StatGoals || stat == market.StatCards) && base.Scope == market.ScopePlayer {
+		// Goalscorer and booking outcomes are one per player, backing the
+		// player means yes.
+		base.Kind, base.Selection = market.KindYesNo, market.SelectionYes
+	} else {
+		return nil, true
+	}
+	return &base, true
+}
+
+// standardizeOutcome maps an outcome to its canonical market. label and
+// selectionLabel are the translated criterion and outcome labels.
+func standardizeOutcome(outcome Outcome, criterion map[string]interface{}, label, selectionLabel, homePlayer, awayPlayer, sport string) *market.Market {
 	order, _ := criterion["order"].([]interface{})
-	// line := outcome.Line
 	outcomeType := outcome.Type
 
-	var baseType string
+	var base market.Market
 
-	if sport == "Tennis" {
+	if prop, ok := standardizePlayerProp(outcome, label, selectionLabel, homePlayer, awayPlayer, sport); ok {
+		return prop
+	}
+
+	if strings.EqualFold(sport, "Tennis") {
+		if detailed, ok := standardizeTennisDetail(outcome, label, order, homePlayer, awayPlayer); ok {
+			return detailed
+		}
 		if strings.Contains(label, "handicap") {
 			if strings.Contains(label, "game") && len(order) == 1 && order[0] == 0.0 {
 				if containsOnlyAllowedWords(label, []string{"game", "handicap"}) {
-					baseType = "GAH"
+					base = market.Market{Kind: market.KindHandicap, Stat: market.StatGames, Period: market.PeriodFullTime}
 				} else {
 					return nil
 				}
 			} else if strings.Contains(label, "set") && len(order) == 1 && order[0] == 0.0 {
 				if containsOnlyAllowedWords(label, []string{"set", "handicap"}) {
-					baseType = "AH"
+					base = market.Market{Kind: market.KindHandicap, Stat: market.StatSets, Period: market.PeriodFullTime}
 				} else {
 					return nil
 				}
 			} else {
 				return nil
 			}
-		} else if strings.Contains(label, "match odds") || label == "noteringen wedstrijd" {
-			if len(order) == 1 && order[0] == 0.0 && containsOnlyAllowedWords(label, []string{"match", "odds", "noteringen", "wedstrijd"}) {
-				baseType = ""
+		} else if strings.Contains(label, "match odds") {
+			if len(order) == 1 && order[0] == 0.0 && containsOnlyAllowedWords(label, []string{"match", "odds"}) {
+				base = market.Market{Kind: market.KindWinner, Stat: market.StatSets, Period: market.PeriodFullTime}
 			} else {
 				return nil
 			}
 		} else if strings.Contains(label, "set") && !strings.Contains(label, "game") && !strings.Contains(label, "point") && !strings.Contains(label, "total") {
 			if len(order) == 1 && order[0].(float64) >= 1 && order[0].(float64) <= 5 && containsOnlyAllowedWords(label, []string{"set", fmt.Sprintf("%d", int(order[0].(float64)))}) {
-				baseType = fmt.Sprintf("%dH", int(order[0].(float64)))
+				base = market.Market{Kind: market.KindWinner, Stat: market.StatGames, Period: market.SetPeriod(int(order[0].(float64)))}
 			} else {
 				return nil
 			}
 		} else if strings.Contains(label, "total") {
 			if strings.Contains(label, "games") && len(order) == 1 && order[0] == 0.0 {
-				baseType = "G"
+				base = market.Market{Kind: market.KindTotal, Stat: market.StatGames, Period: market.PeriodFullTime}
 			} else if strings.Contains(label, "sets") && len(order) == 1 && order[0] == 0.0 {
-				baseType = ""
+				base = market.Market{Kind: market.KindTotal, Stat: market.StatSets, Period: market.PeriodFullTime}
 			} else if strings.Contains(label, "games") && strings.Contains(label, "set") && len(order) == 1 && order[0].(float64) >= 1 && order[0].(float64) <= 5 {
-				baseType = fmt.Sprintf("%dHG", int(order[0].(float64)))
+				base = market.Market{Kind: market.KindTotal, Stat: market.StatGames, Period: market.SetPeriod(int(order[0].(float64)))}
 			} else {
 				return nil
 			}
 		} else {
 			return nil
 		}
+		base.Scope = market.ScopeMatch
 
 		switch outcomeType {
 		case "OT_ONE", "OT_HOME":
-			result := baseType + "1"
-			return &result
+			base.Selection = market.SelectionHome
 		case "OT_TWO", "OT_AWAY":
-			result := baseType + "2"
-			return &result
+			base.Selection = market.SelectionAway
 		case "OT_OVER":
-			result := baseType + "O"
-			return &result
+			base.Selection = market.SelectionOver
 		case "OT_UNDER":
-			result := baseType + "U"
-			return &result
+			base.Selection = market.SelectionUnder
 		case "OT_CROSS":
-			if baseType == "" {
-				result := "X"
-				return &result
+			if base.Kind != market.KindWinner || base.Period != market.PeriodFullTime {
+				return nil
 			}
+			base.Selection = market.SelectionDraw
+		default:
+			return nil
+		}
+		return &base
+	} else if strings.EqualFold(sport, "Football") {
+		if detailed, ok := standardizeFootballDetail(outcome, label, selectionLabel, homePlayer, awayPlayer); ok {
+			return detailed
 		}
-	} else if sport == "Football" {
-		englishLabel, _ := outcome.Criterion["englishLabel"].(string)
 		participant, _ := outcome.Criterion["participant"].(string)
-		// line := outcome.Line / 1000
-
-		if label == "full time" || label == "1x2" {
-			result := outcome.Criterion["label"].(string)
-			return &result
-		} else if label == "first half 1x2" || label == "half time" {
-			result := "1H" + outcome.Criterion["label"].(string)
-			return &result
-		} else if label == "2nd half 1x2" {
-			result := "2H" + outcome.Criterion["label"].(string)
-			return &result
-		} else if strings.Contains(label, "total goals") || strings.Contains(label, "asian total") {
-			var prefix string
-			if strings.Contains(label, "first half") || strings.Contains(label, "1e helft") || strings.Contains(label, "1st half") {
-				prefix = "1H"
-			} else if strings.Contains(label, "2nd half") || strings.Contains(label, "2e helft") {
-				prefix = "2H"
-			}
 
+		if label == "full time" || label == "1x2" || label == "first half 1x2" || label == "half time" || label == "second half 1x2" {
+			selection, ok := resultSelection(outcome)
+			if !ok {
+				return nil
+			}
+			period := market.PeriodFullTime
+			if label == "first half 1x2" || label == "half time" {
+				period = market.PeriodFirstHalf
+			} else if label == "second half 1x2" {
+				period = market.PeriodSecondHalf
+			}
+			return &market.Market{Kind: market.KindWinner, Stat: market.StatGoals, Period: period, Scope: market.ScopeMatch, Selection: selection}
+		} else if strings.Contains(label, "total goals") || strings.Contains(label, "asian total") {
 			if strings.Contains(label, ":") {
 				return nil
 			}
-			if strings.Contains(label, "by") || strings.Contains(label, "door") {
-				if strings.Contains(strings.ToLower(label), strings.ToLower(homePlayer)) {
-					teamPrefix := "H"
-					if strings.Contains(strings.ToLower(englishLabel), "over") {
-						result := prefix + "T" + teamPrefix + "O"
-						return &result
-					} else if strings.Contains(strings.ToLower(englishLabel), "under") {
-						result := prefix + "T" + teamPrefix + "U"
-						return &result
-					}
-				} else if strings.Contains(strings.ToLower(label), strings.ToLower(awayPlayer)) {
-					teamPrefix := "A"
-					if strings.Contains(strings.ToLower(englishLabel), "over") {
-						result := prefix + "T" + teamPrefix + "O"
-						return &result
-					} else if strings.Contains(strings.ToLower(englishLabel), "under") {
-						result := prefix + "T" + teamPrefix + "U"
-						return &result
-					}
+			base = market.Market{Kind: market.KindTotal, Stat: market.StatGoals, Period: footballPeriod(label), Scope: market.ScopeMatch}
+
+			if strings.Contains(label, " by ") {
+				if strings.Contains(label, strings.ToLower(homePlayer)) {
+					base.Scope = market.ScopeHome
+				} else if strings.Contains(label, strings.ToLower(awayPlayer)) {
+					base.Scope = market.ScopeAway
+				} else {
+					return nil
 				}
+			}
+
+			if strings.Contains(selectionLabel, "over") {
+				base.Selection = market.SelectionOver
+			} else if strings.Contains(selectionLabel, "under") {
+				base.Selection = market.SelectionUnder
 			} else {
-				if strings.Contains(strings.ToLower(englishLabel), "over") {
-					result := prefix + "O"
-					return &result
-				} else if strings.Contains(strings.ToLower(englishLabel), "under") {
-					result := prefix + "U"
-					return &result
-				}
+				return nil
 			}
+			return &base
 		} else if strings.Contains(label, "handicap") && !strings.Contains(label, "3") {
-			var prefix string
-			if strings.Contains(label, "1st half") || strings.Contains(label, "1e helft") || strings.Contains(label, "first half") {
-				prefix = "1H"
-			} else if strings.Contains(label, "2nd half") || strings.Contains(label, "2e helft") || strings.Contains(label, "second half") {
-				prefix = "2H"
-			}
+			base = market.Market{Kind: market.KindHandicap, Stat: market.StatGoals, Period: footballPeriod(label), Scope: market.ScopeMatch}
 			if strings.ToLower(participant) == strings.ToLower(homePlayer) {
-				result := prefix + "AH1"
-				return &result
+				base.Selection = market.SelectionHome
+				return &base
 			} else if strings.ToLower(participant) == strings.ToLower(awayPlayer) {
-				result := prefix + "AH2"
-				return &result
+				base.Selection = market.SelectionAway
+				return &base
 			}
 		}
 	}
@@ -221,8 +753,10 @@ func standardizeOutcome(outcome Outcome, criterion map[string]interface{}, homeP
 	return nil
 }
 
-// func ProcessMatchData(rawData RawData) (ProcessedData, error) {
-func ProcessMatchData(rawData *RawData) (ProcessedData, error) {
+// ProcessMatchData normalizes a fetched event. Labels are translated from
+// the feed's locale by labels before markets are standardized, offers with
+// outcomes no market was found for are recorded in unmapped.
+func ProcessMatchData(rawData *RawData, labels *translation.Translator, locale string, unmapped *discovery.Collector) (ProcessedData, error) {
 
 	event := rawData.Events[0]
 	homeTeam := event.HomeName
@@ -241,11 +775,12 @@ func ProcessMatchData(rawData *RawData) (ProcessedData, error) {
 	var processedData ProcessedData
 	if strings.ToLower(event.Sport) == "tennis" {
 		processedData = ProcessedData{
+			Provider:  ProviderUnibet,
 			EventID:   event.ID,
-			MatchName: fmt.Sprintf("%s vs %s", fixName(homeTeam), fixName(awayTeam)),
+			MatchName: fmt.Sprintf("%s vs %s", participant.DisplayName(event.Sport, homeTeam), participant.DisplayName(event.Sport, awayTeam)),
 			StartTime: startTimestamp,
-			HomeTeam:  fixName(homeTeam),
-			AwayTeam:  fixName(awayTeam),
+			HomeTeam:  participant.DisplayName(event.Sport, homeTeam),
+			AwayTeam:  participant.DisplayName(event.Sport, awayTeam),
 			Sport:     strings.Title(event.Sport),
 			League:    "Unknown",
 			Country:   "Unknown",
@@ -255,6 +790,7 @@ func ProcessMatchData(rawData *RawData) (ProcessedData, error) {
 		}
 	} else {
 		processedData = ProcessedData{
+			Provider:  ProviderUnibet,
 			EventID:   event.ID,
 			MatchName: fmt.Sprintf("%s vs %s", homeTeam, awayTeam),
 			StartTime: startTimestamp,
@@ -270,59 +806,70 @@ func ProcessMatchData(rawData *RawData) (ProcessedData, error) {
 	}
 
 	for _, offer := range rawData.BetOffers {
-		if offer.Suspended {
+		if len(offer.Criterion) == 0 {
 			continue
 		}
+		label := labels.Criterion(locale, offer.Criterion, homeTeam, awayTeam)
+		// The outcomes of player markets are labelled with player names,
+		// which are not translated.
+		_, playerMarket := playerStat(label)
+		var discarded []string
+		mapped := 0
 		for _, outcome := range offer.Outcomes {
-			if outcome.Criterion["status"] == "OPEN" {
-				if len(offer.Criterion) == 0 {
+			if state := outcomeStatus(offer, outcome); state != "" {
+				selectionLabel, _ := outcome.Criterion["englishLabel"].(string)
+				if playerMarket {
+					selectionLabel = strings.ToLower(selectionLabel)
+				} else if selectionLabel != "" {
+					selectionLabel = labels.Label(locale, selectionLabel, homeTeam, awayTeam)
+				}
+				canonical := standardizeOutcome(outcome, offer.Criterion, label, selectionLabel, homeTeam, awayTeam, strings.Title(event.Sport))
+				if canonical == nil {
+					discarded = append(discarded, outcome.Type)
 					continue
 				}
-				standardizedType := standardizeOutcome(outcome, offer.Criterion, homeTeam, awayTeam, strings.Title(event.Sport))
-				if standardizedType != nil {
-					processedOutcome := Outcome{
-						TypeName:   offer.Criterion["englishLabel"].(string),
-						Type:       *standardizedType,
-						Line:       outcome.Line / 1000,
-						Odds:       outcome.Odds / 1000,
-						BetOfferID: outcome.BetOfferID,
-						ID:         outcome.ID,
-						Criterion:  offer.Criterion,
-						Path:       event.Path,
-					}
-					processedData.Outcomes = append(processedData.Outcomes, processedOutcome)
+				if canonical.Kind.HasLine() {
+					*canonical = canonical.WithLine(outcome.Line / 1000)
+				}
+				processedOutcome := Outcome{
+					TypeName:   offer.Criterion["englishLabel"].(string),
+					Type:       canonical.Code(),
+					Market:     canonical,
+					Line:       outcome.Line / 1000,
+					Odds:       outcome.Odds / 1000,
+					BetOfferID: outcome.BetOfferID,
+					ID:         outcome.ID,
+					Criterion:  offer.Criterion,
+					Path:       event.Path,
+					Status:     state,
 				}
+				if canonical.Scope == market.ScopePlayer {
+					name := participant.DisplayName(event.Sport, propPlayer(outcome, label))
+					processedOutcome.Participant, processedOutcome.ParticipantID = name, outcome.ParticipantID
+					processedData.addPlayer(Player{ID: canonical.Participant, Name: name, ProviderID: outcome.ParticipantID})
+				}
+				processedData.Outcomes = append(processedData.Outcomes, processedOutcome)
+				mapped++
 			}
 		}
-	}
-
-	matchName := fmt.Sprintf("%s vs %s", processedData.HomeTeam, processedData.AwayTeam)
-	matchName = strings.ReplaceAll(matchName, "/", "")
 
-	saveOddsToJSONL(matchName, processedData)
-
-	return processedData, nil
-}
-
-func saveOddsToJSONL(matchName string, data ProcessedData) {
-	
-	file, err := os.OpenFile(fmt.Sprintf("/odds_data/%s.jsonl", matchName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return
-	}
-	defer file.Close()
-
-	dataBytes, err := json.Marshal(data)
-	if err != nil {
-		fmt.Println("Error marshalling data:", err)
-		return
+		if len(discarded) > 0 {
+			englishLabel, _ := offer.Criterion["englishLabel"].(string)
+			order, _ := offer.Criterion["order"].([]interface{})
+			unmapped.Record(discovery.Observation{
+				Sport:        strings.Title(event.Sport),
+				EventID:      event.ID,
+				Label:        label,
+				EnglishLabel: englishLabel,
+				HomeName:     homeTeam,
+				AwayName:     awayTeam,
+				Order:        order,
+				OutcomeTypes: discarded,
+				Partial:      mapped > 0,
+				Offer:        offer,
+			})
+		}
 	}
 
-	if _, err := file.Write(dataBytes); err != nil {
-		fmt.Println("Error writing to file:", err)
-	}
-	if _, err := file.WriteString("\n"); err != nil {
-		fmt.Println("Error writing newline to file:", err)
-	}
+	return processedData, nil
 }
@@ -0,0 +1,222 @@
+package helper
+
+import (
+	"testing"
+
+	"test_task_app/discovery"
+	"test_task_app/participant"
+	"test_task_app/translation"
+)
+
+// mapping is an outcome of a bet offer and the market code it must map to,
+// "" when it must not be mapped.
+type mapping struct {
+	label          string
+	order          []interface{}
+	outcomeType    string
+	outcomeLabel   string
+	selectionLabel string
+	// participant is the player the outcome criterion names.
+	participant string
+	want        string
+}
+
+func testMappings(t *testing.T, sport, home, away string, tests []mapping) {
+	t.Helper()
+
+	for _, tt := range tests {
+		outcome := Outcome{Type: tt.outcomeType, Criterion: map[string]interface{}{}}
+		if tt.outcomeLabel != "" {
+			outcome.Criterion["label"] = tt.outcomeLabel
+		}
+		if tt.participant != "" {
+			outcome.Criterion["participant"] = tt.participant
+		}
+		criterion := map[string]interface{}{}
+		if tt.order != nil {
+			criterion["order"] = tt.order
+		}
+		got := ""
+		if m := standardizeOutcome(outcome, criterion, tt.label, tt.selectionLabel, home, away, sport); m != nil {
+			got = m.Code()
+		}
+		if got != tt.want {
+			t.Errorf("%q %v %s %q: got %q, want %q", tt.label, tt.order, tt.outcomeType, tt.outcomeLabel, got, tt.want)
+		}
+	}
+}
+
+func TestStandardizeTennis(t *testing.T) {
+	testMappings(t, "Tennis", "Djokovic, Novak", "Alcaraz, Carlos", []mapping{
+		{label: "match odds", order: []interface{}{0.0}, outcomeType: "OT_ONE", want: "WIN:SETS:FT:MATCH:HOME"},
+		{label: "total games", order: []interface{}{0.0}, outcomeType: "OT_OVER", want: "TOTAL:GAMES:FT:MATCH:OVER"},
+
+		// Correct score is counted in sets for the match, in games for a set.
+		{label: "correct score", order: []interface{}{0.0}, outcomeLabel: "2-1", want: "CS:SETS:FT:MATCH:2-1"},
+		{label: "correct score - set 2", outcomeLabel: "6-4", want: "CS:GAMES:S2:MATCH:6-4"},
+		{label: "correct score", order: []interface{}{3.0}, outcomeLabel: "7:6", want: "CS:GAMES:S3:MATCH:7-6"},
+		{label: "correct score", order: []interface{}{0.0}, outcomeLabel: "any other", want: ""},
+
+		// Whether there is a tie-break, and who wins the tie-break of a set.
+		{label: "tie-break in match", outcomeType: "OT_YES", want: "YN:TIEBREAKS:FT:MATCH:YES"},
+		{label: "tie-break - set 1", outcomeType: "OT_NO", want: "YN:TIEBREAKS:S1:MATCH:NO"},
+		{label: "tie-break in set", order: []interface{}{2.0}, outcomeType: "OT_YES", want: "YN:TIEBREAKS:S2:MATCH:YES"},
+		{label: "set 1 tie-break winner", outcomeType: "OT_ONE", want: "WIN:POINTS:S1TB:MATCH:HOME"},
+		{label: "tie-break winner", order: []interface{}{4.0}, outcomeType: "OT_TWO", want: "WIN:POINTS:S4TB:MATCH:AWAY"},
+		{label: "tie-break winner", outcomeType: "OT_ONE", want: ""},
+
+		// Player game totals, by the name as Kambi sends it or as displayed.
+		{label: "total games won by djokovic, novak", outcomeType: "OT_OVER", want: "TOTAL:GAMES:FT:HOME:OVER"},
+		{label: "total games won by carlos alcaraz - set 2", outcomeType: "OT_UNDER", want: "TOTAL:GAMES:S2:AWAY:UNDER"},
+
+		// Set and game winners, from the label or from an order of [set, game].
+		{label: "set 2", order: []interface{}{2.0}, outcomeType: "OT_TWO", want: "WIN:GAMES:S2:MATCH:AWAY"},
+		{label: "set 2 game 5", outcomeType: "OT_TWO", want: "WIN:POINTS:S2G5:MATCH:AWAY"},
+		{label: "game 5 - set 2", outcomeType: "OT_ONE", want: "WIN:POINTS:S2G5:MATCH:HOME"},
+		{label: "game winner", order: []interface{}{1.0, 3.0}, outcomeType: "OT_ONE", want: "WIN:POINTS:S1G3:MATCH:HOME"},
+		{label: "next game", order: []interface{}{3.0, 7.0}, outcomeType: "OT_TWO", want: "WIN:POINTS:S3G7:MATCH:AWAY"},
+		{label: "next game", outcomeType: "OT_ONE", want: ""},
+	})
+}
+
+func TestStandardizeFootball(t *testing.T) {
+	testMappings(t, "Football", "Arsenal", "Chelsea", []mapping{
+		{label: "full time", outcomeType: "OT_CROSS", want: "WIN:GOALS:FT:MATCH:DRAW"},
+		{label: "total goals", selectionLabel: "over", want: "TOTAL:GOALS:FT:MATCH:OVER"},
+
+		{label: "both teams to score", outcomeType: "OT_YES", want: "BTTS:GOALS:FT:MATCH:YES"},
+		{label: "both teams to score - first half", selectionLabel: "no", want: "BTTS:GOALS:H1:MATCH:NO"},
+		{label: "both teams to score", selectionLabel: "maybe", want: ""},
+
+		{label: "double chance", outcomeType: "OT_ONE_OR_CROSS", want: "DC:GOALS:FT:MATCH:HOME_DRAW"},
+		{label: "double chance - second half", outcomeLabel: "X2", want: "DC:GOALS:H2:MATCH:DRAW_AWAY"},
+		{label: "double chance", outcomeLabel: "12", want: "DC:GOALS:FT:MATCH:HOME_AWAY"},
+
+		// Draw no bet has no draw to back.
+		{label: "draw no bet", outcomeType: "OT_ONE", want: "DNB:GOALS:FT:MATCH:HOME"},
+		{label: "draw no bet", outcomeLabel: "2", want: "DNB:GOALS:FT:MATCH:AWAY"},
+		{label: "draw no bet", outcomeType: "OT_CROSS", want: ""},
+
+		{label: "3-way handicap", outcomeType: "OT_CROSS", want: "HCP3:GOALS:FT:MATCH:DRAW"},
+		{label: "european handicap - first half", outcomeType: "OT_ONE", want: "HCP3:GOALS:H1:MATCH:HOME"},
+		{label: "3-way handicap - corners", outcomeType: "OT_TWO", want: "HCP3:CORNERS:FT:MATCH:AWAY"},
+
+		{label: "half time/full time", outcomeLabel: "1/X", want: "HTFT:GOALS:FT:MATCH:HOME/DRAW"},
+		{label: "half time/full time", outcomeLabel: "2/2", want: "HTFT:GOALS:FT:MATCH:AWAY/AWAY"},
+		{label: "half time/full time", outcomeLabel: "1/3", want: ""},
+
+		{label: "correct score", outcomeLabel: "2-1", want: "CS:GOALS:FT:MATCH:2-1"},
+		{label: "correct score - first half", outcomeLabel: "0 - 0", want: "CS:GOALS:H1:MATCH:0-0"},
+
+		// Corner and card markets are told apart from goal markets.
+		{label: "total corners", outcomeType: "OT_OVER", want: "TOTAL:CORNERS:FT:MATCH:OVER"},
+		{label: "total corners by arsenal", selectionLabel: "under", want: "TOTAL:CORNERS:FT:HOME:UNDER"},
+		{label: "total corners by tottenham", outcomeType: "OT_OVER", want: ""},
+		{label: "total cards - second half", outcomeType: "OT_UNDER", want: "TOTAL:CARDS:H2:MATCH:UNDER"},
+		{label: "total cards by chelsea", outcomeType: "OT_OVER", want: "TOTAL:CARDS:FT:AWAY:OVER"},
+		{label: "corner handicap", outcomeType: "OT_TWO", want: "HCP:CORNERS:FT:MATCH:AWAY"},
+		{label: "most corners", outcomeType: "OT_CROSS", want: "WIN:CORNERS:FT:MATCH:DRAW"},
+		{label: "card 1x2 - first half", outcomeType: "OT_ONE", want: "WIN:CARDS:H1:MATCH:HOME"},
+		{label: "first corner", outcomeType: "OT_ONE", want: ""},
+	})
+}
+
+func TestStandardizePlayerProp(t *testing.T) {
+	saka := "PLAYER/" + participant.ID("Football", participant.Normalize("Football", "Bukayo Saka"))
+	palmer := "PLAYER/" + participant.ID("Football", participant.Normalize("Football", "Cole Palmer"))
+	testMappings(t, "Football", "Arsenal", "Chelsea", []mapping{
+		{label: "anytime goalscorer", outcomeLabel: "Bukayo Saka", want: "YN:GOALS:FT:" + saka + ":YES"},
+		{label: "player shots on target", participant: "Saka, Bukayo", outcomeType: "OT_OVER", want: "TOTAL:SHOTS_ON_TARGET:FT:" + saka + ":OVER"},
+		{label: "player assists - first half", participant: "Bukayo Saka", selectionLabel: "under", want: "TOTAL:ASSISTS:H1:" + saka + ":UNDER"},
+		{label: "to be booked", outcomeLabel: "Cole Palmer", want: "YN:CARDS:FT:" + palmer + ":YES"},
+		{label: "player to score 2+ goals", participant: "Cole Palmer", outcomeType: "OT_NO", want: "YN:GOALS:FT:" + palmer + ":NO"},
+
+		// Outcomes that back no player are skipped.
+		{label: "anytime goalscorer", outcomeLabel: "No goalscorer", want: ""},
+		{label: "to be booked", outcomeLabel: "None", want: ""},
+		{label: "first goalscorer", outcomeLabel: "Bukayo Saka", want: ""},
+
+		// The same statistics counted for a team.
+		{label: "total shots by arsenal", outcomeType: "OT_OVER", want: "TOTAL:SHOTS:FT:HOME:OVER"},
+		{label: "total shots on target by chelsea - first half", selectionLabel: "under", want: "TOTAL:SHOTS_ON_TARGET:H1:AWAY:UNDER"},
+		{label: "total shots", outcomeType: "OT_OVER", want: ""},
+	})
+
+	testMappings(t, "Tennis", "Djokovic, Novak", "Alcaraz, Carlos", []mapping{
+		{label: "total aces", outcomeType: "OT_OVER", want: "TOTAL:ACES:FT:MATCH:OVER"},
+		{label: "total aces by novak djokovic - set 1", outcomeType: "OT_UNDER", want: "TOTAL:ACES:S1:HOME:UNDER"},
+		{label: "total double faults by alcaraz, carlos", outcomeType: "OT_OVER", want: "TOTAL:DOUBLE_FAULTS:FT:AWAY:OVER"},
+	})
+}
+
+func TestPlayerPropParticipants(t *testing.T) {
+	dir := t.TempDir()
+	labels, err := translation.NewTranslator(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmapped, err := discovery.NewCollector(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := &RawData{
+		Events: []Event{{ID: 1, HomeName: "Arsenal", AwayName: "Chelsea", Start: "2025-10-09T18:00:00Z", Sport: "FOOTBALL"}},
+		BetOffers: []BetOffer{{
+			Criterion: map[string]interface{}{"englishLabel": "Anytime Goalscorer"},
+			Outcomes: []Outcome{
+				{Type: "OT_UNTYPED", Odds: 2500, Status: "OPEN", ParticipantID: 1001, Criterion: map[string]interface{}{"label": "Saka, Bukayo"}},
+				{Type: "OT_UNTYPED", Odds: 3000, Status: "OPEN", ParticipantID: 1002, Criterion: map[string]interface{}{"label": "Cole Palmer"}},
+				{Type: "OT_UNTYPED", Odds: 9000, Status: "OPEN", Criterion: map[string]interface{}{"label": "No goalscorer"}},
+			},
+		}},
+	}
+	data, err := ProcessMatchData(raw, labels, "en_GB", unmapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Outcomes) != 2 {
+		t.Fatalf("mapped %d outcomes, want the two players", len(data.Outcomes))
+	}
+	saka := data.Outcomes[0]
+	if saka.Participant != "Bukayo Saka" || saka.ParticipantID != 1001 || saka.Market.Participant == "" {
+		t.Errorf("outcome %+v", saka)
+	}
+	if len(data.Players) != 2 || data.Players[0].ID != saka.Market.Participant || data.Players[0].ProviderID != 1001 {
+		t.Errorf("players %+v", data.Players)
+	}
+}
+
+func TestNextGameWithoutIndexIsUnmapped(t *testing.T) {
+	dir := t.TempDir()
+	labels, err := translation.NewTranslator(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmapped, err := discovery.NewCollector(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := &RawData{
+		Events: []Event{{ID: 1, HomeName: "Djokovic, Novak", AwayName: "Alcaraz, Carlos", Start: "2025-10-09T18:00:00Z", Sport: "TENNIS"}},
+		BetOffers: []BetOffer{{
+			Criterion: map[string]interface{}{"englishLabel": "Next Game"},
+			Outcomes: []Outcome{
+				{Type: "OT_ONE", Odds: 1500, Status: "OPEN"},
+				{Type: "OT_TWO", Odds: 2500, Status: "OPEN"},
+			},
+		}},
+	}
+	data, err := ProcessMatchData(raw, labels, "en_GB", unmapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Outcomes) != 0 {
+		t.Errorf("mapped %d outcomes of a next game market without index", len(data.Outcomes))
+	}
+	report := unmapped.Report("Tennis", 0)
+	if len(report) != 1 || report[0].Label != "next game" || report[0].Offers != 1 {
+		t.Errorf("unmapped report %+v, want the next game market", report)
+	}
+}
@@ -0,0 +1,146 @@
+package helper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"test_task_app/discovery"
+	"test_task_app/market"
+	"test_task_app/participant"
+	"test_task_app/translation"
+)
+
+// TypeOutright is the ProcessedData type of a competition's outright
+// markets, next to the PreMatch and Live types of matches.
+const TypeOutright = "Outright"
+
+var groupPattern = regexp.MustCompile(`\bgroup ([a-z])\b`)
+
+// outrightParticipant returns the team or player an outright outcome backs.
+func outrightParticipant(outcome Outcome) string {
+	if name, _ := outcome.Criterion["participant"].(string); name != "" {
+		return name
+	}
+	if outcome.Participant != "" {
+		return outcome.Participant
+	}
+	return outcomeLabel(outcome)
+}
+
+// standardizeOutright maps an outright outcome to its canonical market,
+// backing the participant of the outcome: the competition or group winner,
+// the top goalscorer or a relegated team.
+func standardizeOutright(outcome Outcome, label, sport string) *market.Market {
+	base := market.Market{Kind: market.KindOutright, Period: market.PeriodFullTime, Scope: market.ScopeParticipant}
+	switch {
+	case strings.Contains(label, "top goalscorer"):
+		base.Stat = market.StatGoals
+	case strings.Contains(label, "relegated"):
+		base.Stat = market.StatRelegation
+	case strings.Contains(label, "winner"):
+		base.Stat = market.StatWinner
+		if match := groupPattern.FindStringSubmatch(label); match != nil {
+			base.Period = market.GroupPeriod(match[1])
+		} else if strings.Contains(label, "group") {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	name := outrightParticipant(outcome)
+	if name == "" {
+		return nil
+	}
+	base.Participant = participant.ID(sport, participant.Normalize(sport, name))
+	if selection, found := yesNoSelection(outcome.Type); found {
+		base.Selection = selection
+	} else {
+		base.Selection = market.SelectionYes
+	}
+	return &base
+}
+
+// ProcessOutrightData normalizes the outright markets of a competition
+// event. Every selection is linked to the participant it backs, offers with
+// outcomes no market was found for are recorded in unmapped.
+func ProcessOutrightData(rawData *RawData, labels *translation.Translator, locale string, unmapped *discovery.Collector) (ProcessedData, error) {
+	if len(rawData.Events) == 0 {
+		return ProcessedData{}, fmt.Errorf("no competition event in outright data")
+	}
+	event := rawData.Events[0]
+	startTime, err := time.Parse(time.RFC3339, event.Start)
+	if err != nil {
+		return ProcessedData{}, err
+	}
+	sport := strings.Title(event.Sport)
+
+	processedData := ProcessedData{
+		Provider:  ProviderUnibet,
+		EventID:   event.ID,
+		MatchName: event.Name,
+		StartTime: startTime.Unix(),
+		Sport:     sport,
+		League:    event.Group,
+		Country:   "Unknown",
+		Outcomes:  []Outcome{},
+		Time:      time.Now().Unix(),
+		Type:      TypeOutright,
+	}
+
+	for _, offer := range rawData.BetOffers {
+		if len(offer.Criterion) == 0 {
+			continue
+		}
+		label := labels.Criterion(locale, offer.Criterion, "", "")
+		var discarded []string
+		mapped := 0
+		for _, outcome := range offer.Outcomes {
+			state := outcomeStatus(offer, outcome)
+			if state == "" {
+				continue
+			}
+			canonical := standardizeOutright(outcome, label, sport)
+			if canonical == nil {
+				discarded = append(discarded, outcome.Type)
+				continue
+			}
+			name := participant.DisplayName(sport, outrightParticipant(outcome))
+			typeName, _ := offer.Criterion["englishLabel"].(string)
+			processedData.Outcomes = append(processedData.Outcomes, Outcome{
+				TypeName:      typeName,
+				Type:          canonical.Code(),
+				Market:        canonical,
+				Odds:          outcome.Odds / 1000,
+				BetOfferID:    outcome.BetOfferID,
+				ID:            outcome.ID,
+				Criterion:     offer.Criterion,
+				Path:          event.Path,
+				Participant:   name,
+				ParticipantID: outcome.ParticipantID,
+				Status:        state,
+			})
+			processedData.addPlayer(Player{ID: canonical.Participant, Name: name, ProviderID: outcome.ParticipantID})
+			mapped++
+		}
+
+		if len(discarded) > 0 {
+			englishLabel, _ := offer.Criterion["englishLabel"].(string)
+			order, _ := offer.Criterion["order"].([]interface{})
+			unmapped.Record(discovery.Observation{
+				Sport:        sport,
+				EventID:      event.ID,
+				Label:        label,
+				EnglishLabel: englishLabel,
+				Order:        order,
+				OutcomeTypes: discarded,
+				Partial:      mapped > 0,
+				Offer:        offer,
+			})
+		}
+	}
+
+	return processedData, nil
+}
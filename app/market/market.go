@@ -0,0 +1,331 @@
+// Package market is the canonical description of a priced outcome shared by
+// the parser, the websocket payloads and the view.
+//
+// Every outcome is described by the kind of market, the statistic it is
+// settled on, the period, the participant scope, the selection and an
+// optional line. The stable string code of an outcome is
+//
+//	KIND:STAT:PERIOD:SCOPE:SELECTION[@LINE]
+//
+// for example WIN:GOALS:FT:MATCH:DRAW, TOTAL:GAMES:S2:MATCH:OVER@9.5 or
+// CS:GAMES:S1:MATCH:6-4. Player and outright markets carry the participant
+// ID in the scope, as in TOTAL:SHOTS:FT:PLAYER/p1a2b3c4d5e6f:OVER@1.5 or
+// OUTRIGHT:WINNER:GA:PARTICIPANT/p1a2b3c4d5e6f:YES.
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Kind string
+
+const (
+	// KindWinner is a 1X2 or two-way winner market.
+	KindWinner   Kind = "WIN"
+	KindHandicap Kind = "HCP"
+	KindTotal    Kind = "TOTAL"
+	// KindCorrectScore selections are scores such as 6-4.
+	KindCorrectScore Kind = "CS"
+	// KindYesNo asks whether something happens, e.g. a tie-break.
+	KindYesNo Kind = "YN"
+	// KindBothTeamsScore is Both Teams To Score, selections YES and NO.
+	KindBothTeamsScore Kind = "BTTS"
+	// KindDoubleChance selections cover two of the three 1X2 results.
+	KindDoubleChance Kind = "DC"
+	// KindDrawNoBet is a winner market voided on a draw.
+	KindDrawNoBet Kind = "DNB"
+	// KindHandicap3Way is the European handicap, a 1X2 on the handicapped score.
+	KindHandicap3Way Kind = "HCP3"
+	// KindHalfTimeFullTime selections are the half time and full time
+	// results, e.g. HOME/DRAW.
+	KindHalfTimeFullTime Kind = "HTFT"
+	// KindOutright is a futures market of a whole competition, such as the
+	// tournament winner, settled when the competition or a stage ends.
+	KindOutright Kind = "OUTRIGHT"
+)
+
+// HasLine reports whether outcomes of the kind are priced on a line.
+func (k Kind) HasLine() bool {
+	return k == KindHandicap || k == KindTotal || k == KindHandicap3Way
+}
+
+// Stat is the statistic the market is settled on.
+type Stat string
+
+const (
+	StatGoals     Stat = "GOALS"
+	StatSets      Stat = "SETS"
+	StatGames     Stat = "GAMES"
+	StatPoints    Stat = "POINTS"
+	StatTieBreaks Stat = "TIEBREAKS"
+	StatCorners   Stat = "CORNERS"
+	StatCards     Stat = "CARDS"
+
+	StatShots         Stat = "SHOTS"
+	StatShotsOnTarget Stat = "SHOTS_ON_TARGET"
+	StatAssists       Stat = "ASSISTS"
+	StatAces          Stat = "ACES"
+	StatDoubleFaults  Stat = "DOUBLE_FAULTS"
+
+	// StatWinner and StatRelegation settle outrights on the final standings.
+	StatWinner     Stat = "WINNER"
+	StatRelegation Stat = "RELEGATION"
+)
+
+// Period is FT for the full event, H1/H2 for halves, S1..S5 for sets,
+// S<set>G<game> for a game within a set, S<set>TB for a set's tie-break and
+// G<group> for a group stage of a competition.
+type Period string
+
+const (
+	PeriodFullTime   Period = "FT"
+	PeriodFirstHalf  Period = "H1"
+	PeriodSecondHalf Period = "H2"
+)
+
+// SetPeriod returns the period of the n-th set.
+func SetPeriod(n int) Period {
+	return Period(fmt.Sprintf("S%d", n))
+}
+
+// GamePeriod returns the period of the given game of a set.
+func GamePeriod(set, game int) Period {
+	return Period(fmt.Sprintf("S%dG%d", set, game))
+}
+
+// TieBreakPeriod returns the period of the tie-break of a set.
+func TieBreakPeriod(set int) Period {
+	return Period(fmt.Sprintf("S%dTB", set))
+}
+
+// GroupPeriod returns the period of a group stage, e.g. GA for group A.
+func GroupPeriod(group string) Period {
+	return Period("G" + strings.ToUpper(group))
+}
+
+// Set returns the set index of a set, game or tie-break period, 0 otherwise.
+func (p Period) Set() int {
+	if match := periodPattern.FindStringSubmatch(string(p)); match != nil && match[1] != "" {
+		set, _ := strconv.Atoi(match[1])
+		return set
+	}
+	return 0
+}
+
+// Game returns the game index of a game period, 0 otherwise.
+func (p Period) Game() int {
+	if match := periodPattern.FindStringSubmatch(string(p)); match != nil && match[2] != "" {
+		game, _ := strconv.Atoi(match[2])
+		return game
+	}
+	return 0
+}
+
+// Group returns the group of a group stage period, "" otherwise.
+func (p Period) Group() string {
+	if match := periodPattern.FindStringSubmatch(string(p)); match != nil {
+		return match[3]
+	}
+	return ""
+}
+
+// TieBreak reports whether p is the tie-break of a set.
+func (p Period) TieBreak() bool {
+	return strings.HasSuffix(string(p), "TB") && p.Set() > 0
+}
+
+// Scope tells whose statistic is counted.
+type Scope string
+
+const (
+	ScopeMatch Scope = "MATCH"
+	ScopeHome  Scope = "HOME"
+	ScopeAway  Scope = "AWAY"
+	// ScopePlayer counts the statistic of a single player, named by the
+	// market's participant ID.
+	ScopePlayer Scope = "PLAYER"
+	// ScopeParticipant is the team or player an outright selection backs.
+	ScopeParticipant Scope = "PARTICIPANT"
+)
+
+type Selection string
+
+const (
+	SelectionHome  Selection = "HOME"
+	SelectionDraw  Selection = "DRAW"
+	SelectionAway  Selection = "AWAY"
+	SelectionOver  Selection = "OVER"
+	SelectionUnder Selection = "UNDER"
+	SelectionYes   Selection = "YES"
+	SelectionNo    Selection = "NO"
+
+	SelectionHomeDraw Selection = "HOME_DRAW"
+	SelectionHomeAway Selection = "HOME_AWAY"
+	SelectionDrawAway Selection = "DRAW_AWAY"
+)
+
+// HalfTimeFullTimeSelection returns the selection of a half time/full time
+// market from the two results.
+func HalfTimeFullTimeSelection(halfTime, fullTime Selection) Selection {
+	return halfTime + "/" + fullTime
+}
+
+// ScoreSelection returns the selection of a correct score market.
+func ScoreSelection(home, away int) Selection {
+	return Selection(fmt.Sprintf("%d-%d", home, away))
+}
+
+var (
+	kinds = map[Kind]struct{}{
+		KindWinner: {}, KindHandicap: {}, KindTotal: {}, KindCorrectScore: {}, KindYesNo: {},
+		KindBothTeamsScore: {}, KindDoubleChance: {}, KindDrawNoBet: {}, KindHandicap3Way: {}, KindHalfTimeFullTime: {},
+		KindOutright: {},
+	}
+	stats = map[Stat]struct{}{
+		StatGoals: {}, StatSets: {}, StatGames: {}, StatPoints: {}, StatTieBreaks: {}, StatCorners: {}, StatCards: {},
+		StatShots: {}, StatShotsOnTarget: {}, StatAssists: {}, StatAces: {}, StatDoubleFaults: {},
+		StatWinner: {}, StatRelegation: {},
+	}
+	scopes     = map[Scope]struct{}{ScopeMatch: {}, ScopeHome: {}, ScopeAway: {}, ScopePlayer: {}, ScopeParticipant: {}}
+	selections = map[Selection]struct{}{
+		SelectionHome: {}, SelectionDraw: {}, SelectionAway: {}, SelectionOver: {}, SelectionUnder: {},
+		SelectionYes: {}, SelectionNo: {}, SelectionHomeDraw: {}, SelectionHomeAway: {}, SelectionDrawAway: {},
+	}
+
+	// periodPattern captures the set and game index of set based periods and
+	// the group of group stages.
+	periodPattern = regexp.MustCompile(`^(?:FT|H[12]|S([1-5])(?:G([1-9][0-9]?)|TB)?|G([A-Z]))$`)
+	scorePattern  = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+	htftPattern   = regexp.MustCompile(`^(HOME|DRAW|AWAY)/(HOME|DRAW|AWAY)$`)
+	// participantPattern matches the participant IDs of the participant registry.
+	participantPattern = regexp.MustCompile(`^p[0-9a-f]{12}$`)
+)
+
+// Market identifies a single outcome in canonical form.
+type Market struct {
+	Kind      Kind      `json:"kind"`
+	Stat      Stat      `json:"stat"`
+	Period    Period    `json:"period"`
+	Scope     Scope     `json:"scope"`
+	Selection Selection `json:"selection"`
+	Line      *float64  `json:"line,omitempty"`
+	// Participant is the participant ID of a player or participant scoped market.
+	Participant string `json:"participant,omitempty"`
+}
+
+// MarshalJSON adds the set and game indices and the group of the period, so
+// consumers do not have to parse it.
+func (m Market) MarshalJSON() ([]byte, error) {
+	type plain Market
+	return json.Marshal(struct {
+		plain
+		Set   int    `json:"set,omitempty"`
+		Game  int    `json:"game,omitempty"`
+		Group string `json:"group,omitempty"`
+	}{plain(m), m.Period.Set(), m.Period.Game(), m.Period.Group()})
+}
+
+// WithLine returns a copy of m with the given line.
+func (m Market) WithLine(line float64) Market {
+	m.Line = &line
+	return m
+}
+
+// Code returns the stable string code of the outcome.
+func (m Market) Code() string {
+	code := strings.Join([]string{string(m.Kind), string(m.Stat), string(m.Period), m.scope(), string(m.Selection)}, ":")
+	if m.Line != nil {
+		code += "@" + strconv.FormatFloat(*m.Line, 'f', -1, 64)
+	}
+	return code
+}
+
+// MarketCode is Code without the selection and line, it is shared by all
+// outcomes of one market.
+func (m Market) MarketCode() string {
+	return strings.Join([]string{string(m.Kind), string(m.Stat), string(m.Period), m.scope()}, ":")
+}
+
+// scope is the scope part of the code, with the participant of player markets.
+func (m Market) scope() string {
+	if m.Participant != "" {
+		return string(m.Scope) + "/" + m.Participant
+	}
+	return string(m.Scope)
+}
+
+func (m Market) String() string {
+	return m.Code()
+}
+
+// Validate checks that every part of m is a known value.
+func (m Market) Validate() error {
+	if _, ok := kinds[m.Kind]; !ok {
+		return fmt.Errorf("unknown market kind %q", m.Kind)
+	}
+	if _, ok := stats[m.Stat]; !ok {
+		return fmt.Errorf("unknown market stat %q", m.Stat)
+	}
+	if !periodPattern.MatchString(string(m.Period)) {
+		return fmt.Errorf("unknown market period %q", m.Period)
+	}
+	if _, ok := scopes[m.Scope]; !ok {
+		return fmt.Errorf("unknown market scope %q", m.Scope)
+	}
+	if m.Scope == ScopePlayer || m.Scope == ScopeParticipant {
+		if !participantPattern.MatchString(m.Participant) {
+			return fmt.Errorf("invalid market participant %q", m.Participant)
+		}
+	} else if m.Participant != "" {
+		return fmt.Errorf("market participant %q outside player and participant scope", m.Participant)
+	}
+	if m.Kind == KindCorrectScore {
+		if !scorePattern.MatchString(string(m.Selection)) {
+			return fmt.Errorf("invalid correct score selection %q", m.Selection)
+		}
+	} else if m.Kind == KindHalfTimeFullTime {
+		if !htftPattern.MatchString(string(m.Selection)) {
+			return fmt.Errorf("invalid half time/full time selection %q", m.Selection)
+		}
+	} else if _, ok := selections[m.Selection]; !ok {
+		return fmt.Errorf("unknown market selection %q", m.Selection)
+	}
+	return nil
+}
+
+// Parse is the inverse of Code.
+func Parse(code string) (Market, error) {
+	var m Market
+
+	body, line, hasLine := strings.Cut(code, "@")
+	parts := strings.Split(body, ":")
+	if len(parts) != 5 {
+		return m, fmt.Errorf("invalid market code %q: expected 5 parts, got %d", code, len(parts))
+	}
+
+	scope, participant, _ := strings.Cut(parts[3], "/")
+	m = Market{
+		Kind:        Kind(parts[0]),
+		Stat:        Stat(parts[1]),
+		Period:      Period(parts[2]),
+		Scope:       Scope(scope),
+		Selection:   Selection(parts[4]),
+		Participant: participant,
+	}
+	if hasLine {
+		value, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return Market{}, fmt.Errorf("invalid market code %q: bad line: %w", code, err)
+		}
+		m.Line = &value
+	}
+
+	if err := m.Validate(); err != nil {
+		return Market{}, fmt.Errorf("invalid market code %q: %w", code, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,145 @@
+package market
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func line(value float64) *float64 {
+	return &value
+}
+
+func equal(a, b Market) bool {
+	if (a.Line == nil) != (b.Line == nil) || (a.Line != nil && *a.Line != *b.Line) {
+		return false
+	}
+	a.Line, b.Line = nil, nil
+	return a == b
+}
+
+func TestCodeParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		market Market
+		code   string
+	}{
+		{Market{Kind: KindWinner, Stat: StatGoals, Period: PeriodFullTime, Scope: ScopeMatch, Selection: SelectionDraw}, "WIN:GOALS:FT:MATCH:DRAW"},
+		{Market{Kind: KindWinner, Stat: StatSets, Period: PeriodFullTime, Scope: ScopeMatch, Selection: SelectionHome}, "WIN:SETS:FT:MATCH:HOME"},
+		{Market{Kind: KindHandicap, Stat: StatGoals, Period: PeriodFirstHalf, Scope: ScopeMatch, Selection: SelectionAway, Line: line(-1.5)}, "HCP:GOALS:H1:MATCH:AWAY@-1.5"},
+		{Market{Kind: KindHandicap, Stat: StatGames, Period: PeriodFullTime, Scope: ScopeMatch, Selection: SelectionHome, Line: line(0)}, "HCP:GAMES:FT:MATCH:HOME@0"},
+		{Market{Kind: KindTotal, Stat: StatGames, Period: SetPeriod(2), Scope: ScopeMatch, Selection: SelectionOver, Line: line(9.5)}, "TOTAL:GAMES:S2:MATCH:OVER@9.5"},
+		{Market{Kind: KindTotal, Stat: StatCorners, Period: PeriodSecondHalf, Scope: ScopeHome, Selection: SelectionUnder, Line: line(4.25)}, "TOTAL:CORNERS:H2:HOME:UNDER@4.25"},
+		{Market{Kind: KindTotal, Stat: StatShotsOnTarget, Period: PeriodFullTime, Scope: ScopeAway, Selection: SelectionOver, Line: line(3.5)}, "TOTAL:SHOTS_ON_TARGET:FT:AWAY:OVER@3.5"},
+		{Market{Kind: KindTotal, Stat: StatPoints, Period: GamePeriod(1, 3), Scope: ScopeMatch, Selection: SelectionUnder, Line: line(6.5)}, "TOTAL:POINTS:S1G3:MATCH:UNDER@6.5"},
+		{Market{Kind: KindCorrectScore, Stat: StatGames, Period: SetPeriod(1), Scope: ScopeMatch, Selection: ScoreSelection(6, 4)}, "CS:GAMES:S1:MATCH:6-4"},
+		{Market{Kind: KindCorrectScore, Stat: StatGoals, Period: PeriodFullTime, Scope: ScopeMatch, Selection: ScoreSelection(10, 0)}, "CS:GOALS:FT:MATCH:10-0"},
+		{Market{Kind: KindYesNo, Stat: StatTieBreaks, Period: TieBreakPeriod(3), Scope: ScopeMatch, Selection: SelectionYes}, "YN:TIEBREAKS:S3TB:MATCH:YES"},
+		{Market{Kind: KindWinner, Stat: StatGames, Period: GamePeriod(2, 12), Scope: ScopeMatch, Selection: SelectionAway}, "WIN:GAMES:S2G12:MATCH:AWAY"},
+		{Market{Kind: KindBothTeamsScore, Stat: StatGoals, Period: PeriodFullTime, Scope: ScopeMatch, Selection: SelectionNo}, "BTTS:GOALS:FT:MATCH:NO"},
+		{Market{Kind: KindDoubleChance, Stat: StatGoals, Period: PeriodFullTime, Scope: ScopeMatch, Selection: SelectionHomeDraw}, "DC:GOALS:FT:MATCH:HOME_DRAW"},
+		{Market{Kind: KindDrawNoBet, Stat: StatGoals, Period: PeriodFirstHalf, Scope: ScopeMatch, Selection: SelectionAway}, "DNB:GOALS:H1:MATCH:AWAY"},
+		{Market{Kind: KindHandicap3Way, Stat: StatGoals, Period: PeriodFullTime, Scope: ScopeMatch, Selection: SelectionDraw, Line: line(-1)}, "HCP3:GOALS:FT:MATCH:DRAW@-1"},
+		{Market{Kind: KindHalfTimeFullTime, Stat: StatGoals, Period: PeriodFullTime, Scope: ScopeMatch, Selection: HalfTimeFullTimeSelection(SelectionHome, SelectionDraw)}, "HTFT:GOALS:FT:MATCH:HOME/DRAW"},
+		{Market{Kind: KindTotal, Stat: StatCards, Period: PeriodFullTime, Scope: ScopeMatch, Selection: SelectionOver, Line: line(4.5)}, "TOTAL:CARDS:FT:MATCH:OVER@4.5"},
+		{Market{Kind: KindTotal, Stat: StatShots, Period: PeriodFullTime, Scope: ScopePlayer, Participant: "p1a2b3c4d5e6f", Selection: SelectionOver, Line: line(1.5)}, "TOTAL:SHOTS:FT:PLAYER/p1a2b3c4d5e6f:OVER@1.5"},
+		{Market{Kind: KindYesNo, Stat: StatGoals, Period: PeriodFullTime, Scope: ScopePlayer, Participant: "p000000000000", Selection: SelectionYes}, "YN:GOALS:FT:PLAYER/p000000000000:YES"},
+		{Market{Kind: KindTotal, Stat: StatAces, Period: SetPeriod(1), Scope: ScopeHome, Selection: SelectionOver, Line: line(2.5)}, "TOTAL:ACES:S1:HOME:OVER@2.5"},
+		{Market{Kind: KindTotal, Stat: StatDoubleFaults, Period: PeriodFullTime, Scope: ScopeMatch, Selection: SelectionUnder, Line: line(5.5)}, "TOTAL:DOUBLE_FAULTS:FT:MATCH:UNDER@5.5"},
+		{Market{Kind: KindTotal, Stat: StatAssists, Period: PeriodFullTime, Scope: ScopePlayer, Participant: "pabcdefabcdef", Selection: SelectionOver, Line: line(0.5)}, "TOTAL:ASSISTS:FT:PLAYER/pabcdefabcdef:OVER@0.5"},
+		{Market{Kind: KindOutright, Stat: StatWinner, Period: PeriodFullTime, Scope: ScopeParticipant, Participant: "p1a2b3c4d5e6f", Selection: SelectionYes}, "OUTRIGHT:WINNER:FT:PARTICIPANT/p1a2b3c4d5e6f:YES"},
+		{Market{Kind: KindOutright, Stat: StatWinner, Period: GroupPeriod("a"), Scope: ScopeParticipant, Participant: "p1a2b3c4d5e6f", Selection: SelectionYes}, "OUTRIGHT:WINNER:GA:PARTICIPANT/p1a2b3c4d5e6f:YES"},
+		{Market{Kind: KindOutright, Stat: StatRelegation, Period: PeriodFullTime, Scope: ScopeParticipant, Participant: "p1a2b3c4d5e6f", Selection: SelectionYes}, "OUTRIGHT:RELEGATION:FT:PARTICIPANT/p1a2b3c4d5e6f:YES"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if err := tt.market.Validate(); err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if code := tt.market.Code(); code != tt.code {
+				t.Fatalf("Code() = %q, want %q", code, tt.code)
+			}
+			parsed, err := Parse(tt.code)
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+			if !equal(parsed, tt.market) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.code, parsed, tt.market)
+			}
+			if parsed.Code() != tt.code {
+				t.Errorf("Parse(%q).Code() = %q", tt.code, parsed.Code())
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	codes := []string{
+		"",
+		"WIN:GOALS:FT:MATCH",
+		"WIN:GOALS:FT:MATCH:DRAW:EXTRA",
+		"WINNER:GOALS:FT:MATCH:DRAW",
+		"WIN:HITS:FT:MATCH:DRAW",
+		"WIN:GOALS:H3:MATCH:DRAW",
+		"WIN:GOALS:S6:MATCH:DRAW",
+		"WIN:GOALS:S1G0:MATCH:DRAW",
+		"WIN:GOALS:S1G100:MATCH:DRAW",
+		"WIN:GOALS:Gab:MATCH:DRAW",
+		"WIN:GOALS:FT:TEAM:DRAW",
+		"WIN:GOALS:FT:MATCH:MAYBE",
+		"WIN:GOALS:FT:MATCH/p1a2b3c4d5e6f:DRAW",
+		"TOTAL:SHOTS:FT:PLAYER:OVER@1.5",
+		"TOTAL:SHOTS:FT:PLAYER/arsenal:OVER@1.5",
+		"TOTAL:GOALS:FT:MATCH:OVER@two",
+		"CS:GOALS:FT:MATCH:HOME",
+		"CS:GOALS:FT:MATCH:2:1",
+		"HTFT:GOALS:FT:MATCH:HOME",
+		"HTFT:GOALS:FT:MATCH:HOME/OVER",
+	}
+	for _, code := range codes {
+		if m, err := Parse(code); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", code, m)
+		}
+	}
+}
+
+func TestPeriodParts(t *testing.T) {
+	tests := []struct {
+		period   Period
+		set      int
+		game     int
+		group    string
+		tieBreak bool
+	}{
+		{PeriodFullTime, 0, 0, "", false},
+		{PeriodFirstHalf, 0, 0, "", false},
+		{SetPeriod(3), 3, 0, "", false},
+		{GamePeriod(2, 11), 2, 11, "", false},
+		{TieBreakPeriod(5), 5, 0, "", true},
+		{GroupPeriod("c"), 0, 0, "C", false},
+	}
+	for _, tt := range tests {
+		if set, game, group, tieBreak := tt.period.Set(), tt.period.Game(), tt.period.Group(), tt.period.TieBreak(); set != tt.set || game != tt.game || group != tt.group || tieBreak != tt.tieBreak {
+			t.Errorf("%s: set %d game %d group %q tie-break %v, want %d %d %q %v", tt.period, set, game, group, tieBreak, tt.set, tt.game, tt.group, tt.tieBreak)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	m := Market{Kind: KindTotal, Stat: StatPoints, Period: GamePeriod(1, 3), Scope: ScopeMatch, Selection: SelectionOver, Line: line(6.5)}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"kind":"TOTAL","stat":"POINTS","period":"S1G3","scope":"MATCH","selection":"OVER","line":6.5,"set":1,"game":3}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	var decoded Market
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(decoded, m) {
+		t.Errorf("decoded %+v, want %+v", decoded, m)
+	}
+}
@@ -0,0 +1,153 @@
+package oddspb
+
+import (
+	"strconv"
+	"strings"
+
+	"test_task_app/helper"
+	"test_task_app/status"
+)
+
+// Matches reports whether the event passes the event level criteria of f, a
+// nil filter matches every event.
+func (f *Filter) Matches(data helper.ProcessedData) bool {
+	if f == nil {
+		return true
+	}
+	return anyFold(f.Sports, data.Sport) &&
+		anyFold(f.Operators, data.Operator) &&
+		anyFold(f.Types, data.Type) &&
+		anyID(f.EventIds, int64(data.EventID))
+}
+
+// NewEvent converts a snapshot, grouping its outcomes by market and line and
+// keeping the markets that pass the market level criteria of f, which may be
+// nil. Markets closed since they were first seen are kept without outcomes.
+func NewEvent(data helper.ProcessedData, f *Filter) *Event {
+	event := &Event{
+		Key:           data.StorageKey(),
+		Provider:      data.Provider,
+		Operator:      data.Operator,
+		EventId:       int64(data.EventID),
+		Type:          data.Type,
+		MatchName:     data.MatchName,
+		StartTime:     data.StartTime,
+		Sport:         data.Sport,
+		League:        data.League,
+		Country:       data.Country,
+		Region:        data.Region,
+		CompetitionId: data.CompetitionID,
+		TourLevel:     data.TourLevel,
+		Gender:        data.Gender,
+		Time:          data.Time,
+	}
+	if data.Type != helper.TypeOutright {
+		event.Home = &Participant{Id: data.HomeID, Name: data.HomeTeam}
+		event.Away = &Participant{Id: data.AwayID, Name: data.AwayTeam}
+	}
+	for _, player := range data.Players {
+		event.Players = append(event.Players, &Participant{Id: player.ID, Name: player.Name, ProviderId: int64(player.ProviderID)})
+	}
+
+	statuses := make(map[string]status.Market, len(data.Markets))
+	for _, m := range data.Markets {
+		statuses[m.Code] = m
+	}
+
+	markets := make(map[string]*Market)
+	for _, outcome := range data.Outcomes {
+		if outcome.Market == nil {
+			continue
+		}
+		code := outcome.Market.MarketCode()
+		group := code
+		if outcome.Market.Line != nil {
+			group += "@" + strconv.FormatFloat(*outcome.Market.Line, 'f', -1, 64)
+		}
+		m, ok := markets[group]
+		if !ok {
+			m = &Market{
+				Code:        code,
+				Kind:        string(outcome.Market.Kind),
+				Stat:        string(outcome.Market.Stat),
+				Period:      string(outcome.Market.Period),
+				Scope:       string(outcome.Market.Scope),
+				Participant: outcome.Market.Participant,
+				Line:        outcome.Market.Line,
+				Status:      outcome.Status,
+				StatusSince: outcome.StatusSince,
+			}
+			if s, ok := statuses[code]; ok {
+				m.Status, m.StatusSince = s.Status, s.Since
+			}
+			markets[group] = m
+			event.Markets = append(event.Markets, m)
+		}
+		m.Outcomes = append(m.Outcomes, &Outcome{
+			Code:        outcome.Type,
+			Selection:   string(outcome.Market.Selection),
+			Label:       outcome.Label,
+			Odds:        outcome.Odds,
+			Status:      outcome.Status,
+			StatusSince: outcome.StatusSince,
+			OutcomeId:   int64(outcome.ID),
+			BetOfferId:  int64(outcome.BetOfferID),
+		})
+	}
+	offered := make(map[string]bool, len(markets))
+	for _, m := range markets {
+		offered[m.Code] = true
+	}
+	for _, s := range data.Markets {
+		if s.Status == status.Closed && !offered[s.Code] {
+			event.Markets = append(event.Markets, closedMarket(s))
+		}
+	}
+
+	if f != nil && (len(f.MarketKinds) > 0 || len(f.MarketStatuses) > 0) {
+		kept := event.Markets[:0]
+		for _, m := range event.Markets {
+			if anyFold(f.MarketKinds, m.Kind) && anyFold(f.MarketStatuses, m.Status) {
+				kept = append(kept, m)
+			}
+		}
+		event.Markets = kept
+	}
+	return event
+}
+
+// closedMarket describes a market that is no longer offered from its code,
+// KIND:STAT:PERIOD:SCOPE with the participant ID after a slash in the scope.
+func closedMarket(s status.Market) *Market {
+	m := &Market{Code: s.Code, Status: s.Status, StatusSince: s.Since}
+	parts := strings.SplitN(s.Code, ":", 4)
+	if len(parts) == 4 {
+		m.Kind, m.Stat, m.Period = parts[0], parts[1], parts[2]
+		m.Scope, m.Participant, _ = strings.Cut(parts[3], "/")
+	}
+	return m
+}
+
+func anyFold(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyID(ids []int64, id int64) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
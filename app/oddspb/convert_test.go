@@ -0,0 +1,35 @@
+package oddspb
+
+import (
+	"testing"
+
+	"test_task_app/helper"
+	"test_task_app/market"
+	"test_task_app/status"
+)
+
+func TestNewEventClosedMarkets(t *testing.T) {
+	result := &market.Market{Kind: "WIN", Stat: "GOALS", Period: "FT", Scope: "MATCH", Selection: "HOME"}
+	data := helper.ProcessedData{
+		Provider: "unibet",
+		Operator: "ubbe",
+		EventID:  1,
+		Outcomes: []helper.Outcome{{Type: "1", Odds: 1.8, Status: status.Closed, Market: result}},
+		Markets: []status.Market{
+			{Code: result.MarketCode(), Status: status.Closed},
+			{Code: "TOTAL:GOALS:FT:MATCH", Status: status.Closed},
+		},
+	}
+
+	event := NewEvent(data, nil)
+	if event.Key != "unibet-ubbe-1" {
+		t.Errorf("key %s, want unibet-ubbe-1", event.Key)
+	}
+	codes := make(map[string]int)
+	for _, m := range event.Markets {
+		codes[m.Code]++
+	}
+	if codes[result.MarketCode()] != 1 || codes["TOTAL:GOALS:FT:MATCH"] != 1 || len(event.Markets) != 2 {
+		t.Errorf("markets %v, want each closed market once", codes)
+	}
+}
@@ -0,0 +1,5 @@
+// Package oddspb holds the protobuf messages and gRPC service of the odds API,
+// generated from odds.proto.
+package oddspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative odds.proto
@@ -0,0 +1,212 @@
+// Odds API of the parser: the latest normalized snapshot of every event and
+// a stream of updates, the typed counterpart of the /ws JSON feed.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: odds.proto
+
+package oddspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Odds_GetEvent_FullMethodName   = "/odds.v1.Odds/GetEvent"
+	Odds_ListEvents_FullMethodName = "/odds.v1.Odds/ListEvents"
+	Odds_Subscribe_FullMethodName  = "/odds.v1.Odds/Subscribe"
+)
+
+// OddsClient is the client API for Odds service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type OddsClient interface {
+	// GetEvent returns the latest snapshot of one event.
+	GetEvent(ctx context.Context, in *GetEventRequest, opts ...grpc.CallOption) (*Event, error)
+	// ListEvents returns the latest snapshot of every event matching the filter.
+	ListEvents(ctx context.Context, in *ListEventsRequest, opts ...grpc.CallOption) (*ListEventsResponse, error)
+	// Subscribe streams every update of the events matching the filter, starting
+	// with their current snapshots when include_snapshot is set.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type oddsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOddsClient(cc grpc.ClientConnInterface) OddsClient {
+	return &oddsClient{cc}
+}
+
+func (c *oddsClient) GetEvent(ctx context.Context, in *GetEventRequest, opts ...grpc.CallOption) (*Event, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Event)
+	err := c.cc.Invoke(ctx, Odds_GetEvent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oddsClient) ListEvents(ctx context.Context, in *ListEventsRequest, opts ...grpc.CallOption) (*ListEventsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListEventsResponse)
+	err := c.cc.Invoke(ctx, Odds_ListEvents_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oddsClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Odds_ServiceDesc.Streams[0], Odds_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Odds_SubscribeClient = grpc.ServerStreamingClient[Event]
+
+// OddsServer is the server API for Odds service.
+// All implementations must embed UnimplementedOddsServer
+// for forward compatibility.
+type OddsServer interface {
+	// GetEvent returns the latest snapshot of one event.
+	GetEvent(context.Context, *GetEventRequest) (*Event, error)
+	// ListEvents returns the latest snapshot of every event matching the filter.
+	ListEvents(context.Context, *ListEventsRequest) (*ListEventsResponse, error)
+	// Subscribe streams every update of the events matching the filter, starting
+	// with their current snapshots when include_snapshot is set.
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedOddsServer()
+}
+
+// UnimplementedOddsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedOddsServer struct{}
+
+func (UnimplementedOddsServer) GetEvent(context.Context, *GetEventRequest) (*Event, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEvent not implemented")
+}
+func (UnimplementedOddsServer) ListEvents(context.Context, *ListEventsRequest) (*ListEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListEvents not implemented")
+}
+func (UnimplementedOddsServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedOddsServer) mustEmbedUnimplementedOddsServer() {}
+func (UnimplementedOddsServer) testEmbeddedByValue()              {}
+
+// UnsafeOddsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OddsServer will
+// result in compilation errors.
+type UnsafeOddsServer interface {
+	mustEmbedUnimplementedOddsServer()
+}
+
+func RegisterOddsServer(s grpc.ServiceRegistrar, srv OddsServer) {
+	// If the following call pancis, it indicates UnimplementedOddsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Odds_ServiceDesc, srv)
+}
+
+func _Odds_GetEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OddsServer).GetEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Odds_GetEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OddsServer).GetEvent(ctx, req.(*GetEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Odds_ListEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OddsServer).ListEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Odds_ListEvents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OddsServer).ListEvents(ctx, req.(*ListEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Odds_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OddsServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Odds_SubscribeServer = grpc.ServerStreamingServer[Event]
+
+// Odds_ServiceDesc is the grpc.ServiceDesc for Odds service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Odds_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "odds.v1.Odds",
+	HandlerType: (*OddsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetEvent",
+			Handler:    _Odds_GetEvent_Handler,
+		},
+		{
+			MethodName: "ListEvents",
+			Handler:    _Odds_ListEvents_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Odds_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "odds.proto",
+}
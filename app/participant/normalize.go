@@ -0,0 +1,114 @@
+package participant
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// qualifiers distinguish different teams of the same club, they are kept in
+// the normalized name in a canonical spelling.
+var qualifiers = []struct {
+	pattern *regexp.Regexp
+	token   string
+}{
+	{regexp.MustCompile(`\b(u|under)[ -]?(\d{2})\b`), "u$2"},
+	{regexp.MustCompile(`\b(women|womens|woman|dames|vrouwen|femmes|frauen|damer)\b`), "women"},
+	{regexp.MustCompile(`\b(reserves?|ii)\b`), "reserves"},
+}
+
+// abbreviations are expanded before comparison.
+var abbreviations = map[string]string{
+	"utd":  "united",
+	"st":   "saint",
+	"ste":  "sainte",
+	"intl": "international",
+	"atl":  "atletico",
+	"dep":  "deportivo",
+}
+
+// noise words carry no identity and are dropped.
+var noise = map[string]struct{}{
+	"fc": {}, "cf": {}, "afc": {}, "sc": {}, "ac": {}, "fk": {}, "sk": {}, "bk": {}, "if": {},
+	"cd": {}, "club": {}, "the": {}, "de": {}, "kv": {}, "krc": {}, "rsc": {}, "kaa": {},
+}
+
+var (
+	nonAlnum    = regexp.MustCompile(`[^a-z0-9 ]+`)
+	doublesSep  = regexp.MustCompile(`\s*/\s*`)
+	spaces      = regexp.MustCompile(`\s+`)
+	diacritics  = runes.Remove(runes.In(unicode.Mn))
+	transliters = strings.NewReplacer("ß", "ss", "ø", "o", "Ø", "O", "æ", "ae", "Æ", "AE", "ł", "l", "Ł", "L", "đ", "d", "Đ", "D", "ı", "i")
+)
+
+// DisplayName turns Kambi names into the form shown to users: tennis style
+// "Last, First" becomes "First Last", and tennis doubles pairs are kept as
+// "A / B".
+func DisplayName(sport, name string) string {
+	players := splitPair(sport, strings.TrimSpace(name))
+	for i, player := range players {
+		if last, first, ok := strings.Cut(player, ","); ok {
+			player = strings.TrimSpace(first) + " " + strings.TrimSpace(last)
+		}
+		players[i] = strings.TrimSpace(player)
+	}
+	return strings.Join(players, " / ")
+}
+
+// Normalize returns the comparison key of a participant name. Names that
+// differ only in diacritics, punctuation, legal suffixes, abbreviations or
+// the order of a tennis doubles pair normalize to the same key.
+func Normalize(sport, name string) string {
+	players := splitPair(sport, DisplayName(sport, name))
+	for i, player := range players {
+		players[i] = normalizeSingle(player)
+	}
+	if len(players) > 1 {
+		sort.Strings(players)
+	}
+	return strings.Join(players, " / ")
+}
+
+// splitPair splits a tennis doubles pair into its players. Other sports keep
+// the slash, it is part of names like Bodø/Glimt.
+func splitPair(sport, name string) []string {
+	if !strings.EqualFold(sport, "tennis") {
+		return []string{name}
+	}
+	return doublesSep.Split(name, -1)
+}
+
+func normalizeSingle(name string) string {
+	name = transliters.Replace(name)
+	name, _, _ = transform.String(transform.Chain(norm.NFD, diacritics, norm.NFC), name)
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("-", " ", ".", " ", "'", "", "&", " and ").Replace(name)
+	name = nonAlnum.ReplaceAllString(name, " ")
+
+	for _, q := range qualifiers {
+		name = q.pattern.ReplaceAllString(name, "\x00"+q.token)
+	}
+
+	var words, suffixes []string
+	for _, word := range strings.Fields(name) {
+		if strings.HasPrefix(word, "\x00") {
+			suffixes = append(suffixes, strings.TrimPrefix(word, "\x00"))
+			continue
+		}
+		if full, ok := abbreviations[word]; ok {
+			word = full
+		}
+		if _, ok := noise[word]; ok {
+			continue
+		}
+		words = append(words, word)
+	}
+	sort.Strings(suffixes)
+
+	return spaces.ReplaceAllString(strings.TrimSpace(strings.Join(append(words, suffixes...), " ")), " ")
+}
@@ -0,0 +1,44 @@
+package participant
+
+import "testing"
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		sport, name string
+		want        string
+	}{
+		{"Tennis", "Djokovic, Novak", "Novak Djokovic"},
+		{"Tennis", "Krawietz, Kevin/Puetz, Tim", "Kevin Krawietz / Tim Puetz"},
+		{"FOOTBALL", "Bodø/Glimt", "Bodø/Glimt"},
+		{"FOOTBALL", "Saka, Bukayo", "Bukayo Saka"},
+	}
+	for _, tt := range tests {
+		if got := DisplayName(tt.sport, tt.name); got != tt.want {
+			t.Errorf("DisplayName(%q, %q) = %q, want %q", tt.sport, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		sport, a, b string
+		same        bool
+	}{
+		{"Tennis", "Krawietz, Kevin / Puetz, Tim", "Tim Puetz/Kevin Krawietz", true},
+		{"Football", "Bodø/Glimt", "FK Bodo/Glimt", true},
+		{"Football", "Bodø/Glimt", "Glimt/Bodø", false},
+		{"Football", "Man Utd", "Manchester United", false},
+		{"Football", "Atl. Madrid", "Atletico Madrid", true},
+		{"Football", "Arsenal Women", "Arsenal", false},
+		{"Football", "Jong Ajax II", "Jong Ajax Reserves", true},
+	}
+	for _, tt := range tests {
+		a, b := Normalize(tt.sport, tt.a), Normalize(tt.sport, tt.b)
+		if (a == b) != tt.same {
+			t.Errorf("Normalize(%q, %q) = %q, Normalize(%q, %q) = %q, want same %v", tt.sport, tt.a, a, tt.sport, tt.b, b, tt.same)
+		}
+	}
+	if got := Normalize("Football", "Bodø/Glimt"); got != "bodo glimt" {
+		t.Errorf("Normalize(Football, Bodø/Glimt) = %q, want %q", got, "bodo glimt")
+	}
+}
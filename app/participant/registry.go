@@ -0,0 +1,195 @@
+package participant
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RegistryFileName is the file inside the data directory listing every
+// participant seen so far.
+const RegistryFileName = "participants.json"
+
+type Participant struct {
+	ID         string   `json:"id"`
+	Sport      string   `json:"sport"`
+	Name       string   `json:"name"`
+	Normalized string   `json:"normalized"`
+	Aliases    []string `json:"aliases,omitempty"`
+}
+
+// AliasFile maps, per sport, a canonical participant name to the other names
+// it is known by:
+//
+//	football:
+//	  Manchester United: [Man Utd, Man United]
+type AliasFile map[string]map[string][]string
+
+// Registry resolves participant names to stable IDs. The ID is derived from
+// the sport and the normalized canonical name, so it is the same across
+// restarts, providers and historical data.
+type Registry struct {
+	path         string
+	mu           sync.Mutex
+	aliases      map[string]string
+	participants map[string]*Participant
+	dirty        bool
+}
+
+// NewRegistry loads the participants already seen in dir and the alias file,
+// which may be empty.
+func NewRegistry(dir, aliasFile string) (*Registry, error) {
+	r := &Registry{
+		path:         filepath.Join(dir, RegistryFileName),
+		aliases:      make(map[string]string),
+		participants: make(map[string]*Participant),
+	}
+
+	if aliasFile != "" {
+		if err := r.loadAliases(aliasFile); err != nil {
+			return nil, fmt.Errorf("load aliases: %w", err)
+		}
+	}
+
+	data, err := os.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var participants []*Participant
+	if err := json.Unmarshal(data, &participants); err != nil {
+		return nil, err
+	}
+	for _, p := range participants {
+		r.participants[p.ID] = p
+	}
+	return r, nil
+}
+
+func (r *Registry) loadAliases(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file AliasFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	for sport, names := range file {
+		for canonical, aliases := range names {
+			target := Normalize(sport, canonical)
+			r.aliases[aliasKey(sport, canonical)] = target
+			for _, alias := range aliases {
+				r.aliases[aliasKey(sport, alias)] = target
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve returns the participant for name, registering it when it is new.
+func (r *Registry) Resolve(sport, name string) Participant {
+	normalized := Normalize(sport, name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if target, ok := r.aliases[aliasKey(sport, name)]; ok {
+		normalized = target
+	}
+	id := ID(sport, normalized)
+
+	p, ok := r.participants[id]
+	if !ok {
+		p = &Participant{
+			ID:         id,
+			Sport:      strings.ToLower(sport),
+			Name:       DisplayName(sport, name),
+			Normalized: normalized,
+		}
+		r.participants[id] = p
+		r.dirty = true
+		return *p
+	}
+
+	display := DisplayName(sport, name)
+	if display != p.Name && !contains(p.Aliases, display) {
+		p.Aliases = append(p.Aliases, display)
+		sort.Strings(p.Aliases)
+		r.dirty = true
+	}
+	return *p
+}
+
+// Get returns the participant with the given ID.
+func (r *Registry) Get(id string) (Participant, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.participants[id]
+	if !ok {
+		return Participant{}, false
+	}
+	return *p, true
+}
+
+// ID is the stable participant ID of a normalized name.
+func ID(sport, normalized string) string {
+	sum := sha1.Sum([]byte(strings.ToLower(sport) + "|" + normalized))
+	return "p" + hex.EncodeToString(sum[:6])
+}
+
+// Save persists the registry if participants were added since the last save.
+func (r *Registry) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.dirty {
+		return nil
+	}
+
+	participants := make([]*Participant, 0, len(r.participants))
+	for _, p := range r.participants {
+		participants = append(participants, p)
+	}
+	sort.Slice(participants, func(i, j int) bool { return participants[i].ID < participants[j].ID })
+
+	data, err := json.MarshalIndent(participants, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return err
+	}
+	r.dirty = false
+	return nil
+}
+
+func aliasKey(sport, name string) string {
+	return strings.ToLower(sport) + "|" + Normalize(sport, name)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// Package payload encodes the frames sent on /ws in the encodings a client
+// can pick at connect time: JSON text frames, MessagePack or protobuf binary
+// frames. Compression is negotiated separately, as permessage-deflate.
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"test_task_app/helper"
+	"test_task_app/market"
+	"test_task_app/oddspb"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Encodings of the frames.
+const (
+	// JSON is the default, a Frame as JSON.
+	JSON = "json"
+	// MessagePack carries the same Frame as JSON, with the same field names.
+	MessagePack = "msgpack"
+	// Protobuf frames are an oddspb.Frame.
+	Protobuf = "protobuf"
+)
+
+// Frame types.
+const (
+	// TypeSnapshot frames hold every current event, TypeReplay frames the
+	// latest version of the events a resuming client missed and TypeUpdate
+	// frames the events of one update batch.
+	TypeSnapshot = "snapshot"
+	TypeReplay   = "replay"
+	TypeUpdate   = "update"
+)
+
+// Frame is one message sent on /ws. Seq numbers the update batches within an
+// Epoch, which changes when the parser restarts; a snapshot is at least as
+// new as the batch with its Seq.
+type Frame struct {
+	Type  string `json:"type"`
+	Epoch string `json:"epoch"`
+	Seq   uint64 `json:"seq"`
+	// Events are keyed by storage key.
+	Events map[string]helper.ProcessedData `json:"events"`
+}
+
+// subprotocolPrefix prefixes the encodings offered as websocket subprotocols,
+// e.g. odds.msgpack.
+const subprotocolPrefix = "odds."
+
+var encodings = []string{JSON, MessagePack, Protobuf}
+
+func init() {
+	// Markets add the derived period details to their JSON, MessagePack
+	// frames carry them too.
+	msgpack.Register(market.Market{}, func(e *msgpack.Encoder, v reflect.Value) error {
+		type plain market.Market
+		m := v.Interface().(market.Market)
+		return e.Encode(struct {
+			plain
+			Set   int    `json:"set,omitempty"`
+			Game  int    `json:"game,omitempty"`
+			Group string `json:"group,omitempty"`
+		}{plain(m), m.Period.Set(), m.Period.Game(), m.Period.Group()})
+	}, nil)
+}
+
+// Subprotocols returns the websocket subprotocols a client may request, one
+// per encoding.
+func Subprotocols() []string {
+	protocols := make([]string, len(encodings))
+	for i, encoding := range encodings {
+		protocols[i] = subprotocolPrefix + encoding
+	}
+	return protocols
+}
+
+// Negotiate returns the encoding of a connection from the subprotocol agreed
+// on, falling back to the encoding query parameter and then to JSON.
+func Negotiate(subprotocol, query string) (string, error) {
+	if encoding, ok := strings.CutPrefix(subprotocol, subprotocolPrefix); ok {
+		return encoding, nil
+	}
+	if query == "" {
+		return JSON, nil
+	}
+	for _, encoding := range encodings {
+		if strings.EqualFold(query, encoding) {
+			return encoding, nil
+		}
+	}
+	return "", fmt.Errorf("unknown encoding %q, use one of %s", query, strings.Join(encodings, ", "))
+}
+
+// MessageType returns the websocket frame type of an encoding.
+func MessageType(encoding string) int {
+	if encoding == JSON {
+		return websocket.TextMessage
+	}
+	return websocket.BinaryMessage
+}
+
+// Encode encodes a frame.
+func Encode(encoding string, frame Frame) ([]byte, error) {
+	switch encoding {
+	case JSON:
+		return json.Marshal(frame)
+	case MessagePack:
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json")
+		enc.UseCompactInts(true)
+		if err := enc.Encode(frame); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Protobuf:
+		return proto.Marshal(NewFrame(frame))
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
+
+// NewFrame converts a frame, ordering its events by key.
+func NewFrame(frame Frame) *oddspb.Frame {
+	pb := &oddspb.Frame{
+		Type:   frame.Type,
+		Epoch:  frame.Epoch,
+		Seq:    frame.Seq,
+		Events: make([]*oddspb.Event, 0, len(frame.Events)),
+	}
+	for _, data := range frame.Events {
+		pb.Events = append(pb.Events, oddspb.NewEvent(data, nil))
+	}
+	sort.Slice(pb.Events, func(i, j int) bool { return pb.Events[i].Key < pb.Events[j].Key })
+	return pb
+}
@@ -0,0 +1,279 @@
+package payload
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"test_task_app/helper"
+	"test_task_app/market"
+	"test_task_app/oddspb"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	frame := Frame{Type: TypeUpdate, Epoch: "test", Seq: 7, Events: generate(3)}
+
+	t.Run(JSON, func(t *testing.T) {
+		data, err := Encode(JSON, frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Frame
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, frame) {
+			t.Errorf("decoded frame differs from the encoded one")
+		}
+		assertPeriodDetails(t, JSON, data, json.Unmarshal)
+	})
+
+	t.Run(MessagePack, func(t *testing.T) {
+		data, err := Encode(MessagePack, frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Frame
+		if err := decodeMessagePack(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, frame) {
+			t.Errorf("decoded frame differs from the encoded one")
+		}
+		assertPeriodDetails(t, MessagePack, data, decodeMessagePack)
+	})
+
+	t.Run(Protobuf, func(t *testing.T) {
+		data, err := Encode(Protobuf, frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got oddspb.Frame
+		if err := proto.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(&got, NewFrame(frame)) {
+			t.Errorf("decoded frame differs from the encoded one")
+		}
+		if len(got.Events) != len(frame.Events) {
+			t.Fatalf("got %d events, want %d", len(got.Events), len(frame.Events))
+		}
+		for i := 1; i < len(got.Events); i++ {
+			if got.Events[i-1].Key >= got.Events[i].Key {
+				t.Errorf("events not ordered by key: %q before %q", got.Events[i-1].Key, got.Events[i].Key)
+			}
+		}
+	})
+}
+
+func TestEncodeUnknown(t *testing.T) {
+	if _, err := Encode("xml", Frame{}); err == nil {
+		t.Error("encoding xml: want an error")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		subprotocol, query string
+		want               string
+		wantErr            bool
+	}{
+		{"", "", JSON, false},
+		{"odds.msgpack", "", MessagePack, false},
+		{"odds.protobuf", "json", Protobuf, false},
+		{"", "MsgPack", MessagePack, false},
+		{"", "xml", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Negotiate(tt.subprotocol, tt.query)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Negotiate(%q, %q) = %q, %v, want %q", tt.subprotocol, tt.query, got, err, tt.want)
+		}
+	}
+}
+
+// assertPeriodDetails checks that the markets of an encoded frame carry the
+// set, game and group of their period.
+func assertPeriodDetails(t *testing.T, encoding string, data []byte, decode func([]byte, interface{}) error) {
+	t.Helper()
+	var frame struct {
+		Events map[string]struct {
+			Outcomes []struct {
+				Market struct {
+					Period string `json:"period"`
+					Set    int    `json:"set"`
+					Game   int    `json:"game"`
+					Group  string `json:"group"`
+				} `json:"market"`
+			} `json:"outcomes"`
+		} `json:"events"`
+	}
+	if err := decode(data, &frame); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, event := range frame.Events {
+		for _, outcome := range event.Outcomes {
+			m := outcome.Market
+			period := market.Period(m.Period)
+			if m.Set != period.Set() || m.Game != period.Game() || m.Group != period.Group() {
+				t.Errorf("%s: period %s has set %d, game %d, group %q", encoding, m.Period, m.Set, m.Game, m.Group)
+			}
+			seen[m.Period] = true
+		}
+	}
+	for _, period := range []string{"S1G3", "GA"} {
+		if !seen[period] {
+			t.Errorf("%s: no market of period %s", encoding, period)
+		}
+	}
+}
+
+func decodeMessagePack(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func BenchmarkEncodeJSON(b *testing.B)        { benchmarkEncode(b, JSON) }
+func BenchmarkEncodeMessagePack(b *testing.B) { benchmarkEncode(b, MessagePack) }
+func BenchmarkEncodeProtobuf(b *testing.B)    { benchmarkEncode(b, Protobuf) }
+
+// benchmarkEncode encodes a snapshot frame of 300 events, as is and deflated
+// the way permessage-deflate does without context takeover, and reports the
+// frame size.
+func benchmarkEncode(b *testing.B, encoding string) {
+	frame := Frame{Type: TypeSnapshot, Epoch: "bench", Seq: 1, Events: generate(300)}
+	for _, deflate := range []bool{false, true} {
+		name := "plain"
+		if deflate {
+			name = "deflate"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			var size int
+			for i := 0; i < b.N; i++ {
+				data, err := Encode(encoding, frame)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if deflate {
+					if data, err = compress(data, flate.BestSpeed); err != nil {
+						b.Fatal(err)
+					}
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "bytes/frame")
+		})
+	}
+}
+
+func compress(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+	// The empty stored block closing a flush is not sent.
+	return buf.Bytes()[:buf.Len()-4], nil
+}
+
+// generate builds football events with the usual spread of markets: match
+// result, double chance, both teams to score, totals and handicaps on a range
+// of lines and correct scores, plus a tennis game and a group stage market.
+func generate(events int) map[string]helper.ProcessedData {
+	batch := make(map[string]helper.ProcessedData, events)
+	for i := 0; i < events; i++ {
+		eventID := 1020300000 + i
+		data := helper.ProcessedData{
+			Provider:      "unibet",
+			Operator:      "ubbe",
+			EventID:       eventID,
+			MatchName:     fmt.Sprintf("Home Team %d - Away Team %d", i, i),
+			StartTime:     1760000000 + int64(i)*900,
+			HomeTeam:      fmt.Sprintf("Home Team %d", i),
+			AwayTeam:      fmt.Sprintf("Away Team %d", i),
+			HomeID:        fmt.Sprintf("p%012x", 2*i),
+			AwayID:        fmt.Sprintf("p%012x", 2*i+1),
+			Sport:         "FOOTBALL",
+			League:        "Premier League",
+			Country:       "England",
+			CompetitionID: "c00000000001",
+			Region:        "Europe",
+			Gender:        "men",
+			Time:          1760000000,
+			Type:          "match",
+		}
+
+		add := func(m market.Market, odds float64) {
+			data.Outcomes = append(data.Outcomes, helper.Outcome{
+				TypeName:   string(m.Kind),
+				Type:       m.Code(),
+				Label:      string(m.Selection),
+				Odds:       odds,
+				BetOfferID: eventID*10 + len(data.Outcomes)/3,
+				ID:         eventID*100 + len(data.Outcomes),
+				// Numbers in the criterion would not decode back to the
+				// same type, its labels do.
+				Criterion: map[string]interface{}{"label": "Full Time", "englishLabel": "Full Time"},
+				Market:    &m,
+				Status:    "OPEN",
+			})
+		}
+		for _, period := range []market.Period{market.PeriodFullTime, market.PeriodFirstHalf, market.PeriodSecondHalf} {
+			base := market.Market{Stat: market.StatGoals, Period: period, Scope: market.ScopeMatch}
+			for j, selection := range []market.Selection{market.SelectionHome, market.SelectionDraw, market.SelectionAway} {
+				m := base
+				m.Kind, m.Selection = market.KindWinner, selection
+				add(m, 1.5+float64(j))
+			}
+			for j, selection := range []market.Selection{"HOME_DRAW", "HOME_AWAY", "DRAW_AWAY"} {
+				m := base
+				m.Kind, m.Selection = market.KindDoubleChance, selection
+				add(m, 1.1+float64(j)/10)
+			}
+			for _, selection := range []market.Selection{"YES", "NO"} {
+				m := base
+				m.Kind, m.Selection = market.KindBothTeamsScore, selection
+				add(m, 1.85)
+			}
+			for line := 0.5; line < 6; line++ {
+				for _, selection := range []market.Selection{market.SelectionOver, market.SelectionUnder} {
+					m := base
+					m.Kind, m.Selection = market.KindTotal, selection
+					add(m.WithLine(line), 1.2+line/3)
+				}
+			}
+			for line := -2.5; line < 3; line++ {
+				for _, selection := range []market.Selection{market.SelectionHome, market.SelectionAway} {
+					m := base
+					m.Kind, m.Selection = market.KindHandicap, selection
+					add(m.WithLine(line), 1.9)
+				}
+			}
+		}
+		for home := 0; home < 5; home++ {
+			for away := 0; away < 5; away++ {
+				m := market.Market{Kind: market.KindCorrectScore, Stat: market.StatGoals, Period: market.PeriodFullTime, Scope: market.ScopeMatch, Selection: market.ScoreSelection(home, away)}
+				add(m, 6+float64(home+away)*3)
+			}
+		}
+		add(market.Market{Kind: market.KindTotal, Stat: market.StatPoints, Period: market.GamePeriod(1, 3), Scope: market.ScopeMatch, Selection: market.SelectionOver}.WithLine(6.5), 1.9)
+		add(market.Market{Kind: market.KindWinner, Stat: market.StatGoals, Period: market.GroupPeriod("A"), Scope: market.ScopeHome, Selection: market.SelectionHome}, 2.4)
+		batch[data.StorageKey()] = data
+	}
+	return batch
+}
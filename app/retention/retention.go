@@ -0,0 +1,344 @@
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"test_task_app/config"
+	"test_task_app/status"
+	"test_task_app/storage"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	activeExt       = ".jsonl"
+	segmentLayout   = "20060102T150405"
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+	compressionNone = "none"
+
+	// settleTime is how long a rotated segment must stay untouched before it
+	// is compressed, so a write that raced with the rename is not lost.
+	settleTime = time.Minute
+	// idleTime is how long an event must go without updates before it is
+	// taken to have left the feed, longer than the slowest poll interval.
+	idleTime = time.Hour
+)
+
+// Manager keeps the data directory bounded: it rotates active JSONL files,
+// compresses finished ones, removes expired data and enforces the disk quota.
+type Manager struct {
+	dir     string
+	cfg     config.Retention
+	catalog *storage.Catalog
+	// prune removes the catalog entries of events without data files, only
+	// when the snapshots are stored in files.
+	prune bool
+	Log   *logrus.Logger
+	now   func() time.Time
+}
+
+type fileInfo struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// NewManager tells finished events apart by their start time and status in
+// catalog, and removes the entries of events whose data is gone from it.
+func NewManager(cfg config.Config, catalog *storage.Catalog, log *logrus.Logger) *Manager {
+	return &Manager{
+		dir:     cfg.PathToData,
+		cfg:     cfg.Retention,
+		catalog: catalog,
+		prune:   cfg.StorageBackend == storage.BackendFile || cfg.StorageBackend == "",
+		Log:     log,
+		now:     time.Now,
+	}
+}
+
+// Run sweeps the data directory every RetentionCheckInterval until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	if !m.cfg.RetentionEnabled {
+		m.Log.Info("retention is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(m.cfg.RetentionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Sweep(); err != nil {
+			m.Log.Errorf("retention sweep failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep runs a single rotation, compression, expiry, quota and catalog pass.
+func (m *Manager) Sweep() error {
+	files, err := m.list()
+	if err != nil {
+		return err
+	}
+
+	now := m.now()
+	for _, f := range files {
+		if isActive(f.path) && m.shouldRotate(f, now) {
+			segment, err := m.rotate(f.path, now)
+			if err != nil {
+				m.Log.Errorf("error rotating %s: %v", f.path, err)
+				continue
+			}
+			m.Log.Debugf("rotated %s to %s", f.path, segment)
+		}
+	}
+
+	if files, err = m.list(); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if isSegment(f.path) && now.Sub(f.modTime) >= settleTime {
+			if err := m.compress(f.path); err != nil {
+				m.Log.Errorf("error compressing %s: %v", f.path, err)
+			}
+		}
+	}
+
+	if files, err = m.list(); err != nil {
+		return err
+	}
+	if m.cfg.MaxAge > 0 {
+		kept := files[:0]
+		for _, f := range files {
+			if now.Sub(f.modTime) > m.cfg.MaxAge {
+				if err := os.Remove(f.path); err != nil {
+					m.Log.Errorf("error removing expired %s: %v", f.path, err)
+					kept = append(kept, f)
+					continue
+				}
+				m.Log.Infof("removed expired %s", f.path)
+				continue
+			}
+			kept = append(kept, f)
+		}
+		files = kept
+	}
+
+	if err := m.enforceQuota(files); err != nil {
+		return err
+	}
+	if m.prune {
+		return m.pruneCatalog(now)
+	}
+	return nil
+}
+
+// pruneCatalog removes the catalog entries of events whose files are all
+// gone. Events seen within settleTime are kept, their first snapshot may
+// still be queued for writing.
+func (m *Manager) pruneCatalog(now time.Time) error {
+	files, err := m.list()
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]bool, len(files))
+	for _, f := range files {
+		key, _, _ := strings.Cut(filepath.Base(f.path), activeExt)
+		stored[key] = true
+	}
+
+	removed := m.catalog.Prune(func(entry storage.CatalogEntry) bool {
+		return stored[entry.Key] || now.Sub(time.Unix(entry.LastSeen, 0)) < settleTime
+	})
+	if removed > 0 {
+		m.Log.Infof("removed %d catalog entries of events without data", removed)
+	}
+	return nil
+}
+
+func (m *Manager) shouldRotate(f fileInfo, now time.Time) bool {
+	if m.cfg.RotateMaxFileSizeMB > 0 && f.size >= m.cfg.RotateMaxFileSizeMB<<20 {
+		return true
+	}
+	if m.cfg.RotateDaily && !sameDay(f.modTime, now) {
+		return true
+	}
+	return m.complete(f, now)
+}
+
+// complete reports whether the event of an active file is finished: all its
+// markets closed, or it started CompleteAfter ago and is no longer updated.
+// Files of events missing from the catalog are finished once they were not
+// written for CompleteAfter.
+func (m *Manager) complete(f fileInfo, now time.Time) bool {
+	entry, ok := m.catalog.Get(strings.TrimSuffix(filepath.Base(f.path), activeExt))
+	if !ok {
+		return m.cfg.CompleteAfter > 0 && now.Sub(f.modTime) >= m.cfg.CompleteAfter
+	}
+	if entry.Status == status.Closed {
+		return now.Sub(f.modTime) >= settleTime
+	}
+	started := time.Unix(entry.StartTime, 0)
+	return m.cfg.CompleteAfter > 0 && entry.StartTime > 0 && now.Sub(started) >= m.cfg.CompleteAfter &&
+		now.Sub(time.Unix(entry.LastSeen, 0)) >= idleTime
+}
+
+// rotate renames an active file to a timestamped segment. The writer opens
+// the file on every append, so the next write starts a fresh active file.
+func (m *Manager) rotate(path string, now time.Time) (string, error) {
+	segment := fmt.Sprintf("%s.%s", path, now.UTC().Format(segmentLayout))
+	if err := os.Rename(path, segment); err != nil {
+		return "", err
+	}
+	return segment, nil
+}
+
+func (m *Manager) compress(path string) error {
+	var ext string
+	switch m.cfg.Compression {
+	case compressionNone, "":
+		return nil
+	case compressionGzip:
+		ext = ".gz"
+	case compressionZstd:
+		ext = ".zst"
+	default:
+		return fmt.Errorf("unknown compression %q", m.cfg.Compression)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ext + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := copyCompressed(dst, src, m.cfg.Compression); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+ext); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+func copyCompressed(dst io.Writer, src io.Reader, compression string) error {
+	var w io.WriteCloser
+	var err error
+	if compression == compressionZstd {
+		w, err = zstd.NewWriter(dst)
+		if err != nil {
+			return err
+		}
+	} else {
+		w = gzip.NewWriter(dst)
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// enforceQuota removes the oldest files until the directory fits the quota.
+func (m *Manager) enforceQuota(files []fileInfo) error {
+	if m.cfg.DiskQuotaMB <= 0 {
+		return nil
+	}
+	quota := m.cfg.DiskQuotaMB << 20
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	if total <= quota {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= quota {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			m.Log.Errorf("error evicting %s: %v", f.path, err)
+			continue
+		}
+		total -= f.size
+		m.Log.Warnf("disk quota exceeded, evicted %s (%d bytes)", f.path, f.size)
+	}
+	return nil
+}
+
+func (m *Manager) list() ([]fileInfo, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []fileInfo
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".tmp") || !strings.Contains(entry.Name(), activeExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, fileInfo{
+			path:    filepath.Join(m.dir, entry.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	return files, nil
+}
+
+func isActive(path string) bool {
+	return strings.HasSuffix(path, activeExt)
+}
+
+func isSegment(path string) bool {
+	i := strings.LastIndex(path, activeExt+".")
+	if i < 0 {
+		return false
+	}
+	_, err := time.Parse(segmentLayout, path[i+len(activeExt)+1:])
+	return err == nil
+}
+
+func sameDay(a, b time.Time) bool {
+	a, b = a.UTC(), b.UTC()
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
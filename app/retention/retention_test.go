@@ -0,0 +1,112 @@
+package retention
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"test_task_app/config"
+	"test_task_app/helper"
+	"test_task_app/status"
+	"test_task_app/storage"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestComplete(t *testing.T) {
+	dir := t.TempDir()
+	catalog, err := storage.OpenCatalog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1760000000, 0)
+	event := func(id int, start, seen time.Time, markets ...string) string {
+		data := helper.ProcessedData{Provider: "unibet", Operator: "ubbe", EventID: id, StartTime: start.Unix(), Time: seen.Unix()}
+		for _, s := range markets {
+			data.Markets = append(data.Markets, status.Market{Code: "WIN:GOALS:FT:MATCH", Status: s})
+		}
+		catalog.Update(data)
+		return data.StorageKey()
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		modified time.Time
+		want     bool
+	}{
+		{"markets closed", event(1, now.Add(-time.Hour), now.Add(-2*time.Minute), status.Closed, status.Closed), now.Add(-2 * time.Minute), true},
+		{"markets just closed", event(2, now.Add(-time.Hour), now, status.Closed), now, false},
+		{"market suspended", event(3, now.Add(-time.Hour), now.Add(-2*time.Hour), status.Closed, status.Suspended), now.Add(-2 * time.Hour), false},
+		{"long started, gone from the feed", event(4, now.Add(-7*time.Hour), now.Add(-2*time.Hour), status.Open), now.Add(-2 * time.Hour), true},
+		{"long started, still updated", event(5, now.Add(-7*time.Hour), now.Add(-time.Minute), status.Open), now.Add(-time.Minute), false},
+		{"not started, not updated", event(6, now.Add(time.Hour), now.Add(-10*time.Hour)), now.Add(-10 * time.Hour), false},
+		{"unknown, not written", "unibet-ubbe-7", now.Add(-7 * time.Hour), true},
+		{"unknown, written", "unibet-ubbe-8", now.Add(-time.Hour), false},
+	}
+
+	m := NewManager(config.Config{PathToData: dir}, catalog, nil)
+	m.cfg.CompleteAfter = 6 * time.Hour
+	for _, tt := range tests {
+		f := fileInfo{path: filepath.Join(dir, tt.key+activeExt), modTime: tt.modified}
+		if got := m.complete(f, now); got != tt.want {
+			t.Errorf("%s: complete() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSweepPrunesCatalog(t *testing.T) {
+	dir := t.TempDir()
+	catalog, err := storage.OpenCatalog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	keys := make(map[string]string)
+	for _, event := range []struct {
+		name string
+		id   int
+		seen time.Time
+		file string
+	}{
+		{"active", 1, now.Add(-time.Hour), ".jsonl"},
+		{"rotated", 2, now.Add(-time.Hour), ".jsonl.20251009T120000.zst"},
+		{"gone", 3, now.Add(-time.Hour), ""},
+		{"queued", 4, now, ""},
+	} {
+		data := helper.ProcessedData{Provider: "unibet", Operator: "ubbe", EventID: event.id, Time: event.seen.Unix()}
+		catalog.Update(data)
+		keys[event.name] = data.StorageKey()
+		if event.file != "" {
+			if err := os.WriteFile(filepath.Join(dir, data.StorageKey()+event.file), []byte("{}\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	m := NewManager(config.Config{PathToData: dir}, catalog, log)
+	if err := m.Sweep(); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"active": true, "rotated": true, "gone": false, "queued": true} {
+		if _, ok := catalog.Get(keys[name]); ok != want {
+			t.Errorf("%s event in catalog: %v, want %v", name, ok, want)
+		}
+	}
+
+	// Catalogs of other backends have no files to match.
+	catalog.Update(helper.ProcessedData{Provider: "unibet", Operator: "ubbe", EventID: 5, Time: now.Add(-time.Hour).Unix()})
+	cfg := config.Config{PathToData: dir}
+	cfg.StorageBackend = storage.BackendSQLite
+	m = NewManager(cfg, catalog, log)
+	if err := m.Sweep(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := catalog.Get("unibet-ubbe-5"); !ok {
+		t.Error("sqlite backend pruned the catalog")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 	"test_task_app/config"
+	"test_task_app/filter"
 	"test_task_app/helper"
 
 	"math/rand"
@@ -20,44 +21,49 @@ import (
 )
 
 const (
-	Live = "Live"
+	Live     = "Live"
+	Outright = "Outright"
 )
 
 type MatchData struct {
-	cfg  config.Config
-	Log  *logrus.Logger
-	Data map[string]interface{}
+	cfg      config.Config
+	offering config.Offering
+	filter   *filter.Engine
+	Log      *logrus.Logger
+	Data     map[string]interface{}
 }
 
-func NewMatchData(cfg config.Config) *MatchData {
+func NewMatchData(cfg config.Config, offering config.Offering, filter *filter.Engine, log *logrus.Logger) *MatchData {
 
-	logg := SetLogrus(cfg.LogLevel)
 	return &MatchData{
-		cfg:  cfg,
-		Log:  logg,
-		Data: make(map[string]interface{}),
+		cfg:      cfg,
+		offering: offering,
+		filter:   filter,
+		Log:      log,
+		Data:     make(map[string]interface{}),
 	}
 }
 
 func (md *MatchData) Get(ctx context.Context, client *http.Client, sm config.SportMode) error {
 	md.Log.WithFields(logrus.Fields{"op": "service.MatchData.Get"})
-	md.Log.Infof("start getting matches for sport=%v mode=%v", sm.Sport, sm.Mode)
-
-	now := time.Now().UTC()
-	maxStartTime := now.Add(24 * time.Hour)
+	md.Log.Infof("start getting matches for operator=%v sport=%v mode=%v", md.offering.Operator, sm.Sport, sm.Mode)
 
 	var baseURL string
 	var params *url.Values
-	if sm.Mode == Live {
-		params = setBaseParams(md.cfg)
+	if sm.Mode == Outright {
+		params = setBaseParams(md.cfg, md.offering)
+		baseURL = fmt.Sprintf(md.cfg.RawURLgetOutrights, md.cfg.UnibetAPIBase+md.offering.Operator, strings.ToLower(sm.Sport))
+
+	} else if sm.Mode == Live {
+		params = setBaseParams(md.cfg, md.offering)
 		params.Add("useCombined", "true")
 		params.Add("useCombinedLive", "true")
-		baseURL = fmt.Sprintf(md.cfg.RawURLgetMatchesIsLive, md.cfg.UnibetAPIBase+md.cfg.APICountryCode, strings.ToLower(sm.Sport))
+		baseURL = fmt.Sprintf(md.cfg.RawURLgetMatchesIsLive, md.cfg.UnibetAPIBase+md.offering.Operator, strings.ToLower(sm.Sport))
 
 	} else {
-		params = setBaseParams(md.cfg)
+		params = setBaseParams(md.cfg, md.offering)
 		params.Add("useCombined", "true")
-		baseURL = fmt.Sprintf(md.cfg.RawURLgetMatches, md.cfg.UnibetAPIBase+md.cfg.APICountryCode, strings.ToLower(sm.Sport))
+		baseURL = fmt.Sprintf(md.cfg.RawURLgetMatches, md.cfg.UnibetAPIBase+md.offering.Operator, strings.ToLower(sm.Sport))
 	}
 
 	req, err := http.NewRequest("GET", baseURL+"?"+params.Encode(), nil)
@@ -66,7 +72,7 @@ func (md *MatchData) Get(ctx context.Context, client *http.Client, sm config.Spo
 		return err
 	}
 
-	for key, value := range getHeaders(md.cfg) {
+	for key, value := range getHeaders(md.cfg, md.offering) {
 		req.Header.Set(key, value)
 	}
 
@@ -98,18 +104,18 @@ func (md *MatchData) Get(ctx context.Context, client *http.Client, sm config.Spo
 				return err
 			}
 		}
+		events, _ := md.Data["events"].([]interface{})
 		filteredEvents := []interface{}{}
-		for _, event := range md.Data["events"].([]interface{}) {
-			eventData := event.(map[string]interface{})["event"].(map[string]interface{})
-			startTime, _ := time.Parse(time.RFC3339, eventData["start"].(string))
-			homeName := strings.ToLower(eventData["homeName"].(string))
-			awayName := strings.ToLower(eventData["awayName"].(string))
-
-			if startTime.Before(maxStartTime) && !strings.Contains(homeName, "esport") && !strings.Contains(awayName, "esport") {
+		for _, event := range events {
+			candidate := filter.FromListEvent(event.(map[string]interface{}), md.offering.Operator, sm.Mode)
+			if allowed, reason := md.filter.Allow(candidate); allowed {
 				filteredEvents = append(filteredEvents, event)
+			} else {
+				md.Log.Debugf("skipping event %d %s - %s: %s", candidate.ID, candidate.HomeName, candidate.AwayName, reason)
 			}
 		}
 
+		md.Log.Infof("%d of %d %s %s %s events passed filters", len(filteredEvents), len(events), md.offering.Operator, sm.Sport, sm.Mode)
 		md.Data["events"] = filteredEvents
 		md.Log.Infof("finish getting matches for sport=%v mode=%v", sm.Sport, sm.Mode)
 		return nil
@@ -132,9 +138,9 @@ func (md *MatchData) Fetch(ctx context.Context, requestSemaphore chan struct{},
 	}
 	client.Transport = &transport
 
-	params := setBaseParams(md.cfg)
+	params := setBaseParams(md.cfg, md.offering)
 	params.Add("includeParticipants", "true")
-	local_url := fmt.Sprintf(md.cfg.RawURLfetchMatch, md.cfg.UnibetAPIBase+md.cfg.APICountryCode, matchID)
+	local_url := fmt.Sprintf(md.cfg.RawURLfetchMatch, md.cfg.UnibetAPIBase+md.offering.Operator, matchID)
 
 	req, err := http.NewRequest("GET", local_url+"?"+params.Encode(), nil)
 	if err != nil {
@@ -142,7 +148,7 @@ func (md *MatchData) Fetch(ctx context.Context, requestSemaphore chan struct{},
 		return nil, err
 	}
 
-	for key, value := range getHeaders(md.cfg) {
+	for key, value := range getHeaders(md.cfg, md.offering) {
 		req.Header.Set(key, value)
 	}
 
@@ -193,26 +199,32 @@ func getRandomProxy(config config.Config) string {
 	return config.Proxies[rand.Intn(len(config.Proxies))]
 }
 
-func getHeaders(config config.Config) map[string]string {
-	return map[string]string{
+// getHeaders returns the browser-like headers for the offering, its own
+// headers take precedence.
+func getHeaders(config config.Config, offering config.Offering) map[string]string {
+	headers := map[string]string{
 		"Accept":          "application/json, text/javascript, */*; q=0.01",
 		"Accept-Encoding": "gzip, deflate, br, zstd",
 		"Accept-Language": "en-US;q=0.7,en;q=0.3",
 		"Connection":      "keep-alive",
 		"Host":            "eu-offering-api.kambicdn.com",
-		"Origin":          fmt.Sprintf("https://www.unibet.%s", config.CountryCode),
-		"Referer":         fmt.Sprintf("https://www.unibet.%s/", config.CountryCode),
+		"Origin":          fmt.Sprintf("https://www.unibet.%s", offering.CountryCode),
+		"Referer":         fmt.Sprintf("https://www.unibet.%s/", offering.CountryCode),
 		"Sec-Fetch-Dest":  "empty",
 		"Sec-Fetch-Mode":  "cors",
 		"Sec-Fetch-Site":  "cross-site",
 		"User-Agent":      config.UserAgent,
 	}
+	for key, value := range offering.Headers {
+		headers[key] = value
+	}
+	return headers
 }
 
-func setBaseParams(config config.Config) *url.Values {
+func setBaseParams(config config.Config, offering config.Offering) *url.Values {
 	params := url.Values{}
-	params.Add("lang", config.Lang)
-	params.Add("market", config.Market)
+	params.Add("lang", offering.Lang)
+	params.Add("market", offering.Market)
 	params.Add("client_id", config.ClientID)
 	params.Add("channel_id", config.ChannelID)
 	params.Add("ncid", fmt.Sprintf("%d", time.Now().Second()*1000))
@@ -222,13 +234,7 @@ func setBaseParams(config config.Config) *url.Values {
 func SetLogrus(level string) *logrus.Logger {
 
 	log := logrus.New()
-	logrusLevel, err := logrus.ParseLevel(level)
-
-	if err != nil {
-		log.SetLevel(logrus.DebugLevel)
-	} else {
-		log.SetLevel(logrusLevel)
-	}
+	SetLevel(log, level)
 
 	log.SetFormatter(&logrus.JSONFormatter{
 		TimestampFormat: "2006-01-02 15:04:05",
@@ -243,7 +249,17 @@ func SetLogrus(level string) *logrus.Logger {
 
 	multiWriter := io.MultiWriter(os.Stdout, logFile)
 
-	log.SetOutput(multiWriter)
+	log.SetOutput(config.RedactingWriter{W: multiWriter})
 
 	return log
 }
+
+// SetLevel sets the level of log, an unknown level logs everything.
+func SetLevel(log *logrus.Logger, level string) {
+	logrusLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		log.SetLevel(logrus.DebugLevel)
+	} else {
+		log.SetLevel(logrusLevel)
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"test_task_app/alert"
+	"test_task_app/competition"
+	"test_task_app/config"
+	"test_task_app/discovery"
+	"test_task_app/feed"
+	"test_task_app/filter"
+	"test_task_app/helper"
+	"test_task_app/participant"
+	"test_task_app/sink"
+	"test_task_app/status"
+	"test_task_app/storage"
+	"test_task_app/translation"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Pipeline turns a fetched event into processed data, enriches it and hands
+// it to storage.
+type Pipeline struct {
+	// Filter decides which listed events are fetched at all.
+	Filter       *filter.Engine
+	Store        storage.Store
+	Catalog      *storage.Catalog
+	Participants *participant.Registry
+	Competitions *competition.Resolver
+	Labels       *translation.Translator
+	Unmapped     *discovery.Collector
+	// Status tracks suspensions and closures across updates, events not
+	// updated for StatusTTL are forgotten by it and by Feed.
+	Status    *status.Tracker
+	StatusTTL time.Duration
+	// Alerts detects sharp price movements, which Notifier delivers. Both
+	// are nil when alerts are disabled.
+	Alerts   *alert.Engine
+	Notifier *alert.Notifier
+	// Sinks publishes every update to external consumers, Feed keeps the
+	// latest snapshots for the gRPC API.
+	Sinks *sink.Dispatcher
+	Feed  *feed.Hub
+	Log   *logrus.Logger
+}
+
+// Process normalizes an event fetched from the offering in the given mode and
+// persists it. Persistence errors are logged but do not drop the event from
+// the live feed.
+func (p *Pipeline) Process(ctx context.Context, offering config.Offering, mode string, rawData *helper.RawData) (helper.ProcessedData, error) {
+	process := helper.ProcessMatchData
+	if mode == Outright {
+		process = helper.ProcessOutrightData
+	}
+	processedData, err := process(rawData, p.Labels, offering.Lang, p.Unmapped)
+	if err != nil {
+		return processedData, err
+	}
+	processedData.Operator = offering.Operator
+
+	if len(rawData.Events) > 0 {
+		c := p.Competitions.Resolve(processedData.Provider, rawData.Events[0].Path)
+		processedData.CompetitionID = c.ID
+		processedData.Country = c.Country
+		processedData.Region = c.Region
+		processedData.TourLevel = c.TourLevel
+		processedData.Gender = c.Gender
+		if c.Tournament != competition.Unknown {
+			processedData.League = c.Tournament
+		}
+	}
+
+	if processedData.Type != helper.TypeOutright {
+		processedData.HomeID = p.Participants.Resolve(processedData.Sport, processedData.HomeTeam).ID
+		processedData.AwayID = p.Participants.Resolve(processedData.Sport, processedData.AwayTeam).ID
+	}
+
+	// Player and outright markets carry IDs derived from the names, the registry
+	// applies aliases and records the players.
+	players := make(map[string]string, len(processedData.Players))
+	for i, player := range processedData.Players {
+		id := p.Participants.Resolve(processedData.Sport, player.Name).ID
+		players[player.ID] = id
+		processedData.Players[i].ID = id
+	}
+	for i := range processedData.Outcomes {
+		m := processedData.Outcomes[i].Market
+		if m == nil || m.Participant == "" {
+			continue
+		}
+		if id, ok := players[m.Participant]; ok && id != m.Participant {
+			m.Participant = id
+			processedData.Outcomes[i].Type = m.Code()
+		}
+	}
+
+	observed := make([]status.Outcome, 0, len(processedData.Outcomes))
+	for _, outcome := range processedData.Outcomes {
+		observed = append(observed, status.Outcome{Code: outcome.Type, Market: outcome.Market.MarketCode(), Status: outcome.Status})
+	}
+	result := p.Status.Update(processedData.StorageKey(), observed)
+	for i := range processedData.Outcomes {
+		processedData.Outcomes[i].StatusSince = result.Since[i]
+	}
+	processedData.Markets, processedData.StatusChanges = result.Markets, result.Changes
+
+	if p.Alerts != nil {
+		prices := make([]alert.Price, 0, len(processedData.Outcomes))
+		for _, outcome := range processedData.Outcomes {
+			if outcome.Status != status.Open {
+				continue
+			}
+			prices = append(prices, alert.Price{
+				Code:      outcome.Type,
+				Market:    outcome.Market.MarketCode(),
+				Selection: string(outcome.Market.Selection),
+				Odds:      outcome.Odds,
+			})
+		}
+		processedData.Alerts = p.Alerts.Observe(alert.Event{
+			Key:       processedData.StorageKey(),
+			EventID:   processedData.EventID,
+			Operator:  processedData.Operator,
+			MatchName: processedData.MatchName,
+			Sport:     processedData.Sport,
+			League:    processedData.League,
+		}, prices)
+		p.Notifier.Notify(processedData.Alerts)
+	}
+
+	if err := p.Store.Save(ctx, processedData); err != nil {
+		p.Log.Errorf("error saving match data: %v", err)
+	}
+	p.Catalog.Update(processedData)
+	p.Sinks.Publish(processedData)
+	p.Feed.Publish(processedData)
+	return processedData, nil
+}
+
+// Flush persists the registries that are only written once per update cycle.
+func (p *Pipeline) Flush() {
+	if err := p.Participants.Save(); err != nil {
+		p.Log.Errorf("error saving participants: %v", err)
+	}
+	if err := p.Competitions.Save(); err != nil {
+		p.Log.Errorf("error saving competitions: %v", err)
+	}
+	if err := p.Labels.Save(); err != nil {
+		p.Log.Errorf("error saving untranslated labels: %v", err)
+	}
+	if p.StatusTTL > 0 {
+		p.Status.Prune(p.StatusTTL)
+		p.Feed.Prune(p.StatusTTL)
+	}
+	if p.Alerts != nil {
+		p.Alerts.Prune()
+	}
+}
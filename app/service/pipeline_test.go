@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"test_task_app/competition"
+	"test_task_app/config"
+	"test_task_app/discovery"
+	"test_task_app/feed"
+	"test_task_app/helper"
+	"test_task_app/participant"
+	"test_task_app/sink"
+	"test_task_app/status"
+	"test_task_app/storage"
+	"test_task_app/translation"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestPipeline(t *testing.T, dir string) *Pipeline {
+	t.Helper()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	participants, err := participant.NewRegistry(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	competitions, err := competition.NewResolver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels, err := translation.NewTranslator(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmapped, err := discovery.NewCollector(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := storage.OpenCatalog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sinks, err := sink.New(config.Sinks{}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Pipeline{
+		Store:        storage.NewFileStore(dir),
+		Catalog:      catalog,
+		Participants: participants,
+		Competitions: competitions,
+		Labels:       labels,
+		Unmapped:     unmapped,
+		Status:       status.NewTracker(),
+		Sinks:        sinks,
+		Feed:         feed.NewHub(),
+		Log:          log,
+	}
+}
+
+// fullTime is a football event with a 1X2 offer in the given outcome statuses.
+func fullTime(statuses ...string) *helper.RawData {
+	raw := &helper.RawData{
+		Events: []helper.Event{{ID: 1, HomeName: "Arsenal", AwayName: "Chelsea", Start: "2025-10-09T18:00:00Z", Sport: "FOOTBALL"}},
+	}
+	offer := helper.BetOffer{Criterion: map[string]interface{}{"englishLabel": "Full Time"}}
+	for i, s := range statuses {
+		offer.Outcomes = append(offer.Outcomes, helper.Outcome{
+			Type:   []string{"OT_ONE", "OT_CROSS", "OT_TWO"}[i],
+			ID:     i + 1,
+			Odds:   2000,
+			Status: s,
+		})
+	}
+	raw.BetOffers = []helper.BetOffer{offer}
+	return raw
+}
+
+func TestProcessWritesStatuses(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestPipeline(t, dir)
+	offering := config.Offering{Operator: "ubbe", Lang: "en_GB"}
+
+	updates := []struct {
+		raw     *helper.RawData
+		market  string
+		changes []string
+	}{
+		{fullTime(status.Open, status.Open, status.Open), status.Open, []string{status.EventOpened, status.EventOpened, status.EventOpened, status.EventOpened}},
+		{fullTime(status.Suspended, status.Suspended, status.Suspended), status.Suspended, []string{status.EventSuspended, status.EventSuspended, status.EventSuspended, status.EventSuspended}},
+		{fullTime(status.Open, status.Open), status.Open, []string{status.EventReopened, status.EventClosed, status.EventReopened, status.EventReopened}},
+	}
+	for i, update := range updates {
+		data, err := p.Process(context.Background(), offering, "PreMatch", update.raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data.Markets) != 1 || data.Markets[0].Code != "WIN:GOALS:FT:MATCH" || data.Markets[0].Status != update.market {
+			t.Errorf("update %d: markets %+v, want %s", i, data.Markets, update.market)
+		}
+		var events []string
+		for _, change := range data.StatusChanges {
+			events = append(events, change.Event)
+		}
+		if len(events) != len(update.changes) {
+			t.Errorf("update %d: changes %v, want %v", i, events, update.changes)
+			continue
+		}
+		for j := range events {
+			if events[j] != update.changes[j] {
+				t.Errorf("update %d: changes %v, want %v", i, events, update.changes)
+				break
+			}
+		}
+	}
+
+	// The saved payloads carry the statuses, and the away win missing from
+	// the last update is reported closed in the history.
+	file, err := os.Open(filepath.Join(dir, "unibet-ubbe-1.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var saved []helper.ProcessedData
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var data helper.ProcessedData
+		if err := json.Unmarshal(scanner.Bytes(), &data); err != nil {
+			t.Fatal(err)
+		}
+		saved = append(saved, data)
+	}
+	if len(saved) != 3 {
+		t.Fatalf("saved %d updates, want 3", len(saved))
+	}
+	for _, outcome := range saved[1].Outcomes {
+		if outcome.Status != status.Suspended || outcome.StatusSince == 0 {
+			t.Errorf("saved outcome %s: status %s since %d", outcome.Type, outcome.Status, outcome.StatusSince)
+		}
+	}
+	closed := false
+	for _, change := range p.Status.History("unibet-ubbe-1") {
+		closed = closed || (change.Code == "WIN:GOALS:FT:MATCH:AWAY" && change.Event == status.EventClosed)
+	}
+	if !closed {
+		t.Errorf("history %+v does not close the away win", p.Status.History("unibet-ubbe-1"))
+	}
+}
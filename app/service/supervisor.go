@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"test_task_app/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Supervisor runs one UpdateMatches goroutine per offering and SportMode and
+// reconciles them when the configuration is reloaded.
+type Supervisor struct {
+	ctx             context.Context
+	chanMatchesData chan map[string]interface{}
+	Log             *logrus.Logger
+
+	mu               sync.Mutex
+	cfg              config.Config
+	pipeline         *Pipeline
+	requestSemaphore chan struct{}
+	workers          map[workerKey]worker
+	wg               sync.WaitGroup
+}
+
+type workerKey struct {
+	Operator string
+	config.SportMode
+}
+
+type worker struct {
+	offering config.Offering
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewSupervisor(ctx context.Context, chanMatchesData chan map[string]interface{}, log *logrus.Logger) *Supervisor {
+	return &Supervisor{
+		ctx:             ctx,
+		chanMatchesData: chanMatchesData,
+		Log:             log,
+		workers:         make(map[workerKey]worker),
+	}
+}
+
+// Apply starts workers for added offerings and sport modes and stops those
+// for removed ones. Workers of an offering whose settings changed are
+// restarted, when a shared setting changed every worker is.
+func (s *Supervisor) Apply(cfg config.Config, pipeline *Pipeline) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restartAll := s.pipeline == nil || workerSettingsChanged(s.cfg, cfg) || s.pipeline.Filter != pipeline.Filter
+	if s.requestSemaphore == nil || s.cfg.MatchesPerBatch != cfg.MatchesPerBatch {
+		s.requestSemaphore = make(chan struct{}, cfg.MatchesPerBatch)
+		restartAll = true
+	}
+
+	wanted := make(map[workerKey]config.Offering)
+	for _, offering := range cfg.Targets() {
+		for _, sm := range offering.SportsToParse {
+			wanted[workerKey{Operator: offering.Operator, SportMode: sm}] = offering
+		}
+	}
+
+	var stopped []worker
+	for key, w := range s.workers {
+		offering, ok := wanted[key]
+		if !ok || restartAll || offeringChanged(w.offering, offering) {
+			w.cancel()
+			stopped = append(stopped, w)
+			delete(s.workers, key)
+			if !ok {
+				s.Log.Infof("stopped updates for %s %s %s", key.Operator, key.Sport, key.Mode)
+			}
+		}
+	}
+	// A replacement must not run next to the worker it replaces.
+	for _, w := range stopped {
+		<-w.done
+	}
+
+	if s.cfg.LogLevel != cfg.LogLevel {
+		SetLevel(s.Log, cfg.LogLevel)
+	}
+	s.cfg = cfg
+	s.pipeline = pipeline
+
+	for key, offering := range wanted {
+		if _, running := s.workers[key]; running {
+			continue
+		}
+		ctx, cancel := context.WithCancel(s.ctx)
+		done := make(chan struct{})
+		s.workers[key] = worker{offering: offering, cancel: cancel, done: done}
+		s.wg.Add(1)
+		go func(offering config.Offering, sm config.SportMode) {
+			defer s.wg.Done()
+			defer close(done)
+			UpdateMatches(ctx, cfg, offering, s.requestSemaphore, s.chanMatchesData, pipeline, sm, s.Log)
+		}(offering, key.SportMode)
+		s.Log.Infof("started updates for %s %s %s", key.Operator, key.Sport, key.Mode)
+	}
+}
+
+// Config returns the config the workers currently run with.
+func (s *Supervisor) Config() config.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cfg
+}
+
+// Pipeline returns the pipeline the workers currently run with.
+func (s *Supervisor) Pipeline() *Pipeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pipeline
+}
+
+// Wait blocks until every worker has returned, the parent context must be
+// cancelled first.
+func (s *Supervisor) Wait() {
+	s.wg.Wait()
+}
+
+// workerSettingsChanged reports whether anything UpdateMatches reads, other
+// than the offerings and their sports, differs between the two configs. The
+// log level is applied to the shared logger in place.
+func workerSettingsChanged(old, new config.Config) bool {
+	old.Unibet, new.Unibet = sharedUnibetSettings(old.Unibet), sharedUnibetSettings(new.Unibet)
+	return !reflect.DeepEqual(old.Unibet, new.Unibet) || old.Timeout != new.Timeout
+}
+
+// sharedUnibetSettings clears the settings that only feed the offerings,
+// Targets already carries them per worker.
+func sharedUnibetSettings(u config.Unibet) config.Unibet {
+	u.APICountryCode, u.CountryCode, u.Lang, u.Market = "", "", "", ""
+	u.LiveUpdateInterval, u.PrematchUpdateInterval = 0, 0
+	u.SportsToParse, u.Offerings = nil, nil
+	return u
+}
+
+// offeringChanged reports whether a worker of old must restart to run with
+// new, the list of sports is reconciled separately.
+func offeringChanged(old, new config.Offering) bool {
+	old.SportsToParse, new.SportsToParse = nil, nil
+	return !reflect.DeepEqual(old, new)
+}
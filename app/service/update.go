@@ -8,25 +8,26 @@ import (
 	"sync"
 	"time"
 	"test_task_app/config"
-	"test_task_app/helper"
+
+	"github.com/sirupsen/logrus"
 )
 
-func UpdateMatches(ctx context.Context, config config.Config, requestSemaphore chan struct{}, chanMatchesData chan map[string]interface{}, sm config.SportMode) {
+func UpdateMatches(ctx context.Context, config config.Config, offering config.Offering, requestSemaphore chan struct{}, chanMatchesData chan map[string]interface{}, pipeline *Pipeline, sm config.SportMode, log *logrus.Logger) {
 
 	var matchesDataLock sync.Mutex
 	var client *http.Client = &http.Client{}
 
-	matchData := NewMatchData(config)
+	matchData := NewMatchData(config, offering, pipeline.Filter, log)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			matchData.Log.Printf("Updating %s %s matches...", sm.Sport, sm.Mode)
+			matchData.Log.Printf("Updating %s %s %s matches...", offering.Operator, sm.Sport, sm.Mode)
 			err := matchData.Get(ctx, client, sm)
 			if err != nil {
-				matchData.Log.Printf("Error updating %s %s matches: %v", sm.Sport, sm.Mode, err)
+				matchData.Log.Printf("Error updating %s %s %s matches: %v", offering.Operator, sm.Sport, sm.Mode, err)
 				continue
 			}
 
@@ -43,7 +44,7 @@ func UpdateMatches(ctx context.Context, config config.Config, requestSemaphore c
 						result, err := matchData.Fetch(ctx, requestSemaphore, matchID, client)
 						if err == nil && result != nil {
 
-							processedData, err := helper.ProcessMatchData(result)
+							processedData, err := pipeline.Process(ctx, offering, sm.Mode, result)
 							if err != nil {
 								matchData.Log.Printf("Error processing match data: %v", err)
 								return
@@ -57,16 +58,27 @@ func UpdateMatches(ctx context.Context, config config.Config, requestSemaphore c
 				}
 			}
 			wg.Wait()
+			pipeline.Flush()
 
-			chanMatchesData <- newMatchesData
+			select {
+			case chanMatchesData <- newMatchesData:
+			case <-ctx.Done():
+				return
+			}
 
-			matchData.Log.Printf("Updated %d %s %s matches", len(newMatchesData), sm.Sport, sm.Mode)
+			matchData.Log.Printf("Updated %d %s %s %s matches", len(newMatchesData), offering.Operator, sm.Sport, sm.Mode)
 
-			interval := config.LiveUpdateInterval
-			if sm.Mode != Live {
-				interval = config.PrematchUpdateInterval
+			interval := offering.LiveUpdateInterval
+			if sm.Mode == Outright {
+				interval = offering.OutrightUpdateInterval
+			} else if sm.Mode != Live {
+				interval = offering.PrematchUpdateInterval
+			}
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return
 			}
-			time.Sleep(interval)
 		}
 	}
 }
@@ -0,0 +1,131 @@
+//go:build integration
+
+package sink
+
+// The Kafka and Redis sinks are tested against the compose services:
+//
+//	docker compose --profile sinks up -d kafka redis
+//	go test -tags integration ./sink
+//
+// ODDS_TEST_KAFKA_BROKERS (comma separated) and ODDS_TEST_REDIS_URL point the
+// tests elsewhere.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func testEnv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func TestKafkaPublish(t *testing.T) {
+	brokers := strings.Split(testEnv("ODDS_TEST_KAFKA_BROKERS", "localhost:29092"), ",")
+	topic := fmt.Sprintf("odds-test-%d", time.Now().UnixNano())
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sink := NewKafka(brokers, topic)
+	msgs := []Message{
+		{Key: "unibet-ubbe-1", Sport: "FOOTBALL", Operator: "ubbe", Body: []byte(`{"event_id":1}`), Time: time.Unix(1760000000, 0)},
+		{Key: "unibet-ubbe-1", Sport: "FOOTBALL", Operator: "ubbe", Body: []byte(`{"event_id":1,"time":2}`), Time: time.Unix(1760000001, 0)},
+	}
+	for _, msg := range msgs {
+		if err := sink.Publish(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An auto-created topic has a single partition.
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, Topic: topic, Partition: 0})
+	defer reader.Close()
+	for _, want := range msgs {
+		got, err := reader.ReadMessage(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers := make(map[string]string)
+		for _, h := range got.Headers {
+			headers[h.Key] = string(h.Value)
+		}
+		if string(got.Key) != want.Key || string(got.Value) != string(want.Body) || !got.Time.Equal(want.Time) {
+			t.Errorf("got key %q %s at %v, want key %q %s at %v", got.Key, got.Value, got.Time, want.Key, want.Body, want.Time)
+		}
+		if headers["sport"] != want.Sport || headers["operator"] != want.Operator {
+			t.Errorf("got headers %v", headers)
+		}
+	}
+}
+
+func TestRedisPublish(t *testing.T) {
+	url := testEnv("ODDS_TEST_REDIS_URL", "redis://localhost:6379/0")
+	stream := fmt.Sprintf("odds-test-%d", time.Now().UnixNano())
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sink, err := NewRedis(url, stream, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	defer sink.client.Del(context.Background(), stream)
+
+	msg := Message{Key: "unibet-ubbe-1", Sport: "FOOTBALL", Operator: "ubbe", Body: []byte(`{"event_id":1}`)}
+	if err := sink.Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := sink.client.XRange(ctx, stream, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	want := map[string]interface{}{"key": msg.Key, "sport": msg.Sport, "operator": msg.Operator, "data": string(msg.Body)}
+	for field, value := range want {
+		if entries[0].Values[field] != value {
+			t.Errorf("entry %s = %v, want %v", field, entries[0].Values[field], value)
+		}
+	}
+}
+
+func TestRedisTrim(t *testing.T) {
+	url := testEnv("ODDS_TEST_REDIS_URL", "redis://localhost:6379/0")
+	stream := fmt.Sprintf("odds-test-%d", time.Now().UnixNano())
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sink, err := NewRedis(url, stream, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	defer sink.client.Del(context.Background(), stream)
+
+	for i := 0; i < 1000; i++ {
+		if err := sink.Publish(ctx, Message{Key: fmt.Sprintf("unibet-ubbe-%d", i), Body: []byte(`{}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Approximate trimming keeps up to a node of entries beyond the limit.
+	length, err := sink.client.XLen(ctx, stream).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if length >= 1000 {
+		t.Errorf("stream holds %d entries, want it trimmed", length)
+	}
+}
@@ -0,0 +1,45 @@
+package sink
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka produces every update to a topic, keyed by the event so the updates
+// of an event land on one partition in order.
+type Kafka struct {
+	writer *kafka.Writer
+}
+
+func NewKafka(brokers []string, topic string) *Kafka {
+	return &Kafka{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireOne,
+		BatchTimeout:           10 * time.Millisecond,
+		AllowAutoTopicCreation: true,
+	}}
+}
+
+func (k *Kafka) Name() string {
+	return "kafka"
+}
+
+func (k *Kafka) Publish(ctx context.Context, msg Message) error {
+	return k.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(msg.Key),
+		Value: msg.Body,
+		Time:  msg.Time,
+		Headers: []kafka.Header{
+			{Key: "sport", Value: []byte(msg.Sport)},
+			{Key: "operator", Value: []byte(msg.Operator)},
+		},
+	})
+}
+
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
@@ -0,0 +1,54 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+var subjectUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// NATS publishes every update on <subject>.<sport>.<operator>, so consumers
+// can subscribe to a sport or operator with wildcards.
+type NATS struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func NewNATS(url, subject string) (*NATS, error) {
+	conn, err := nats.Connect(url, nats.Name("odds-parser"), nats.MaxReconnects(-1), nats.RetryOnFailedConnect(true))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to NATS: %w", err)
+	}
+	return &NATS{conn: conn, subject: subject}, nil
+}
+
+func (n *NATS) Name() string {
+	return "nats"
+}
+
+func (n *NATS) Publish(ctx context.Context, msg Message) error {
+	m := nats.NewMsg(n.Subject(msg))
+	m.Header.Set(HeaderKey, msg.Key)
+	m.Data = msg.Body
+	return n.conn.PublishMsg(m)
+}
+
+// Subject returns the subject a message is published on.
+func (n *NATS) Subject(msg Message) string {
+	token := func(s string) string {
+		if s = subjectUnsafe.ReplaceAllString(strings.ToLower(s), "_"); s == "" {
+			return "_"
+		}
+		return s
+	}
+	return n.subject + "." + token(msg.Sport) + "." + token(msg.Operator)
+}
+
+// Close flushes the messages still buffered by the client.
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
@@ -0,0 +1,85 @@
+package sink
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestNATSSubject(t *testing.T) {
+	n := &NATS{subject: "odds"}
+	tests := []struct {
+		sport, operator string
+		want            string
+	}{
+		{"FOOTBALL", "ubbe", "odds.football.ubbe"},
+		{"ICE_HOCKEY", "ub.se", "odds.ice_hockey.ub_se"},
+		{"TABLE TENNIS", "", "odds.table_tennis._"},
+		{"*", ">", "odds._._"},
+	}
+	for _, tt := range tests {
+		if got := n.Subject(Message{Sport: tt.sport, Operator: tt.operator}); got != tt.want {
+			t.Errorf("Subject(%q, %q) = %q, want %q", tt.sport, tt.operator, got, tt.want)
+		}
+	}
+}
+
+func TestNATSPublish(t *testing.T) {
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: server.RANDOM_PORT, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	defer srv.Shutdown()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+
+	conn, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sub, err := conn.SubscribeSync("odds.football.>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewNATS(srv.ClientURL(), "odds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []Message{
+		{Key: "unibet-ubbe-1", Sport: "FOOTBALL", Operator: "ubbe", Body: []byte(`{"event_id":1}`)},
+		{Key: "unibet-ubbe-2", Sport: "TENNIS", Operator: "ubbe", Body: []byte(`{"event_id":2}`)},
+		{Key: "unibet-ubse-3", Sport: "FOOTBALL", Operator: "ubse", Body: []byte(`{"event_id":3}`)},
+	}
+	for _, msg := range msgs {
+		if err := sink.Publish(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Close drains, so everything published has reached the server.
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []Message{msgs[0], msgs[2]} {
+		got, err := sub.NextMsg(5 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Subject != "odds.football."+want.Operator || got.Header.Get(HeaderKey) != want.Key || string(got.Data) != string(want.Body) {
+			t.Errorf("got %s key %q %s, want key %q %s", got.Subject, got.Header.Get(HeaderKey), got.Data, want.Key, want.Body)
+		}
+	}
+	if got, err := sub.NextMsg(100 * time.Millisecond); err == nil {
+		t.Errorf("got unexpected message on %s", got.Subject)
+	}
+}
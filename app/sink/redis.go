@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis appends every update to a Redis stream, trimmed to about maxLen
+// entries so it does not grow without bound.
+type Redis struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+// NewRedis connects to a redis:// URL, e.g. redis://:password@localhost:6379/0.
+func NewRedis(url, stream string, maxLen int64) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Redis URL: %w", err)
+	}
+	return &Redis{client: redis.NewClient(opts), stream: stream, maxLen: maxLen}, nil
+}
+
+func (r *Redis) Name() string {
+	return "redis"
+}
+
+func (r *Redis) Publish(ctx context.Context, msg Message) error {
+	return r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.stream,
+		MaxLen: r.maxLen,
+		Approx: true,
+		Values: []interface{}{"key", msg.Key, "sport", msg.Sport, "operator", msg.Operator, "data", msg.Body},
+	}).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,218 @@
+// Package sink publishes every processed update to external consumers, so
+// other services can follow the odds without holding a websocket. Each sink
+// is fed from its own queue: a slow or unreachable consumer drops updates
+// instead of delaying the pollers.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"test_task_app/config"
+	"test_task_app/helper"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Message is one processed update as handed to a sink. Key is the storage key
+// of the event, sinks use it to keep the updates of an event in order.
+type Message struct {
+	Key      string
+	Sport    string
+	Operator string
+	Body     []byte
+	Time     time.Time
+}
+
+// Sink delivers messages to one consumer. Publish is only called from a
+// single goroutine per sink.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// Stats describes the queue and deliveries of one sink.
+type Stats struct {
+	Name          string `json:"name"`
+	QueueLength   int    `json:"queue_length"`
+	QueueCapacity int    `json:"queue_capacity"`
+	Published     int64  `json:"published"`
+	Failed        int64  `json:"failed"`
+	Dropped       int64  `json:"dropped"`
+	LastError     string `json:"last_error,omitempty"`
+}
+
+type worker struct {
+	sink  Sink
+	queue chan Message
+	done  chan struct{}
+
+	published atomic.Int64
+	failed    atomic.Int64
+	dropped   atomic.Int64
+	lastError atomic.Value
+}
+
+// Dispatcher fans processed updates out to every configured sink.
+type Dispatcher struct {
+	workers []*worker
+	log     *logrus.Logger
+
+	mu     sync.RWMutex
+	closed bool
+	cancel context.CancelFunc
+}
+
+// New connects the sinks enabled in cfg. A dispatcher without sinks is valid
+// and drops everything.
+func New(cfg config.Sinks, log *logrus.Logger) (*Dispatcher, error) {
+	var sinks []Sink
+	closeAll := func() {
+		for _, s := range sinks {
+			s.Close()
+		}
+	}
+
+	if cfg.SinkWebhookURL != "" {
+		sinks = append(sinks, NewWebhook(cfg.SinkWebhookURL, cfg.SinkWebhookSecret, cfg.SinkWebhookTimeout, cfg.SinkWebhookRetries, cfg.SinkWebhookBackoff))
+	}
+	if cfg.SinkNATSURL != "" {
+		s, err := NewNATS(cfg.SinkNATSURL, cfg.SinkNATSSubject)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if len(cfg.SinkKafkaBrokers) > 0 {
+		sinks = append(sinks, NewKafka(cfg.SinkKafkaBrokers, cfg.SinkKafkaTopic))
+	}
+	if cfg.SinkRedisURL != "" {
+		s, err := NewRedis(cfg.SinkRedisURL, cfg.SinkRedisStream, cfg.SinkRedisMaxLen)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return NewDispatcher(sinks, cfg.SinkQueueSize, log), nil
+}
+
+// NewDispatcher starts delivering to the given sinks, each with a queue of
+// queueSize messages.
+func NewDispatcher(sinks []Sink, queueSize int, log *logrus.Logger) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{log: log, cancel: cancel}
+	for _, s := range sinks {
+		w := &worker{sink: s, queue: make(chan Message, queueSize), done: make(chan struct{})}
+		d.workers = append(d.workers, w)
+		go d.run(ctx, w)
+	}
+	return d
+}
+
+// Publish queues the update for every sink, dropping it for the sinks whose
+// queue is full.
+func (d *Dispatcher) Publish(data helper.ProcessedData) {
+	if len(d.workers) == 0 {
+		return
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		d.log.Errorf("error encoding update for sinks: %v", err)
+		return
+	}
+	msg := Message{
+		Key:      data.StorageKey(),
+		Sport:    data.Sport,
+		Operator: data.Operator,
+		Body:     body,
+		Time:     time.Now(),
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+	for _, w := range d.workers {
+		select {
+		case w.queue <- msg:
+		default:
+			if w.dropped.Add(1)%100 == 1 {
+				d.log.Errorf("error queueing update for %s sink: queue full, %d dropped so far", w.sink.Name(), w.dropped.Load())
+			}
+		}
+	}
+}
+
+// Stats returns the state of every sink.
+func (d *Dispatcher) Stats() []Stats {
+	stats := make([]Stats, 0, len(d.workers))
+	for _, w := range d.workers {
+		s := Stats{
+			Name:          w.sink.Name(),
+			QueueLength:   len(w.queue),
+			QueueCapacity: cap(w.queue),
+			Published:     w.published.Load(),
+			Failed:        w.failed.Load(),
+			Dropped:       w.dropped.Load(),
+		}
+		if err, ok := w.lastError.Load().(string); ok {
+			s.LastError = err
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// Close delivers what is still queued, waiting at most timeout, and closes
+// the sinks.
+func (d *Dispatcher) Close(timeout time.Duration) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	for _, w := range d.workers {
+		close(w.queue)
+	}
+	d.mu.Unlock()
+
+	timer := time.AfterFunc(timeout, d.cancel)
+	defer timer.Stop()
+
+	var errs []error
+	for _, w := range d.workers {
+		<-w.done
+		if err := w.sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	d.cancel()
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) run(ctx context.Context, w *worker) {
+	defer close(w.done)
+
+	for msg := range w.queue {
+		if ctx.Err() != nil {
+			w.dropped.Add(1)
+			continue
+		}
+		if err := w.sink.Publish(ctx, msg); err != nil {
+			w.failed.Add(1)
+			w.lastError.Store(err.Error())
+			d.log.Errorf("error publishing %s to %s sink: %v", msg.Key, w.sink.Name(), err)
+			continue
+		}
+		w.published.Add(1)
+	}
+}
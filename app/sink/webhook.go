@@ -0,0 +1,117 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers set on every webhook request.
+const (
+	HeaderKey       = "X-Odds-Key"
+	HeaderTimestamp = "X-Odds-Timestamp"
+	// HeaderSignature is "sha256=" followed by the hex HMAC-SHA256 of the
+	// timestamp, a dot and the body, keyed with the shared secret.
+	HeaderSignature = "X-Odds-Signature"
+)
+
+// Webhook POSTs every update as JSON, retrying failed requests with an
+// exponential backoff.
+type Webhook struct {
+	url     string
+	secret  []byte
+	client  *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// NewWebhook signs the requests when secret is set and retries a failed
+// request up to retries times, waiting backoff before the first retry and
+// twice as long before each further one.
+func NewWebhook(url, secret string, timeout time.Duration, retries int, backoff time.Duration) *Webhook {
+	return &Webhook{
+		url:     url,
+		secret:  []byte(secret),
+		client:  &http.Client{Timeout: timeout},
+		retries: retries,
+		backoff: backoff,
+	}
+}
+
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+func (w *Webhook) Publish(ctx context.Context, msg Message) error {
+	var err error
+	wait := w.backoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		if retry, err = w.post(ctx, msg); err == nil || !retry || attempt >= w.retries {
+			break
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wait *= 2
+	}
+	return err
+}
+
+func (w *Webhook) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
+
+// post sends the message once, reporting whether a failure is worth a retry:
+// network errors, 429 and 5xx are, other statuses are not.
+func (w *Webhook) post(ctx context.Context, msg Message) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(msg.Body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(msg.Time.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderKey, msg.Key)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if len(w.secret) > 0 {
+		req.Header.Set(HeaderSignature, Sign(w.secret, timestamp, msg.Body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("HTTP %d", resp.StatusCode)
+}
+
+// Sign returns the HeaderSignature value of a request body.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the valid HeaderSignature of a body,
+// for receivers.
+func Verify(secret []byte, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,116 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		attempts int
+		wantErr  bool
+	}{
+		{"ok", []int{http.StatusNoContent}, 3, 1, false},
+		{"server errors", []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}, 3, 3, false},
+		{"too many requests", []int{http.StatusTooManyRequests, http.StatusOK}, 3, 2, false},
+		{"retries exhausted", []int{http.StatusInternalServerError}, 2, 3, true},
+		{"bad request", []int{http.StatusBadRequest}, 3, 1, true},
+		{"unauthorized", []int{http.StatusUnauthorized}, 3, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				status := tt.statuses[min(attempts, len(tt.statuses)-1)]
+				attempts++
+				mu.Unlock()
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			webhook := NewWebhook(server.URL, "", time.Second, tt.retries, time.Millisecond)
+			defer webhook.Close()
+			err := webhook.Publish(context.Background(), Message{Key: "unibet-ubbe-1", Body: []byte(`{}`), Time: time.Now()})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Publish() error = %v, want error %v", err, tt.wantErr)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("got %d attempts, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestWebhookPublishCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	webhook := NewWebhook(server.URL, "", time.Second, 10, time.Hour)
+	if err := webhook.Publish(ctx, Message{Body: []byte(`{}`), Time: time.Now()}); err != context.DeadlineExceeded {
+		t.Errorf("Publish() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	secret := []byte("s3cret")
+	msg := Message{Key: "unibet-ubbe-1", Body: []byte(`{"event_id":1}`), Time: time.Unix(1760000000, 0)}
+
+	for _, signed := range []bool{true, false} {
+		var key, timestamp, signature string
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key = r.Header.Get(HeaderKey)
+			timestamp = r.Header.Get(HeaderTimestamp)
+			signature = r.Header.Get(HeaderSignature)
+			body, _ = io.ReadAll(r.Body)
+		}))
+
+		webhookSecret := ""
+		if signed {
+			webhookSecret = string(secret)
+		}
+		webhook := NewWebhook(server.URL, webhookSecret, time.Second, 0, time.Millisecond)
+		err := webhook.Publish(context.Background(), msg)
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if key != msg.Key || timestamp != strconv.FormatInt(msg.Time.Unix(), 10) || string(body) != string(msg.Body) {
+			t.Errorf("got key %q, timestamp %q, body %s", key, timestamp, body)
+		}
+		if !signed {
+			if signature != "" {
+				t.Errorf("unsigned webhook sent signature %q", signature)
+			}
+			continue
+		}
+		if !Verify(secret, timestamp, body, signature) {
+			t.Errorf("signature %q does not verify", signature)
+		}
+		if Verify(secret, timestamp, []byte(`{"event_id":2}`), signature) {
+			t.Error("signature verifies a different body")
+		}
+		if Verify(secret, "1760000001", body, signature) {
+			t.Error("signature verifies a different timestamp")
+		}
+		if Verify([]byte("other"), timestamp, body, signature) {
+			t.Error("signature verifies with a different secret")
+		}
+	}
+}
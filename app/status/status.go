@@ -0,0 +1,221 @@
+// Package status tracks the open, suspended and closed state of markets and
+// outcomes across updates of an event, so a suspension can be told apart
+// from a market that was taken down and every transition is timestamped.
+package status
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses of markets and outcomes.
+const (
+	Open      = "OPEN"
+	Suspended = "SUSPENDED"
+	// Closed markets and outcomes were settled or removed from the feed.
+	Closed = "CLOSED"
+)
+
+// Events recorded in a Change.
+const (
+	EventOpened    = "opened"
+	EventSuspended = "suspended"
+	EventReopened  = "reopened"
+	EventClosed    = "closed"
+)
+
+// Levels of a Change.
+const (
+	LevelMarket  = "market"
+	LevelOutcome = "outcome"
+)
+
+// maxHistory caps the changes kept in memory per event.
+const maxHistory = 500
+
+// Outcome is the status of one outcome in an update, Code is its outcome
+// code and Market its market code.
+type Outcome struct {
+	Code   string
+	Market string
+	Status string
+}
+
+// Market is the status of one market of an event and when it was entered.
+type Market struct {
+	Code   string `json:"code"`
+	Status string `json:"status"`
+	Since  int64  `json:"since"`
+}
+
+// Change is one transition of a market or outcome.
+type Change struct {
+	Level  string `json:"level"`
+	Code   string `json:"code"`
+	Event  string `json:"event"`
+	Status string `json:"status"`
+	Time   int64  `json:"time"`
+}
+
+// Result is what an update changed and the state after it.
+type Result struct {
+	// Since holds, per outcome of the update, when it entered its status.
+	Since []int64
+	// Markets lists every market seen for the event, closed ones included.
+	Markets []Market
+	Changes []Change
+}
+
+type state struct {
+	status string
+	since  int64
+}
+
+type event struct {
+	outcomes map[string]state
+	markets  map[string]state
+	history  []Change
+	updated  time.Time
+}
+
+// Tracker keeps the status of the markets and outcomes of every event in
+// memory. After a restart every market is reported as opened again.
+type Tracker struct {
+	mu     sync.Mutex
+	events map[string]*event
+	now    func() time.Time
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		events: make(map[string]*event),
+		now:    time.Now,
+	}
+}
+
+// Update records the outcomes of the latest update of the event with the
+// given key. Outcomes and markets missing from the update are closed.
+func (t *Tracker) Update(key string, outcomes []Outcome) Result {
+	now := t.now()
+	ts := now.Unix()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.events[key]
+	if !ok {
+		e = &event{outcomes: make(map[string]state), markets: make(map[string]state)}
+		t.events[key] = e
+	}
+	e.updated = now
+
+	var result Result
+	result.Since = make([]int64, len(outcomes))
+
+	seen := make(map[string]struct{}, len(outcomes))
+	marketStatus := make(map[string]string)
+	for i, o := range outcomes {
+		seen[o.Code] = struct{}{}
+		if change, ok := transition(e.outcomes, o.Code, o.Status, ts); ok {
+			change.Level = LevelOutcome
+			result.Changes = append(result.Changes, change)
+		}
+		result.Since[i] = e.outcomes[o.Code].since
+		marketStatus[o.Market] = merge(marketStatus[o.Market], o.Status)
+	}
+	for code, s := range e.outcomes {
+		if _, ok := seen[code]; ok || s.status == Closed {
+			continue
+		}
+		if change, ok := transition(e.outcomes, code, Closed, ts); ok {
+			change.Level = LevelOutcome
+			result.Changes = append(result.Changes, change)
+		}
+	}
+
+	for code := range e.markets {
+		if _, ok := marketStatus[code]; !ok {
+			marketStatus[code] = Closed
+		}
+	}
+	for code, s := range marketStatus {
+		if change, ok := transition(e.markets, code, s, ts); ok {
+			change.Level = LevelMarket
+			result.Changes = append(result.Changes, change)
+		}
+		result.Markets = append(result.Markets, Market{Code: code, Status: e.markets[code].status, Since: e.markets[code].since})
+	}
+	sort.Slice(result.Markets, func(i, j int) bool { return result.Markets[i].Code < result.Markets[j].Code })
+	sort.SliceStable(result.Changes, func(i, j int) bool {
+		if result.Changes[i].Level != result.Changes[j].Level {
+			return result.Changes[i].Level == LevelMarket
+		}
+		return result.Changes[i].Code < result.Changes[j].Code
+	})
+
+	e.history = append(e.history, result.Changes...)
+	if len(e.history) > maxHistory {
+		e.history = append([]Change(nil), e.history[len(e.history)-maxHistory:]...)
+	}
+	return result
+}
+
+// History returns the changes recorded for the event since it was first
+// seen, oldest first, capped to the latest ones.
+func (t *Tracker) History(key string) []Change {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.events[key]
+	if !ok {
+		return []Change{}
+	}
+	return append([]Change(nil), e.history...)
+}
+
+// Prune forgets the events that were not updated for the given duration.
+func (t *Tracker) Prune(olderThan time.Duration) {
+	cutoff := t.now().Add(-olderThan)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, e := range t.events {
+		if e.updated.Before(cutoff) {
+			delete(t.events, key)
+		}
+	}
+}
+
+// transition moves code to status, reporting the change if it is one.
+func transition(states map[string]state, code, status string, ts int64) (Change, bool) {
+	previous, known := states[code]
+	if known && previous.status == status {
+		return Change{}, false
+	}
+	states[code] = state{status: status, since: ts}
+
+	event := EventOpened
+	switch {
+	case status == Suspended:
+		event = EventSuspended
+	case status == Closed:
+		event = EventClosed
+	case known:
+		event = EventReopened
+	}
+	return Change{Code: code, Event: event, Status: status, Time: ts}, true
+}
+
+// merge combines the status of a market with one of its outcomes: a market
+// is open while any outcome is, suspended while any outcome is suspended.
+func merge(market, outcome string) string {
+	switch {
+	case market == Open || outcome == Open:
+		return Open
+	case market == Suspended || outcome == Suspended:
+		return Suspended
+	}
+	return Closed
+}
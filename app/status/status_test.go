@@ -0,0 +1,151 @@
+package status
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	winner = "WIN:GOALS:FT:MATCH"
+	total  = "TOTAL:GOALS:FT:MATCH"
+	home   = winner + ":HOME"
+	away   = winner + ":AWAY"
+	over   = total + ":OVER@2.5"
+)
+
+func TestUpdate(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Unix(1760000000, 0)
+	tracker.now = func() time.Time { return now }
+	update := func(outcomes ...Outcome) Result {
+		result := tracker.Update("unibet-ubbe-1", outcomes)
+		now = now.Add(time.Minute)
+		return result
+	}
+	t0 := now.Unix()
+	// The second update, at t0+60, changes nothing.
+	t2, t3, t4 := t0+120, t0+180, t0+240
+
+	steps := []struct {
+		name     string
+		outcomes []Outcome
+		since    []int64
+		markets  []Market
+		changes  []Change
+	}{
+		{
+			"first seen",
+			[]Outcome{{home, winner, Open}, {away, winner, Open}, {over, total, Suspended}},
+			[]int64{t0, t0, t0},
+			[]Market{{total, Suspended, t0}, {winner, Open, t0}},
+			[]Change{
+				{LevelMarket, total, EventSuspended, Suspended, t0},
+				{LevelMarket, winner, EventOpened, Open, t0},
+				{LevelOutcome, over, EventSuspended, Suspended, t0},
+				{LevelOutcome, away, EventOpened, Open, t0},
+				{LevelOutcome, home, EventOpened, Open, t0},
+			},
+		},
+		{
+			"unchanged",
+			[]Outcome{{home, winner, Open}, {away, winner, Open}, {over, total, Suspended}},
+			[]int64{t0, t0, t0},
+			[]Market{{total, Suspended, t0}, {winner, Open, t0}},
+			nil,
+		},
+		{
+			// A market stays open while any of its outcomes is.
+			"outcome suspended",
+			[]Outcome{{home, winner, Suspended}, {away, winner, Open}, {over, total, Suspended}},
+			[]int64{t2, t0, t0},
+			[]Market{{total, Suspended, t0}, {winner, Open, t0}},
+			[]Change{{LevelOutcome, home, EventSuspended, Suspended, t2}},
+		},
+		{
+			"reopened, and a market taken down",
+			[]Outcome{{home, winner, Open}, {away, winner, Open}},
+			[]int64{t3, t0},
+			[]Market{{total, Closed, t3}, {winner, Open, t0}},
+			[]Change{
+				{LevelMarket, total, EventClosed, Closed, t3},
+				{LevelOutcome, over, EventClosed, Closed, t3},
+				{LevelOutcome, home, EventReopened, Open, t3},
+			},
+		},
+		{
+			"settled",
+			[]Outcome{{home, winner, Closed}, {away, winner, Closed}},
+			[]int64{t4, t4},
+			[]Market{{total, Closed, t3}, {winner, Closed, t4}},
+			[]Change{
+				{LevelMarket, winner, EventClosed, Closed, t4},
+				{LevelOutcome, away, EventClosed, Closed, t4},
+				{LevelOutcome, home, EventClosed, Closed, t4},
+			},
+		},
+	}
+
+	var history []Change
+	for _, step := range steps {
+		result := update(step.outcomes...)
+		if !reflect.DeepEqual(result.Since, step.since) {
+			t.Errorf("%s: since %v, want %v", step.name, result.Since, step.since)
+		}
+		if !reflect.DeepEqual(result.Markets, step.markets) {
+			t.Errorf("%s: markets %+v, want %+v", step.name, result.Markets, step.markets)
+		}
+		if !reflect.DeepEqual(result.Changes, step.changes) {
+			t.Errorf("%s: changes %+v, want %+v", step.name, result.Changes, step.changes)
+		}
+		history = append(history, step.changes...)
+	}
+
+	if got := tracker.History("unibet-ubbe-1"); !reflect.DeepEqual(got, history) {
+		t.Errorf("history %+v, want %+v", got, history)
+	}
+	if got := tracker.History("unibet-ubbe-2"); got == nil || len(got) != 0 {
+		t.Errorf("history of an unknown event %v, want empty", got)
+	}
+}
+
+func TestHistoryIsCapped(t *testing.T) {
+	tracker := NewTracker()
+	statuses := []string{Open, Suspended}
+	for i := 0; i < maxHistory+10; i++ {
+		tracker.Update("unibet-ubbe-1", []Outcome{{home, winner, statuses[i%2]}})
+	}
+	history := tracker.History("unibet-ubbe-1")
+	// Every update changes the outcome and its market.
+	if len(history) != maxHistory {
+		t.Errorf("kept %d changes, want %d", len(history), maxHistory)
+	}
+	if last := history[len(history)-1]; last.Status != statuses[(maxHistory+9)%2] {
+		t.Errorf("latest change %+v is not the last update", last)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Unix(1760000000, 0)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Update("unibet-ubbe-1", []Outcome{{home, winner, Open}})
+	now = now.Add(time.Hour)
+	tracker.Update("unibet-ubbe-2", []Outcome{{home, winner, Open}})
+	now = now.Add(time.Minute)
+
+	tracker.Prune(30 * time.Minute)
+	if got := tracker.History("unibet-ubbe-1"); len(got) != 0 {
+		t.Errorf("pruned event kept %d changes", len(got))
+	}
+	if got := tracker.History("unibet-ubbe-2"); len(got) != 2 {
+		t.Errorf("recent event has %d changes, want 2", len(got))
+	}
+
+	// A forgotten event is reported as opened again.
+	result := tracker.Update("unibet-ubbe-1", []Outcome{{home, winner, Open}})
+	if len(result.Changes) != 2 || result.Changes[0].Event != EventOpened || result.Since[0] != now.Unix() {
+		t.Errorf("update after pruning %+v", result)
+	}
+}
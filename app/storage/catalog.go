@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"test_task_app/helper"
+	"test_task_app/status"
+)
+
+// CatalogFileName is the file inside the data directory that maps storage
+// keys back to events.
+const CatalogFileName = "catalog.json"
+
+// CatalogEntry describes the event stored under Key.
+type CatalogEntry struct {
+	Key       string `json:"key"`
+	Provider  string `json:"provider"`
+	Operator  string `json:"operator,omitempty"`
+	EventID   int    `json:"event_id"`
+	MatchName string `json:"match_name"`
+	HomeTeam  string `json:"home_team"`
+	AwayTeam  string `json:"away_team"`
+	Sport     string `json:"sport"`
+	League    string `json:"league"`
+	StartTime int64  `json:"start_time"`
+	// Status is CLOSED once every market of the event closed, OPEN before.
+	Status    string `json:"status,omitempty"`
+	FirstSeen int64  `json:"first_seen"`
+	LastSeen  int64  `json:"last_seen"`
+}
+
+// Catalog maps storage keys to human readable event descriptions and keeps
+// them persisted as a single JSON file. Changes are only kept in memory until
+// Save, which the Writer calls on its flush interval.
+type Catalog struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]CatalogEntry
+	dirty   bool
+}
+
+// OpenCatalog loads the catalog from dir, starting empty if it does not exist yet.
+func OpenCatalog(dir string) (*Catalog, error) {
+	c := &Catalog{
+		path:    filepath.Join(dir, CatalogFileName),
+		entries: make(map[string]CatalogEntry),
+	}
+
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []CatalogEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		c.entries[entry.Key] = entry
+	}
+	return c, nil
+}
+
+// Update records the event, marking the catalog for saving when the
+// description changed. LastSeen alone is not worth a rewrite, it is saved
+// with the next real change.
+func (c *Catalog) Update(data helper.ProcessedData) {
+	key := data.StorageKey()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[key]
+	updated := CatalogEntry{
+		Key:       key,
+		Provider:  data.Provider,
+		Operator:  data.Operator,
+		EventID:   data.EventID,
+		MatchName: data.MatchName,
+		HomeTeam:  data.HomeTeam,
+		AwayTeam:  data.AwayTeam,
+		Sport:     data.Sport,
+		League:    data.League,
+		StartTime: data.StartTime,
+		Status:    eventStatus(data),
+		FirstSeen: entry.FirstSeen,
+		LastSeen:  data.Time,
+	}
+	if !exists {
+		updated.FirstSeen = data.Time
+	}
+	c.entries[key] = updated
+
+	entry.LastSeen = updated.LastSeen
+	if !exists || entry != updated {
+		c.dirty = true
+	}
+}
+
+// Prune removes the entries keep rejects and returns how many it removed.
+func (c *Catalog) Prune(keep func(CatalogEntry) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, entry := range c.entries {
+		if !keep(entry) {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	if removed > 0 {
+		c.dirty = true
+	}
+	return removed
+}
+
+// eventStatus is CLOSED when the event has markets and all of them closed.
+func eventStatus(data helper.ProcessedData) string {
+	if len(data.Markets) == 0 {
+		return status.Open
+	}
+	for _, m := range data.Markets {
+		if m.Status != status.Closed {
+			return status.Open
+		}
+	}
+	return status.Closed
+}
+
+// Get returns the entry stored under key.
+func (c *Catalog) Get(key string) (CatalogEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+// Save writes the catalog to disk if it changed since the last save.
+func (c *Catalog) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+	if err := c.save(); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+func (c *Catalog) save() error {
+	entries := make([]CatalogEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
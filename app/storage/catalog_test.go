@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCatalogSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, CatalogFileName)
+	catalog, err := OpenCatalog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := snapshot(1, 100)
+	data.MatchName, data.StartTime = "Arsenal vs Chelsea", 1760000000
+	catalog.Update(data)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Update wrote the catalog: %v", err)
+	}
+	if err := catalog.Save(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A newer snapshot with the same description is not worth a rewrite.
+	if err := os.Chtimes(path, time.Unix(0, 0), time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	newer := data
+	newer.Time = 200
+	catalog.Update(newer)
+	if err := catalog.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); !info.ModTime().Equal(time.Unix(0, 0)) {
+		t.Error("Save rewrote an unchanged catalog")
+	}
+
+	renamed := data
+	renamed.Time = 300
+	renamed.MatchName = "Arsenal vs Chelsea FC"
+	catalog.Update(renamed)
+	if err := catalog.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.ModTime().Equal(time.Unix(0, 0)) || info.Size() == saved.Size() {
+		t.Error("Save did not write the changed description")
+	}
+
+	reopened, err := OpenCatalog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := reopened.Get(data.StorageKey())
+	if !ok || entry.MatchName != renamed.MatchName || entry.FirstSeen != 100 || entry.LastSeen != 300 || entry.StartTime != data.StartTime {
+		t.Errorf("reopened entry %+v", entry)
+	}
+}
+
+func TestCatalogPrune(t *testing.T) {
+	dir := t.TempDir()
+	catalog, err := OpenCatalog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := 1; id <= 3; id++ {
+		catalog.Update(snapshot(id, int64(id)))
+	}
+	if err := catalog.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	removed := catalog.Prune(func(entry CatalogEntry) bool { return entry.EventID != 2 })
+	if removed != 1 {
+		t.Errorf("Prune() = %d, want 1", removed)
+	}
+	if err := catalog.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenCatalog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[int]bool{1: true, 2: false, 3: true} {
+		if _, ok := reopened.Get(snapshot(id, 0).StorageKey()); ok != want {
+			t.Errorf("event %d in catalog: %v, want %v", id, ok, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"test_task_app/helper"
+)
+
+// FileStore appends every snapshot as a JSON line to <dir>/<storage key>.jsonl.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (fs *FileStore) Save(ctx context.Context, data helper.ProcessedData) error {
+	record, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	// The file is reopened on every append so retention can rotate it safely.
+	file, err := os.OpenFile(filepath.Join(fs.dir, data.StorageKey()+".jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(record, '\n'))
+	return err
+}
+
+// SaveBatch appends the batch grouped by event and syncs every touched file
+// once. Events whose file cannot be written are reported in a *BatchError,
+// the others are saved.
+func (fs *FileStore) SaveBatch(ctx context.Context, batch []helper.ProcessedData) error {
+	records := make(map[string][]byte)
+	failed := make(map[string]error)
+	var keys []string
+	for _, data := range batch {
+		key := data.StorageKey()
+		record, err := json.Marshal(data)
+		if err != nil {
+			failed[key] = err
+			continue
+		}
+		if _, ok := records[key]; !ok {
+			keys = append(keys, key)
+		}
+		records[key] = append(append(records[key], record...), '\n')
+	}
+
+	for _, key := range keys {
+		if _, ok := failed[key]; ok {
+			continue
+		}
+		if err := fs.appendSync(key, records[key]); err != nil {
+			failed[key] = err
+		}
+	}
+	if len(failed) > 0 {
+		return &BatchError{Failed: failed}
+	}
+	return nil
+}
+
+func (fs *FileStore) appendSync(key string, records []byte) error {
+	file, err := os.OpenFile(filepath.Join(fs.dir, key+".jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(records); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (fs *FileStore) Close() error {
+	return nil
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"test_task_app/helper"
+)
+
+func snapshot(eventID int, time int64) helper.ProcessedData {
+	return helper.ProcessedData{
+		Provider:  "unibet",
+		Operator:  "ubbe",
+		EventID:   eventID,
+		Time:      time,
+		Type:      "Match",
+		Sport:     "Football",
+		League:    "Premier League",
+		MatchName: "Arsenal - Chelsea",
+		StartTime: 1700000000,
+	}
+}
+
+// readLines returns the snapshots of a storage key, in the order written.
+func readLines(t *testing.T, dir, key string) []helper.ProcessedData {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join(dir, key+".jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var snapshots []helper.ProcessedData
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var data helper.ProcessedData
+		if err := json.Unmarshal(scanner.Bytes(), &data); err != nil {
+			t.Fatalf("line %d of %s: %v", len(snapshots)+1, key, err)
+		}
+		snapshots = append(snapshots, data)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return snapshots
+}
+
+func assertTimes(t *testing.T, key string, got []helper.ProcessedData, want ...int64) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d snapshots, want %d", key, len(got), len(want))
+	}
+	for i, data := range got {
+		if data.Time != want[i] {
+			t.Errorf("%s: snapshot %d has time %d, want %d", key, i, data.Time, want[i])
+		}
+		if data.StorageKey() != key {
+			t.Errorf("%s: snapshot %d has key %s", key, i, data.StorageKey())
+		}
+	}
+}
+
+func TestFileStoreSave(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+	ctx := context.Background()
+
+	for _, data := range []helper.ProcessedData{snapshot(1, 10), snapshot(2, 11), snapshot(1, 12)} {
+		if err := store.Save(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, second := snapshot(1, 0).StorageKey(), snapshot(2, 0).StorageKey()
+	assertTimes(t, first, readLines(t, dir, first), 10, 12)
+	assertTimes(t, second, readLines(t, dir, second), 11)
+	if got := readLines(t, dir, first)[0]; got.MatchName != "Arsenal - Chelsea" || got.Operator != "ubbe" {
+		t.Errorf("snapshot read back as %+v", got)
+	}
+}
+
+func TestFileStoreSaveBatch(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+	ctx := context.Background()
+
+	if err := store.Save(ctx, snapshot(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	batch := []helper.ProcessedData{snapshot(1, 2), snapshot(2, 3), snapshot(1, 4), snapshot(2, 5)}
+	if err := store.SaveBatch(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := snapshot(1, 0).StorageKey(), snapshot(2, 0).StorageKey()
+	assertTimes(t, first, readLines(t, dir, first), 1, 2, 4)
+	assertTimes(t, second, readLines(t, dir, second), 3, 5)
+}
+
+func TestFileStoreSaveBatchReportsFailedKeys(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+
+	// A directory in place of the file makes appending to it fail.
+	blocked := snapshot(1, 0).StorageKey()
+	if err := os.Mkdir(filepath.Join(dir, blocked+".jsonl"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := store.SaveBatch(context.Background(), []helper.ProcessedData{snapshot(1, 1), snapshot(2, 2)})
+	batchErr, ok := err.(*BatchError)
+	if !ok {
+		t.Fatalf("got error %v, want a *BatchError", err)
+	}
+	if _, ok := batchErr.Failed[blocked]; !ok || len(batchErr.Failed) != 1 {
+		t.Errorf("failed keys %v, want only %s", batchErr.Failed, blocked)
+	}
+
+	saved := snapshot(2, 0).StorageKey()
+	assertTimes(t, saved, readLines(t, dir, saved), 2)
+}
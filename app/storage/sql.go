@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"test_task_app/helper"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+const createSnapshotsTable = `
+CREATE TABLE IF NOT EXISTS odds_snapshots (
+	provider   TEXT    NOT NULL,
+	operator   TEXT    NOT NULL DEFAULT '',
+	event_id   BIGINT  NOT NULL,
+	time       BIGINT  NOT NULL,
+	type       TEXT    NOT NULL,
+	sport      TEXT    NOT NULL,
+	league     TEXT    NOT NULL,
+	match_name TEXT    NOT NULL,
+	start_time BIGINT  NOT NULL,
+	payload    TEXT    NOT NULL
+)`
+
+// addOperatorColumn upgrades tables created before operators were tracked.
+const addOperatorColumn = `
+ALTER TABLE odds_snapshots ADD COLUMN operator TEXT NOT NULL DEFAULT ''`
+
+const createSnapshotsIndex = `
+CREATE INDEX IF NOT EXISTS odds_snapshots_event ON odds_snapshots (provider, event_id, time)`
+
+// SQLStore keeps snapshots in an odds_snapshots table. The full snapshot is
+// stored as JSON in payload, the other columns are there for querying.
+type SQLStore struct {
+	db     *sql.DB
+	insert string
+}
+
+// NewSQLiteStore opens an embedded SQLite database at path.
+func NewSQLiteStore(path string) (*SQLStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, serialize on our side instead of getting SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return newSQLStore(db, "?")
+}
+
+// NewSQLStore connects to a Postgres-compatible database through driver.
+func NewSQLStore(driver, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return newSQLStore(db, "$")
+}
+
+func newSQLStore(db *sql.DB, placeholder string) (*SQLStore, error) {
+	if _, err := db.Exec(createSnapshotsTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+	// Neither SQLite nor older Postgres know ADD COLUMN IF NOT EXISTS.
+	if _, err := db.Exec("SELECT operator FROM odds_snapshots LIMIT 0"); err != nil {
+		if _, err := db.Exec(addOperatorColumn); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("upgrade schema: %w", err)
+		}
+	}
+	if _, err := db.Exec(createSnapshotsIndex); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+
+	args := make([]interface{}, 10)
+	for i := range args {
+		if placeholder == "$" {
+			args[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			args[i] = placeholder
+		}
+	}
+
+	return &SQLStore{
+		db: db,
+		insert: fmt.Sprintf(`INSERT INTO odds_snapshots
+			(provider, operator, event_id, time, type, sport, league, match_name, start_time, payload)
+			VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s)`, args...),
+	}, nil
+}
+
+func (s *SQLStore) Save(ctx context.Context, data helper.ProcessedData) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, s.insert,
+		data.Provider, data.Operator, data.EventID, data.Time, data.Type, data.Sport, data.League, data.MatchName, data.StartTime, string(payload))
+	return err
+}
+
+// SaveBatch inserts the batch in one transaction.
+func (s *SQLStore) SaveBatch(ctx context.Context, batch []helper.ProcessedData) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.insert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, data := range batch {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx,
+			data.Provider, data.Operator, data.EventID, data.Time, data.Type, data.Sport, data.League, data.MatchName, data.StartTime, string(payload)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
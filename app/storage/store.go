@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"test_task_app/config"
+	"test_task_app/helper"
+)
+
+const (
+	BackendFile   = "file"
+	BackendSQLite = "sqlite"
+	BackendSQL    = "sql"
+)
+
+// Store persists processed odds snapshots.
+type Store interface {
+	Save(ctx context.Context, data helper.ProcessedData) error
+	Close() error
+}
+
+// BatchStore is implemented by stores that can persist several snapshots at
+// once and make them durable with a single sync.
+type BatchStore interface {
+	Store
+	SaveBatch(ctx context.Context, batch []helper.ProcessedData) error
+}
+
+// BatchError reports the snapshots of a batch that were not saved, by storage
+// key; those of the other keys were.
+type BatchError struct {
+	Failed map[string]error
+}
+
+func (e *BatchError) Error() string {
+	keys := make([]string, 0, len(e.Failed))
+	for key := range e.Failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %v", key, e.Failed[key]))
+	}
+	return fmt.Sprintf("%d of the batch's events failed: %s", len(keys), strings.Join(parts, "; "))
+}
+
+// New creates the store selected by cfg.StorageBackend.
+func New(cfg config.Config) (Store, error) {
+	switch cfg.StorageBackend {
+	case BackendFile, "":
+		return NewFileStore(cfg.PathToData), nil
+	case BackendSQLite:
+		path := cfg.SQLitePath
+		if path == "" {
+			path = filepath.Join(cfg.PathToData, "odds.db")
+		}
+		return NewSQLiteStore(path)
+	case BackendSQL:
+		return NewSQLStore(cfg.SQLDriver, cfg.SQLDSN)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
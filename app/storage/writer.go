@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"test_task_app/config"
+	"test_task_app/helper"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrWriterClosed is returned by Save once the writer has been closed.
+var ErrWriterClosed = errors.New("odds writer is closed")
+
+// Failed snapshots are retried after retryBackoff, doubling up to
+// maxRetryBackoff while the writes keep failing. Close tries closeRetries
+// more times before giving up on them.
+const (
+	retryBackoff    = time.Second
+	maxRetryBackoff = time.Minute
+	closeRetries    = 3
+)
+
+// WriterStats describes the writer queue, used to spot backpressure.
+type WriterStats struct {
+	QueueLength    int     `json:"queue_length"`
+	QueueCapacity  int     `json:"queue_capacity"`
+	QueueHighWater int64   `json:"queue_high_water"`
+	Enqueued       int64   `json:"enqueued"`
+	Written        int64   `json:"written"`
+	Failed         int64   `json:"failed"`
+	Retrying       int64   `json:"retrying"`
+	Dropped        int64   `json:"dropped"`
+	Batches        int64   `json:"batches"`
+	Blocked        int64   `json:"blocked"`
+	BlockedSeconds float64 `json:"blocked_seconds"`
+	LastBatchSize  int64   `json:"last_batch_size"`
+	LastFlushMs    float64 `json:"last_flush_ms"`
+}
+
+// Writer is a Store that queues snapshots and persists them from a single
+// goroutine in batches, so pollers never wait on disk unless the queue is full.
+// It saves the catalog on the same interval.
+type Writer struct {
+	store         Store
+	catalog       *Catalog
+	queue         chan helper.ProcessedData
+	batchSize     int
+	flushInterval time.Duration
+	Log           *logrus.Logger
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	highWater   atomic.Int64
+	enqueued    atomic.Int64
+	written     atomic.Int64
+	failed      atomic.Int64
+	retrying    atomic.Int64
+	dropped     atomic.Int64
+	batches     atomic.Int64
+	blocked     atomic.Int64
+	blockedNs   atomic.Int64
+	lastBatch   atomic.Int64
+	lastFlushNs atomic.Int64
+}
+
+func NewWriter(cfg config.Config, store Store, catalog *Catalog, log *logrus.Logger) *Writer {
+	w := &Writer{
+		store:         store,
+		catalog:       catalog,
+		queue:         make(chan helper.ProcessedData, cfg.WriterQueueSize),
+		batchSize:     cfg.WriterBatchSize,
+		flushInterval: cfg.WriterFlushInterval,
+		Log:           log,
+		done:          make(chan struct{}),
+	}
+	if w.batchSize <= 0 {
+		w.batchSize = 1
+	}
+
+	go w.run()
+	return w
+}
+
+// Save enqueues the snapshot. When the queue is full it blocks until there is
+// room, which is reported in the stats as backpressure. ctx being done does
+// not abandon the snapshot: the writer keeps draining the queue until Close,
+// so pollers cancelled at shutdown still get theirs written.
+func (w *Writer) Save(ctx context.Context, data helper.ProcessedData) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	select {
+	case w.queue <- data:
+	default:
+		w.blocked.Add(1)
+		start := time.Now()
+		w.queue <- data
+		w.blockedNs.Add(int64(time.Since(start)))
+	}
+
+	w.enqueued.Add(1)
+	if length := int64(len(w.queue)); length > w.highWater.Load() {
+		w.highWater.Store(length)
+	}
+	return nil
+}
+
+// Close stops accepting snapshots, flushes everything still queued, retrying
+// failed writes a few more times, and closes the underlying store.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	<-w.done
+	return w.store.Close()
+}
+
+func (w *Writer) Stats() WriterStats {
+	return WriterStats{
+		QueueLength:    len(w.queue),
+		QueueCapacity:  cap(w.queue),
+		QueueHighWater: w.highWater.Load(),
+		Enqueued:       w.enqueued.Load(),
+		Written:        w.written.Load(),
+		Failed:         w.failed.Load(),
+		Retrying:       w.retrying.Load(),
+		Dropped:        w.dropped.Load(),
+		Batches:        w.batches.Load(),
+		Blocked:        w.blocked.Load(),
+		BlockedSeconds: time.Duration(w.blockedNs.Load()).Seconds(),
+		LastBatchSize:  w.lastBatch.Load(),
+		LastFlushMs:    float64(w.lastFlushNs.Load()) / float64(time.Millisecond),
+	}
+}
+
+// run batches the queue. Snapshots that failed to save are kept and written
+// again with the next flush once their backoff has passed; beyond a queue's
+// worth of them the oldest are dropped.
+func (w *Writer) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]helper.ProcessedData, 0, w.batchSize)
+	var retry []helper.ProcessedData
+	var nextRetry time.Time
+	backoff := retryBackoff
+
+	flush := func(force bool) {
+		retried := false
+		if len(retry) > 0 && (force || !time.Now().Before(nextRetry)) {
+			batch, retry, retried = append(retry, batch...), nil, true
+		} else if len(retry) > 0 {
+			// Later snapshots of a failed event wait for it, so its
+			// history stays in order.
+			waiting := make(map[string]struct{}, len(retry))
+			for _, data := range retry {
+				waiting[data.StorageKey()] = struct{}{}
+			}
+			ready := batch[:0]
+			for _, data := range batch {
+				if _, ok := waiting[data.StorageKey()]; ok {
+					retry = append(retry, data)
+				} else {
+					ready = append(ready, data)
+				}
+			}
+			batch = ready
+		}
+		if len(batch) > 0 {
+			failed := w.flush(batch)
+			switch {
+			case len(failed) == 0 && retried:
+				backoff = retryBackoff
+			case len(failed) > 0 && retried:
+				backoff = min(backoff*2, maxRetryBackoff)
+			}
+			// A fresh batch failing does not put off the retries already
+			// waiting for their backoff.
+			if now := time.Now(); len(failed) > 0 && (retried || !nextRetry.After(now)) {
+				nextRetry = now.Add(backoff)
+			}
+			retry = append(retry, failed...)
+			batch = make([]helper.ProcessedData, 0, w.batchSize)
+		}
+		if excess := len(retry) - cap(w.queue); excess > 0 {
+			w.dropped.Add(int64(excess))
+			w.Log.Errorf("error writing snapshots: dropping the %d oldest of %d failed snapshots", excess, len(retry))
+			retry = retry[excess:]
+		}
+		w.retrying.Store(int64(len(retry)))
+	}
+
+	for {
+		select {
+		case data, ok := <-w.queue:
+			if !ok {
+				flush(true)
+				for attempt := 0; attempt < closeRetries && len(retry) > 0; attempt++ {
+					time.Sleep(retryBackoff)
+					flush(true)
+				}
+				if len(retry) > 0 {
+					w.dropped.Add(int64(len(retry)))
+					w.retrying.Store(0)
+					w.Log.Errorf("error writing snapshots: %d could not be written before closing", len(retry))
+				}
+				w.saveCatalog()
+				return
+			}
+			batch = append(batch, data)
+			if len(batch) >= w.batchSize {
+				flush(false)
+			}
+		case <-ticker.C:
+			flush(false)
+			w.saveCatalog()
+		}
+	}
+}
+
+func (w *Writer) saveCatalog() {
+	if w.catalog == nil {
+		return
+	}
+	if err := w.catalog.Save(); err != nil {
+		w.Log.Errorf("error saving catalog: %v", err)
+	}
+}
+
+// flush writes the batch and returns the snapshots that failed: the events
+// a *BatchError names, or all of them on any other error.
+func (w *Writer) flush(batch []helper.ProcessedData) []helper.ProcessedData {
+	// Writes are not tied to any poller, a shutdown must not abort them.
+	ctx := context.Background()
+	start := time.Now()
+
+	var err error
+	if bs, ok := w.store.(BatchStore); ok {
+		err = bs.SaveBatch(ctx, batch)
+	} else {
+		failed := make(map[string]error)
+		for _, data := range batch {
+			if saveErr := w.store.Save(ctx, data); saveErr != nil {
+				failed[data.StorageKey()] = saveErr
+			}
+		}
+		if len(failed) > 0 {
+			err = &BatchError{Failed: failed}
+		}
+	}
+
+	w.lastFlushNs.Store(int64(time.Since(start)))
+	w.lastBatch.Store(int64(len(batch)))
+	w.batches.Add(1)
+	if err == nil {
+		w.written.Add(int64(len(batch)))
+		return nil
+	}
+
+	failed := batch
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		failed = nil
+		for _, data := range batch {
+			if _, ok := batchErr.Failed[data.StorageKey()]; ok {
+				failed = append(failed, data)
+			}
+		}
+		for key, keyErr := range batchErr.Failed {
+			w.Log.Errorf("error writing snapshots of %s, retrying: %v", key, keyErr)
+		}
+	} else {
+		w.Log.Errorf("error writing batch of %d snapshots, retrying: %v", len(batch), err)
+	}
+	w.written.Add(int64(len(batch) - len(failed)))
+	w.failed.Add(int64(len(failed)))
+	return failed
+}
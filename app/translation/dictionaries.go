@@ -0,0 +1,133 @@
+package translation
+
+// dictionaries map lower-cased Kambi criterion label phrases, per language,
+// to the canonical English phrases standardization matches on. Labels are
+// translated phrase by phrase, longest match first, so every entry only has
+// to cover the fixed part of a label; participant names and numbers pass
+// through untouched.
+//
+// The English dictionary doubles as the vocabulary of canonical phrases and
+// folds English synonyms ("1st half", "2nd half") into one spelling. It is
+// consulted for every language because Kambi mixes English terms such as
+// "handicap" into translated labels.
+var dictionaries = map[string]map[string]string{
+	"en": {
+		"match odds": "match odds", "match": "match", "odds": "odds", "full time": "full time", "1x2": "1x2",
+		"half time": "half time", "first half": "first half", "1st half": "first half",
+		"second half": "second half", "2nd half": "second half", "regular time": "regular time",
+		"total goals": "total goals", "total": "total", "goals": "goals", "goal": "goal",
+		"asian total": "asian total", "asian handicap": "asian handicap", "asian": "asian",
+		"handicap": "handicap", "3-way handicap": "3-way handicap", "european handicap": "european handicap",
+		"game handicap": "game handicap", "set handicap": "set handicap",
+		"total games": "total games", "total sets": "total sets", "games": "games", "game": "game",
+		"sets": "sets", "set": "set", "point": "point", "points": "points", "tie-break": "tie-break",
+		"tiebreak": "tie-break", "by": "by", "over": "over", "under": "under", "and": "and", "to": "to",
+		"win": "win", "winner": "winner", "next": "next", "score": "score", "scores": "scores",
+		"correct score": "correct score", "both teams to score": "both teams to score",
+		"double chance": "double chance", "draw no bet": "draw no bet", "draw": "draw",
+		"half time/full time": "half time/full time", "corners": "corners", "cards": "cards",
+		"player": "player", "team": "team", "home": "home", "away": "away", "yes": "yes", "no": "no",
+		"tie break": "tie-break", "set betting": "correct score", "won": "won", "in": "in", "of": "of",
+		"most": "most", "corner": "corners", "card": "cards", "booking": "cards", "bookings": "cards",
+		"3-way": "3-way", "european": "european", "both": "both", "teams": "teams",
+		"player's": "player", "players": "player", "goalscorer": "goalscorer", "goal scorer": "goalscorer",
+		"anytime": "anytime", "first": "first", "last": "last", "shots": "shots", "shots on target": "shots on target",
+		"on target": "on target", "assists": "assists", "booked": "booked", "to be booked": "to be booked", "be": "be",
+		"aces": "aces", "double faults": "double faults",
+		"outright": "outright", "top goalscorer": "top goalscorer", "top scorer": "top goalscorer",
+		"relegated": "relegated", "to be relegated": "to be relegated", "relegation": "relegated", "group": "group",
+	},
+	"nl": {
+		"noteringen wedstrijd": "match odds", "noteringen": "odds", "wedstrijd": "match",
+		"volledige wedstrijd": "full time", "eindstand": "full time", "reguliere speeltijd": "regular time",
+		"rust": "half time", "1e helft": "first half", "eerste helft": "first half",
+		"2e helft": "second half", "tweede helft": "second half",
+		"totaal aantal doelpunten": "total goals", "aantal doelpunten": "total goals", "doelpunten": "goals",
+		"doelpunt": "goal", "totaal": "total", "aziatisch totaal": "asian total",
+		"aziatische handicap": "asian handicap", "3-weg handicap": "3-way handicap",
+		"europese handicap": "european handicap", "totaal aantal games": "total games",
+		"totaal aantal sets": "total sets", "game handicap": "game handicap", "set handicap": "set handicap",
+		"punt": "point", "punten": "points", "tiebreak": "tie-break",
+		"door": "by", "meer dan": "over", "minder dan": "under", "boven": "over", "onder": "under",
+		"en": "and", "winnaar": "winner", "volgende": "next", "juiste score": "correct score",
+		"correcte score": "correct score", "beide teams scoren": "both teams to score",
+		"dubbele kans": "double chance", "gelijkspel geen weddenschap": "draw no bet", "gelijkspel": "draw",
+		"rust/eindstand": "half time/full time", "hoekschoppen": "corners", "corners": "corners",
+		"kaarten": "cards", "speler": "player", "thuis": "home", "uit": "away", "ja": "yes", "nee": "no",
+		"gewonnen": "won", "setstand": "correct score",
+		"meeste": "most", "aantal hoekschoppen": "corners", "aantal kaarten": "cards",
+		"doelpuntenmaker": "goalscorer", "op elk moment": "anytime", "eerste": "first", "laatste": "last",
+		"schoten": "shots", "schoten op doel": "shots on target", "geboekt": "booked", "dubbele fouten": "double faults",
+		"topscorer": "top goalscorer", "degradatie": "relegated", "degradeert": "relegated", "groep": "group",
+	},
+	"fr": {
+		"cotes du match": "match odds", "cotes": "odds", "match": "match", "temps réglementaire": "full time",
+		"résultat du match": "full time", "mi-temps": "half time", "1ère mi-temps": "first half",
+		"première mi-temps": "first half", "2ème mi-temps": "second half", "2e mi-temps": "second half",
+		"seconde mi-temps": "second half", "deuxième mi-temps": "second half",
+		"total de buts": "total goals", "nombre total de buts": "total goals", "buts": "goals", "but": "goal",
+		"total asiatique": "asian total", "handicap asiatique": "asian handicap",
+		"handicap à 3 issues": "3-way handicap", "handicap européen": "european handicap",
+		"total de jeux": "total games", "total de sets": "total sets", "jeux": "games", "jeu": "game",
+		"handicap jeux": "game handicap", "handicap sets": "set handicap",
+		"point": "point", "points": "points", "tie-break": "tie-break",
+		"par": "by", "plus de": "over", "moins de": "under", "et": "and", "vainqueur": "winner",
+		"prochain": "next", "score exact": "correct score", "les deux équipes marquent": "both teams to score",
+		"double chance": "double chance", "remboursé si match nul": "draw no bet", "match nul": "draw",
+		"mi-temps/fin de match": "half time/full time", "corners": "corners", "cartons": "cards",
+		"joueur": "player", "équipe": "team", "domicile": "home", "extérieur": "away", "oui": "yes", "non": "no",
+		"jeu décisif": "tie-break", "gagnés": "won", "dans": "in",
+		"le plus de": "most", "nombre de corners": "corners", "nombre de cartons": "cards",
+		"buteur": "goalscorer", "à tout moment": "anytime", "premier": "first", "dernier": "last", "tirs": "shots",
+		"tirs cadrés": "shots on target", "passes décisives": "assists", "averti": "booked",
+		"aces": "aces", "doubles fautes": "double faults",
+		"meilleur buteur": "top goalscorer", "relégation": "relegated", "relégué": "relegated", "groupe": "group",
+	},
+	"de": {
+		"spielquoten": "match odds", "quoten": "odds", "spiel": "match", "endergebnis": "full time",
+		"reguläre spielzeit": "regular time", "halbzeit": "half time", "1. halbzeit": "first half",
+		"erste halbzeit": "first half", "2. halbzeit": "second half", "zweite halbzeit": "second half",
+		"tore gesamt": "total goals", "gesamtzahl tore": "total goals", "tore": "goals", "tor": "goal",
+		"gesamt": "total", "asiatisches total": "asian total", "asiatisches handicap": "asian handicap",
+		"3-weg handicap": "3-way handicap", "europäisches handicap": "european handicap",
+		"spiele gesamt": "total games", "sätze gesamt": "total sets", "spiele": "games", "sätze": "sets",
+		"satz": "set", "spielhandicap": "game handicap", "satzhandicap": "set handicap",
+		"punkt": "point", "punkte": "points", "tiebreak": "tie-break",
+		"von": "by", "über": "over", "unter": "under", "und": "and", "sieger": "winner", "gewinner": "winner",
+		"nächstes": "next", "nächster": "next", "genaues ergebnis": "correct score",
+		"korrektes ergebnis": "correct score", "beide teams treffen": "both teams to score",
+		"doppelte chance": "double chance", "unentschieden keine wette": "draw no bet",
+		"unentschieden": "draw", "halbzeit/endstand": "half time/full time", "ecken": "corners",
+		"eckbälle": "corners", "karten": "cards", "spieler": "player", "mannschaft": "team",
+		"heim": "home", "auswärts": "away", "ja": "yes", "nein": "no",
+		"gewonnen": "won", "gewonnene spiele": "games won", "satzwetten": "correct score", "im": "in",
+		"meiste": "most", "die meisten": "most", "anzahl ecken": "corners", "anzahl karten": "cards",
+		"torschütze": "goalscorer", "jederzeit": "anytime", "erster": "first", "letzter": "last",
+		"schüsse": "shots", "schüsse aufs tor": "shots on target", "vorlagen": "assists", "verwarnt": "booked",
+		"asse": "aces", "doppelfehler": "double faults",
+		"torschützenkönig": "top goalscorer", "abstieg": "relegated", "absteiger": "relegated", "gruppe": "group",
+	},
+	"sv": {
+		"matchodds": "match odds", "odds": "odds", "match": "match", "fulltid": "full time",
+		"ordinarie tid": "regular time", "halvtid": "half time", "1:a halvlek": "first half",
+		"första halvlek": "first half", "2:a halvlek": "second half", "andra halvlek": "second half",
+		"totalt antal mål": "total goals", "antal mål": "total goals", "mål": "goals", "totalt": "total",
+		"asiatisk total": "asian total", "asiatiskt handikapp": "asian handicap", "handikapp": "handicap",
+		"3-vägs handikapp": "3-way handicap", "europeiskt handikapp": "european handicap",
+		"totalt antal gem": "total games", "totalt antal set": "total sets", "gem": "games",
+		"gemhandikapp": "game handicap", "sethandikapp": "set handicap",
+		"poäng": "points", "tiebreak": "tie-break",
+		"av": "by", "över": "over", "under": "under", "och": "and", "vinnare": "winner",
+		"matchvinnare": "winner", "nästa": "next", "rätt resultat": "correct score",
+		"båda lagen gör mål": "both teams to score", "dubbelchans": "double chance",
+		"insatsen tillbaka vid oavgjort": "draw no bet", "oavgjort": "draw",
+		"halvtid/fulltid": "half time/full time", "hörnor": "corners", "kort": "cards",
+		"spelare": "player", "lag": "team", "hemma": "home", "borta": "away", "ja": "yes", "nej": "no",
+		"vunna": "won", "i": "in",
+		"flest": "most", "antal hörnor": "corners", "antal kort": "cards",
+		"målskytt": "goalscorer", "när som helst": "anytime", "första": "first", "sista": "last",
+		"skott": "shots", "skott på mål": "shots on target", "assist": "assists", "varnad": "booked",
+		"ess": "aces", "dubbelfel": "double faults",
+		"skyttekung": "top goalscorer", "nedflyttning": "relegated", "grupp": "group",
+	},
+}
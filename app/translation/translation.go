@@ -0,0 +1,277 @@
+// Package translation maps localized Kambi criterion labels to the canonical
+// English labels market standardization matches on, and keeps a report of
+// the labels no dictionary covers.
+package translation
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReportFileName is the file inside the data directory listing the labels
+// that could not be translated.
+const ReportFileName = "untranslated_labels.json"
+
+// English is the language canonical labels are written in.
+const English = "en"
+
+const (
+	homePlaceholder = "{home}"
+	awayPlaceholder = "{away}"
+)
+
+// neutralToken matches numbers, lines, punctuation, 1X2 notation such as
+// "X2" or "1/X" and single letters such as group names, which need no
+// translation.
+var neutralToken = regexp.MustCompile(`^(?:[0-9x\p{P}\p{S}]+|\p{L})$`)
+
+// maxPhraseWords is the length of the longest dictionary phrase.
+var maxPhraseWords = func() int {
+	longest := 1
+	for _, dictionary := range dictionaries {
+		for phrase := range dictionary {
+			if n := len(strings.Fields(phrase)); n > longest {
+				longest = n
+			}
+		}
+	}
+	return longest
+}()
+
+// Untranslated is a label, with participant names replaced by {home} and
+// {away}, that contained words outside the dictionaries.
+type Untranslated struct {
+	Lang      string `json:"lang"`
+	Label     string `json:"label"`
+	Count     int64  `json:"count"`
+	FirstSeen int64  `json:"first_seen"`
+	LastSeen  int64  `json:"last_seen"`
+}
+
+// Translator translates labels and records the ones it could not.
+type Translator struct {
+	path         string
+	mu           sync.Mutex
+	untranslated map[string]*Untranslated
+	dirty        bool
+	now          func() time.Time
+}
+
+// NewTranslator loads the untranslated labels report from dir, starting
+// empty if it does not exist yet.
+func NewTranslator(dir string) (*Translator, error) {
+	t := &Translator{
+		path:         filepath.Join(dir, ReportFileName),
+		untranslated: make(map[string]*Untranslated),
+		now:          time.Now,
+	}
+
+	data, err := os.ReadFile(t.path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Untranslated
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		t.untranslated[reportKey(entries[i].Lang, entries[i].Label)] = &entries[i]
+	}
+	return t, nil
+}
+
+// Language returns the language of a Kambi locale such as "nl_BE".
+func Language(locale string) string {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "_-"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// Translate returns the canonical, lower-cased English form of a label in
+// lang and whether every word of it was known. Participant names are kept
+// as they are.
+func Translate(lang, label, home, away string) (string, bool) {
+	translated, _, known := translate(Language(lang), label, home, away)
+	return translated, known
+}
+
+// Criterion returns the canonical label of a bet offer criterion. The label
+// in the feed language is preferred, Kambi's englishLabel is the fallback.
+// When neither is fully covered the localized label is reported and the
+// best effort translation returned.
+func (t *Translator) Criterion(lang string, criterion map[string]interface{}, home, away string) string {
+	label, _ := criterion["label"].(string)
+	englishLabel, _ := criterion["englishLabel"].(string)
+	if label == "" {
+		return t.Label(lang, englishLabel, home, away)
+	}
+
+	lang = Language(lang)
+	translated, masked, known := translate(lang, label, home, away)
+	if known {
+		return translated
+	}
+	if englishLabel != "" {
+		if fallback, _, ok := translate(English, englishLabel, home, away); ok {
+			return fallback
+		}
+	}
+	t.record(lang, masked)
+	return translated
+}
+
+// Label translates a single label, reporting it when it is not covered.
+func (t *Translator) Label(lang, label, home, away string) string {
+	lang = Language(lang)
+	translated, masked, known := translate(lang, label, home, away)
+	if !known {
+		t.record(lang, masked)
+	}
+	return translated
+}
+
+// Report returns the untranslated labels, most frequent first.
+func (t *Translator) Report() []Untranslated {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.entries()
+}
+
+// Save persists the report if labels were added since the last save. The
+// counters of known labels are written along with the next new one.
+func (t *Translator) Save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(t.entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return err
+	}
+	t.dirty = false
+	return nil
+}
+
+func (t *Translator) record(lang, label string) {
+	if label == "" {
+		return
+	}
+	now := t.now().Unix()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := reportKey(lang, label)
+	entry, ok := t.untranslated[key]
+	if !ok {
+		entry = &Untranslated{Lang: lang, Label: label, FirstSeen: now}
+		t.untranslated[key] = entry
+		t.dirty = true
+	}
+	entry.Count++
+	entry.LastSeen = now
+}
+
+func (t *Translator) entries() []Untranslated {
+	entries := make([]Untranslated, 0, len(t.untranslated))
+	for _, entry := range t.untranslated {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return reportKey(entries[i].Lang, entries[i].Label) < reportKey(entries[j].Lang, entries[j].Label)
+	})
+	return entries
+}
+
+func reportKey(lang, label string) string {
+	return lang + "\x00" + label
+}
+
+// Mask lower-cases label, collapses its whitespace and replaces the
+// participant names with {home} and {away}, so labels of different events
+// can be grouped.
+func Mask(label, home, away string) string {
+	home, away = strings.ToLower(strings.TrimSpace(home)), strings.ToLower(strings.TrimSpace(away))
+	masked := strings.ToLower(strings.Join(strings.Fields(label), " "))
+	if home != "" {
+		masked = strings.ReplaceAll(masked, home, homePlaceholder)
+	}
+	if away != "" {
+		masked = strings.ReplaceAll(masked, away, awayPlaceholder)
+	}
+	return masked
+}
+
+// translate replaces dictionary phrases of lang, longest first, and returns
+// the translation, the label with participant names masked, and whether
+// every word was known.
+func translate(lang, label, home, away string) (string, string, bool) {
+	masked := Mask(label, home, away)
+
+	lookup := []map[string]string{dictionaries[lang]}
+	if lang != English {
+		lookup = append(lookup, dictionaries[English])
+	}
+
+	tokens := strings.Fields(masked)
+	translated := make([]string, 0, len(tokens))
+	known := true
+	for i := 0; i < len(tokens); {
+		if phrase, n := longestPhrase(tokens[i:], lookup); n > 0 {
+			translated = append(translated, phrase)
+			i += n
+			continue
+		}
+		token := tokens[i]
+		if token != homePlaceholder && token != awayPlaceholder && !neutralToken.MatchString(token) {
+			known = false
+		}
+		translated = append(translated, token)
+		i++
+	}
+
+	result := strings.Join(translated, " ")
+	result = strings.ReplaceAll(result, homePlaceholder, strings.ToLower(strings.TrimSpace(home)))
+	result = strings.ReplaceAll(result, awayPlaceholder, strings.ToLower(strings.TrimSpace(away)))
+	return result, masked, known
+}
+
+// longestPhrase finds the longest dictionary phrase at the start of tokens
+// and returns its translation and length in tokens.
+func longestPhrase(tokens []string, lookup []map[string]string) (string, int) {
+	for n := min(maxPhraseWords, len(tokens)); n > 0; n-- {
+		phrase := strings.Join(tokens[:n], " ")
+		for _, dictionary := range lookup {
+			if translated, ok := dictionary[phrase]; ok {
+				return translated, n
+			}
+		}
+	}
+	return "", 0
+}
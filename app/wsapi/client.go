@@ -0,0 +1,78 @@
+package wsapi
+
+import (
+	"sync"
+
+	"test_task_app/auth"
+	"test_task_app/helper"
+	"test_task_app/payload"
+
+	"github.com/gorilla/websocket"
+)
+
+// Messages a client may send, as JSON text frames.
+const (
+	requestResume   = "resume"
+	requestSnapshot = "snapshot"
+)
+
+// request asks for a replay of the updates after Seq of Epoch, or for a
+// snapshot.
+type request struct {
+	Type  string `json:"type"`
+	Epoch string `json:"epoch,omitempty"`
+	Seq   uint64 `json:"seq,omitempty"`
+}
+
+type client struct {
+	conn      *websocket.Conn
+	principal auth.Principal
+	encoding  string
+	send      chan payload.Frame
+	// requests is read by the writer, resume is the request made in the
+	// query string, if any. lastSeq is the last batch the client has seen,
+	// queued batches up to it are skipped.
+	requests chan request
+	resume   *request
+	lastSeq  uint64
+	// request and pending are what the rate limit holds back: the latest
+	// request, and the updates merged into one frame.
+	request *request
+	pending *payload.Frame
+
+	// done is closed by the first stop, closeCode and closeReason say why.
+	once        sync.Once
+	done        chan struct{}
+	closeCode   int
+	closeReason string
+	readDone    chan struct{}
+}
+
+// hold queues an update frame for the writer, merging it into the updates
+// already waiting. Merged updates become a replay frame of the latest seq.
+func (c *client) hold(frame payload.Frame) {
+	if c.pending == nil {
+		c.pending = &frame
+		return
+	}
+	events := make(map[string]helper.ProcessedData, len(c.pending.Events)+len(frame.Events))
+	for key, data := range c.pending.Events {
+		events[key] = data
+	}
+	for key, data := range frame.Events {
+		events[key] = data
+	}
+	c.pending = &payload.Frame{Type: payload.TypeReplay, Epoch: frame.Epoch, Seq: frame.Seq, Events: events}
+}
+
+// stop ends the connection with the given close code and reason, only the
+// first call counts and reports true.
+func (c *client) stop(code int, reason string) bool {
+	stopped := false
+	c.once.Do(func() {
+		c.closeCode, c.closeReason = code, reason
+		close(c.done)
+		stopped = true
+	})
+	return stopped
+}
@@ -0,0 +1,40 @@
+package wsapi
+
+import (
+	"reflect"
+	"testing"
+
+	"test_task_app/helper"
+	"test_task_app/payload"
+)
+
+func TestHold(t *testing.T) {
+	first, second := event(1, "FOOTBALL", "Premier League"), event(2, "TENNIS", "ATP Vienna")
+	updated := first
+	updated.Time = 1
+	frame := func(seq uint64, events ...helper.ProcessedData) payload.Frame {
+		f := payload.Frame{Type: payload.TypeUpdate, Epoch: "current", Seq: seq, Events: make(map[string]helper.ProcessedData)}
+		for _, data := range events {
+			f.Events[data.StorageKey()] = data
+		}
+		return f
+	}
+
+	c := &client{}
+	held := frame(1, first)
+	c.hold(held)
+	if !reflect.DeepEqual(*c.pending, held) {
+		t.Errorf("held %+v, want the update itself", *c.pending)
+	}
+
+	c.hold(frame(2, second))
+	c.hold(frame(3, updated))
+	want := frame(3, updated, second)
+	want.Type = payload.TypeReplay
+	if !reflect.DeepEqual(*c.pending, want) {
+		t.Errorf("merged %+v, want %+v", *c.pending, want)
+	}
+	if !reflect.DeepEqual(held, frame(1, first)) {
+		t.Errorf("merging changed the held update to %+v", held)
+	}
+}
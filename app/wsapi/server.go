@@ -0,0 +1,521 @@
+// Package wsapi serves the odds feed on /ws. Every client gets its own send
+// queue fed by a single broadcaster, a reader that notices closes and
+// answers pings, and a writer that pings the client and bounds every write
+// with a deadline. A client that cannot keep up is disconnected rather than
+// slowing the others down.
+//
+// Every frame is a payload.Frame. The batches are numbered by Seq within an
+// Epoch, which changes when the parser restarts, so a client notices what it
+// missed. After connecting a client is sent a snapshot of every current
+// event, unless it resumes: with the resume and epoch query parameters, or
+// by sending
+//
+//	{"type":"resume","epoch":"<epoch>","seq":<last seq seen>}
+//
+// within websocket_resume_wait. A resume is answered with one replay frame
+// holding the latest version of every event updated since, as long as those
+// batches are still in the replay buffer, and with a snapshot otherwise. A
+// client may send a resume, on a gap in the sequence, or {"type":"snapshot"}
+// at any time.
+//
+// Connects, requests and update frames count towards the client's messages
+// per minute. Over the limit nothing is dropped: a request waits until the
+// client is under the limit again, and the updates held back meanwhile are
+// merged into one replay frame, so the sequence has no gaps.
+package wsapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"test_task_app/auth"
+	"test_task_app/config"
+	"test_task_app/feed"
+	"test_task_app/helper"
+	"test_task_app/payload"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// closeGrace is how long the writer waits for the client to answer a close
+// frame before the connection is dropped.
+const closeGrace = time.Second
+
+// Stats describes the connected clients and what was sent to them.
+type Stats struct {
+	Clients         int    `json:"clients"`
+	Epoch           string `json:"epoch"`
+	Seq             uint64 `json:"seq"`
+	Sent            int64  `json:"sent"`
+	Snapshots       int64  `json:"snapshots"`
+	Replays         int64  `json:"replays"`
+	RateLimited     int64  `json:"rate_limited"`
+	SlowDisconnects int64  `json:"slow_disconnects"`
+}
+
+// Server broadcasts the update batches to every connected client.
+type Server struct {
+	settings config.Websocket
+	auth     *auth.Authenticator
+	hub      *feed.Hub
+	upgrader websocket.Upgrader
+	log      *logrus.Logger
+	epoch    string
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	closing bool
+	wg      sync.WaitGroup
+	// seq is the last batch sent, history the most recent batches, oldest
+	// first.
+	seq     uint64
+	history []payload.Frame
+
+	sent        atomic.Int64
+	snapshots   atomic.Int64
+	replays     atomic.Int64
+	rateLimited atomic.Int64
+	slow        atomic.Int64
+}
+
+// New serves the batches handed to Run, taking the snapshots sent to clients
+// from hub.
+func New(settings config.Websocket, authenticator *auth.Authenticator, hub *feed.Hub, log *logrus.Logger) *Server {
+	return &Server{
+		settings: settings,
+		auth:     authenticator,
+		hub:      hub,
+		upgrader: websocket.Upgrader{
+			CheckOrigin:       authenticator.CheckOrigin,
+			Subprotocols:      payload.Subprotocols(),
+			EnableCompression: settings.WebsocketCompression,
+		},
+		log:     log,
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		clients: make(map[*client]struct{}),
+	}
+}
+
+// Run numbers every batch from source, keeps it for replays and hands it to
+// the clients until ctx is done or source is closed. Empty batches are
+// skipped. A client whose queue is full is closed as too slow.
+func (s *Server) Run(ctx context.Context, source <-chan map[string]interface{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case batch, ok := <-source:
+			if !ok {
+				return
+			}
+			events := make(map[string]helper.ProcessedData, len(batch))
+			for _, value := range batch {
+				if data, ok := value.(helper.ProcessedData); ok {
+					events[data.StorageKey()] = data
+				}
+			}
+			if len(events) == 0 {
+				continue
+			}
+
+			s.mu.Lock()
+			s.seq++
+			frame := payload.Frame{Type: payload.TypeUpdate, Epoch: s.epoch, Seq: s.seq, Events: events}
+			if s.settings.WebsocketReplayBuffer > 0 {
+				if len(s.history) >= s.settings.WebsocketReplayBuffer {
+					s.history = s.history[1:]
+				}
+				s.history = append(s.history, frame)
+			}
+			for c := range s.clients {
+				select {
+				case c.send <- frame:
+				default:
+					if c.stop(websocket.CloseTryAgainLater, "client too slow, reconnect") {
+						s.slow.Add(1)
+					}
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// ServeHTTP authenticates the client and streams the frames in the
+// encoding it picked, as an odds.<encoding> subprotocol or the encoding query
+// parameter, limited to the sports and leagues it is entitled to.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	principal, err := s.auth.Authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	encoding, err := payload.Negotiate("", r.URL.Query().Get("encoding"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var resume *request
+	if value := r.URL.Query().Get("resume"); value != "" {
+		seq, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			http.Error(w, "resume is not a sequence number", http.StatusBadRequest)
+			return
+		}
+		resume = &request{Type: requestResume, Epoch: r.URL.Query().Get("epoch"), Seq: seq}
+	}
+	if s.isClosing() {
+		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if !s.auth.Allow(principal) {
+		s.rateLimited.Add(1)
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+	release, err := s.auth.Acquire(principal)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	defer release()
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.log.Errorf("error upgrading websocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != "" {
+		encoding, _ = payload.Negotiate(conn.Subprotocol(), "")
+	}
+	if s.settings.WebsocketCompression {
+		conn.SetCompressionLevel(s.settings.WebsocketCompressionLevel)
+	}
+
+	c := &client{
+		conn:      conn,
+		principal: principal,
+		encoding:  encoding,
+		send:      make(chan payload.Frame, s.settings.WebsocketSendBuffer),
+		requests:  make(chan request, 1),
+		resume:    resume,
+		done:      make(chan struct{}),
+		readDone:  make(chan struct{}),
+	}
+	if !s.register(c) {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(s.settings.WebsocketWriteTimeout))
+		return
+	}
+	defer s.unregister(c)
+
+	s.log.Infof("websocket client %s connected from %s, encoding %s", principal.Name, r.RemoteAddr, encoding)
+	go s.read(c)
+	code, reason := s.write(c)
+	s.log.Infof("websocket client %s disconnected: %d %s", principal.Name, code, reason)
+}
+
+// Close asks every client to go away and waits up to timeout for them to be
+// disconnected. No client can connect afterwards.
+func (s *Server) Close(timeout time.Duration) {
+	s.mu.Lock()
+	s.closing = true
+	for c := range s.clients {
+		c.stop(websocket.CloseGoingAway, "server shutting down")
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		s.log.Errorf("error closing websockets: clients still connected after %v", timeout)
+	}
+}
+
+// Disconnect closes the connections of the principal with the given ID, such
+// as those of a revoked key.
+func (s *Server) Disconnect(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.clients {
+		if c.principal.ID == id {
+			c.stop(websocket.ClosePolicyViolation, "credentials revoked")
+		}
+	}
+}
+
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	clients, seq := len(s.clients), s.seq
+	s.mu.Unlock()
+
+	return Stats{
+		Clients:         clients,
+		Epoch:           s.epoch,
+		Seq:             seq,
+		Sent:            s.sent.Load(),
+		Snapshots:       s.snapshots.Load(),
+		Replays:         s.replays.Load(),
+		RateLimited:     s.rateLimited.Load(),
+		SlowDisconnects: s.slow.Load(),
+	}
+}
+
+func (s *Server) isClosing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closing
+}
+
+func (s *Server) register(c *client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return false
+	}
+	s.clients[c] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) unregister(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.clients, c)
+	s.wg.Done()
+}
+
+// read consumes everything the client sends, so pongs extend the read
+// deadline and a close from the client is noticed right away, and hands the
+// resume and snapshot requests to the writer. A request made while another
+// is pending is dropped, answering the pending one brings the client up to
+// date anyway.
+func (s *Server) read(c *client) {
+	defer close(c.readDone)
+
+	c.conn.SetReadLimit(s.settings.WebsocketMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(s.settings.WebsocketPongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(s.settings.WebsocketPongTimeout))
+	})
+
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				c.stop(closeErr.Code, closeErr.Text)
+			} else {
+				c.stop(websocket.CloseAbnormalClosure, err.Error())
+			}
+			return
+		}
+
+		var req request
+		if err := json.Unmarshal(message, &req); err != nil || (req.Type != requestResume && req.Type != requestSnapshot) {
+			s.log.Debugf("ignoring websocket message from %s: %.100q", c.principal.Name, message)
+			continue
+		}
+		select {
+		case c.requests <- req:
+		default:
+		}
+	}
+}
+
+// write brings the client up to date, then sends the batches, answers its
+// requests and pings it until the client is stopped, and closes the
+// connection with a close handshake. It returns the close code and reason.
+func (s *Server) write(c *client) (int, string) {
+	ticker := time.NewTicker(s.settings.WebsocketPingInterval)
+	defer ticker.Stop()
+
+	if req, ok := s.awaitResume(c); ok {
+		if err := s.catchUp(c, req); err != nil {
+			c.stop(websocket.CloseAbnormalClosure, err.Error())
+		}
+	}
+
+	// retry is armed while something is held back by the rate limit.
+	var retry <-chan time.Time
+	for {
+		var err error
+		select {
+		case frame := <-c.send:
+			if frame.Seq <= c.lastSeq {
+				// Already part of a snapshot or replay.
+				continue
+			}
+			c.lastSeq = frame.Seq
+			c.hold(frame)
+			err = s.deliver(c)
+		case req := <-c.requests:
+			c.request = &req
+			err = s.deliver(c)
+		case <-retry:
+			retry = nil
+			err = s.deliver(c)
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.settings.WebsocketWriteTimeout)); err != nil {
+				c.stop(websocket.CloseAbnormalClosure, err.Error())
+			}
+		case <-c.done:
+			code, reason := c.closeCode, c.closeReason
+			select {
+			case <-c.readDone:
+				// The client closed the connection, the reader already
+				// answered its close frame.
+			default:
+				if code != websocket.CloseAbnormalClosure {
+					c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(s.settings.WebsocketWriteTimeout))
+					select {
+					case <-c.readDone:
+					case <-time.After(closeGrace):
+					}
+				}
+			}
+			return code, reason
+		}
+		if err != nil {
+			c.stop(websocket.CloseAbnormalClosure, err.Error())
+		}
+		if retry == nil && (c.request != nil || c.pending != nil) && c.principal.MessagesPerMinute > 0 {
+			retry = time.After(time.Minute / time.Duration(c.principal.MessagesPerMinute))
+		}
+	}
+}
+
+// deliver sends what the client is waiting for as far as its rate limit
+// allows: its request first, whose answer covers the held back updates, or
+// else the held back updates.
+func (s *Server) deliver(c *client) error {
+	if c.request == nil && c.pending == nil {
+		return nil
+	}
+	if !s.auth.Allow(c.principal) {
+		s.rateLimited.Add(1)
+		return nil
+	}
+	if req := c.request; req != nil {
+		c.request, c.pending = nil, nil
+		return s.catchUp(c, req)
+	}
+	frame := *c.pending
+	c.pending = nil
+	return s.writeFrame(c, frame)
+}
+
+// awaitResume returns the resume request of a new client, waiting up to
+// websocket_resume_wait for one unless it was made in the query string. It
+// reports false when the client was stopped meanwhile.
+func (s *Server) awaitResume(c *client) (*request, bool) {
+	if c.resume != nil {
+		return c.resume, true
+	}
+	timer := time.NewTimer(s.settings.WebsocketResumeWait)
+	defer timer.Stop()
+
+	select {
+	case req := <-c.requests:
+		return &req, true
+	case <-timer.C:
+		return nil, true
+	case <-c.done:
+		return nil, false
+	}
+}
+
+// catchUp answers a resume with a replay of the batches the client missed,
+// and anything else, or a resume from too long ago or another epoch, with a
+// snapshot. Both are taken under the lock the batches are queued under, so
+// they cover every batch up to the current one and the queued ones after it.
+func (s *Server) catchUp(c *client, req *request) error {
+	s.mu.Lock()
+	frame := payload.Frame{Type: payload.TypeSnapshot, Epoch: s.epoch, Seq: s.seq}
+	if req != nil && req.Type == requestResume && s.canReplay(req) {
+		frame.Type = payload.TypeReplay
+		frame.Events = make(map[string]helper.ProcessedData)
+		for _, batch := range s.history {
+			if batch.Seq <= req.Seq {
+				continue
+			}
+			for key, data := range batch.Events {
+				frame.Events[key] = data
+			}
+		}
+	} else {
+		snapshot := s.hub.Snapshot()
+		frame.Events = make(map[string]helper.ProcessedData, len(snapshot))
+		for _, data := range snapshot {
+			frame.Events[data.StorageKey()] = data
+		}
+	}
+	s.mu.Unlock()
+
+	if frame.Type == payload.TypeReplay {
+		s.replays.Add(1)
+	} else {
+		s.snapshots.Add(1)
+	}
+	c.lastSeq = frame.Seq
+	return s.writeFrame(c, frame)
+}
+
+// canReplay reports whether every batch after the resume point is still in
+// the history. s.mu must be held.
+func (s *Server) canReplay(req *request) bool {
+	if req.Epoch != s.epoch || req.Seq > s.seq {
+		return false
+	}
+	if req.Seq == s.seq {
+		return true
+	}
+	return len(s.history) > 0 && s.history[0].Seq <= req.Seq+1
+}
+
+// writeFrame sends the events of the frame the client is entitled to.
+func (s *Server) writeFrame(c *client, frame payload.Frame) error {
+	if c.principal.Restricted() {
+		frame.Events = entitled(frame.Events, c.principal)
+	}
+
+	data, err := payload.Encode(c.encoding, frame)
+	if err != nil {
+		s.log.Errorf("error encoding %s frame: %v", c.encoding, err)
+		return nil
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(s.settings.WebsocketWriteTimeout))
+	if err := c.conn.WriteMessage(payload.MessageType(c.encoding), data); err != nil {
+		return err
+	}
+	s.sent.Add(1)
+	return nil
+}
+
+// entitled returns the events the principal may receive.
+func entitled(events map[string]helper.ProcessedData, principal auth.Principal) map[string]helper.ProcessedData {
+	allowed := make(map[string]helper.ProcessedData, len(events))
+	for key, data := range events {
+		if principal.Allows(data.Sport, data.League) {
+			allowed[key] = data
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,586 @@
+package wsapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"test_task_app/auth"
+	"test_task_app/config"
+	"test_task_app/feed"
+	"test_task_app/helper"
+	"test_task_app/payload"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+func testSettings() config.Websocket {
+	return config.Websocket{
+		WebsocketSendBuffer:     8,
+		WebsocketWriteTimeout:   time.Second,
+		WebsocketPingInterval:   100 * time.Millisecond,
+		WebsocketPongTimeout:    time.Second,
+		WebsocketMaxMessageSize: 4096,
+		WebsocketReplayBuffer:   3,
+		WebsocketResumeWait:     50 * time.Millisecond,
+	}
+}
+
+// testServer runs a Server behind an httptest.Server, fed through source.
+type testServer struct {
+	*Server
+	url    string
+	source chan map[string]interface{}
+}
+
+func newTestServer(t *testing.T, settings config.Websocket, authenticator *auth.Authenticator) *testServer {
+	t.Helper()
+
+	if authenticator == nil {
+		authenticator = auth.New(config.Auth{}, nil)
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := &testServer{
+		Server: New(settings, authenticator, feed.NewHub(), log),
+		source: make(chan map[string]interface{}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Run(ctx, s.source)
+	ts := httptest.NewServer(s)
+	s.url = "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+	t.Cleanup(func() {
+		cancel()
+		s.Close(time.Second)
+		ts.Close()
+	})
+	return s
+}
+
+// newTestAuth returns an authenticator with a key store and the admin API
+// enabled.
+func newTestAuth(t *testing.T) (*auth.Authenticator, *auth.KeyStore) {
+	t.Helper()
+
+	keys, err := auth.NewKeyStore(filepath.Join(t.TempDir(), auth.KeysFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Auth{AuthEnabled: true, AuthAdminToken: testAdminToken, AuthMaxConnections: 5, AuthMessagesPerMinute: 1000}
+	return auth.New(cfg, keys), keys
+}
+
+const testAdminToken = "admin-token-0123456789"
+
+func event(id int, sport, league string) helper.ProcessedData {
+	return helper.ProcessedData{Provider: "unibet", Operator: "ubbe", EventID: id, Sport: sport, League: league}
+}
+
+// send publishes the events as one batch and waits for Run to number it.
+func (s *testServer) send(t *testing.T, events ...helper.ProcessedData) uint64 {
+	t.Helper()
+
+	seq := s.Stats().Seq
+	batch := make(map[string]interface{}, len(events))
+	for _, data := range events {
+		s.hub.Publish(data)
+		batch[data.StorageKey()] = data
+	}
+	s.source <- batch
+	waitFor(t, "batch to be numbered", func() bool { return s.Stats().Seq > seq })
+	return seq + 1
+}
+
+// dial connects to the server with the query and headers, and waits for the
+// client to be registered.
+func (s *testServer) dial(t *testing.T, query string, header http.Header) *websocket.Conn {
+	t.Helper()
+
+	clients := s.Stats().Clients
+	target := s.url
+	if query != "" {
+		target += "?" + query
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(target, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	waitFor(t, "client to connect", func() bool { return s.Stats().Clients > clients })
+	return conn
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) payload.Frame {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frame payload.Frame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+// readClose reads until the connection ends and returns the error it ended
+// with, a timeout when the server kept it open.
+func readClose(conn *websocket.Conn) error {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return err
+		}
+	}
+}
+
+// keys returns the sorted keys of the events of a frame.
+func keys(frame payload.Frame) []string {
+	keys := make([]string, 0, len(frame.Events))
+	for key := range frame.Events {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func keysOf(events ...helper.ProcessedData) []string {
+	keys := make([]string, 0, len(events))
+	for _, data := range events {
+		keys = append(keys, data.StorageKey())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(3 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReadDeadline(t *testing.T) {
+	settings := testSettings()
+	settings.WebsocketPingInterval = 20 * time.Millisecond
+	settings.WebsocketPongTimeout = 150 * time.Millisecond
+	s := newTestServer(t, settings, nil)
+
+	// Reading answers the pings with pongs, which extend the deadline.
+	answering := s.dial(t, "", nil)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			if _, _, err := answering.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	silent := s.dial(t, "", nil)
+
+	waitFor(t, "silent client to be dropped", func() bool { return s.Stats().Clients == 1 })
+	var netErr net.Error
+	if err := readClose(silent); errors.As(err, &netErr) && netErr.Timeout() {
+		t.Error("silent client still connected")
+	}
+
+	time.Sleep(3 * settings.WebsocketPongTimeout)
+	select {
+	case <-stopped:
+		t.Error("client answering pings was dropped")
+	default:
+	}
+	if clients := s.Stats().Clients; clients != 1 {
+		t.Errorf("%d clients connected, want 1", clients)
+	}
+}
+
+func TestSlowClientIsClosed(t *testing.T) {
+	settings := testSettings()
+	settings.WebsocketSendBuffer = 1
+	settings.WebsocketWriteTimeout = 5 * time.Second
+	settings.WebsocketPingInterval = time.Minute
+	s := newTestServer(t, settings, nil)
+	conn := s.dial(t, "", nil)
+	readFrame(t, conn)
+
+	// Frames the client does not read fill the socket buffers, then its
+	// queue.
+	big := event(1, "FOOTBALL", "Premier League")
+	big.MatchName = strings.Repeat("x", 1<<20)
+	for i := 0; i < 200 && s.Stats().SlowDisconnects == 0; i++ {
+		s.source <- map[string]interface{}{big.StorageKey(): big}
+	}
+	waitFor(t, "slow client to be stopped", func() bool { return s.Stats().SlowDisconnects == 1 })
+
+	if err := readClose(conn); !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+		t.Errorf("slow client closed with %v, want %d", err, websocket.CloseTryAgainLater)
+	}
+	waitFor(t, "slow client to be unregistered", func() bool { return s.Stats().Clients == 0 })
+}
+
+func TestCloseGoesAway(t *testing.T) {
+	s := newTestServer(t, testSettings(), nil)
+	conn := s.dial(t, "", nil)
+	readFrame(t, conn)
+	closed := make(chan error, 1)
+	go func() { closed <- readClose(conn) }()
+
+	// The client answers the close frame, so Close need not wait out the
+	// grace period.
+	start := time.Now()
+	s.Close(time.Second)
+	if elapsed := time.Since(start); elapsed >= closeGrace {
+		t.Errorf("Close() took %v", elapsed)
+	}
+	if err := <-closed; !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("client closed with %v, want %d", err, websocket.CloseGoingAway)
+	}
+	if clients := s.Stats().Clients; clients != 0 {
+		t.Errorf("%d clients connected after Close()", clients)
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(s.url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("connect after Close(): %v", err)
+	}
+}
+
+func TestRevokedKeyIsDisconnected(t *testing.T) {
+	authenticator, keys := newTestAuth(t)
+	s := newTestServer(t, testSettings(), authenticator)
+	authenticator.OnRevoke(s.Disconnect)
+
+	revokedKey, revokedToken, err := keys.Issue("revoked", auth.Entitlements{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, token, err := keys.Issue("kept", auth.Entitlements{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := s.dial(t, "", http.Header{"X-Api-Key": {revokedToken}})
+	kept := s.dial(t, "", http.Header{"X-Api-Key": {token}})
+	readFrame(t, revoked)
+	readFrame(t, kept)
+
+	r := httptest.NewRequest(http.MethodDelete, "/admin/keys/"+revokedKey.ID, nil)
+	r.Header.Set("Authorization", "Bearer "+testAdminToken)
+	w := httptest.NewRecorder()
+	authenticator.AdminHandler().ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("revoke: %d %s", w.Code, w.Body)
+	}
+
+	if err := readClose(revoked); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("revoked client closed with %v, want %d", err, websocket.ClosePolicyViolation)
+	}
+	seq := s.send(t, event(1, "FOOTBALL", "Premier League"))
+	if frame := readFrame(t, kept); frame.Type != payload.TypeUpdate || frame.Seq != seq {
+		t.Errorf("other client got %s %d, want update %d", frame.Type, frame.Seq, seq)
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(s.url, http.Header{"X-Api-Key": {revokedToken}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("connect with a revoked key: %v", err)
+	}
+}
+
+func TestRunNumbersBatches(t *testing.T) {
+	for _, buffer := range []int{0, 3} {
+		settings := testSettings()
+		settings.WebsocketReplayBuffer = buffer
+		s := New(settings, auth.New(config.Auth{}, nil), feed.NewHub(), logrus.New())
+
+		source := make(chan map[string]interface{}, 10)
+		for i := 1; i <= 5; i++ {
+			data := event(i, "FOOTBALL", "Premier League")
+			source <- map[string]interface{}{data.StorageKey(): data}
+			if i == 2 {
+				// Batches without events are not numbered.
+				source <- map[string]interface{}{}
+				source <- map[string]interface{}{"status": "ok"}
+			}
+		}
+		close(source)
+		s.Run(context.Background(), source)
+
+		if s.seq != 5 {
+			t.Errorf("buffer %d: seq %d, want 5", buffer, s.seq)
+		}
+		var seqs []uint64
+		for _, frame := range s.history {
+			seqs = append(seqs, frame.Seq)
+			want := event(int(frame.Seq), "FOOTBALL", "Premier League")
+			if frame.Type != payload.TypeUpdate || frame.Epoch != s.epoch || !reflect.DeepEqual(keys(frame), keysOf(want)) {
+				t.Errorf("buffer %d: batch %d is %+v", buffer, frame.Seq, frame)
+			}
+		}
+		// The oldest batches are dropped once the buffer is full.
+		if want := map[int][]uint64{0: nil, 3: {3, 4, 5}}[buffer]; !reflect.DeepEqual(seqs, want) {
+			t.Errorf("buffer %d: history %v, want %v", buffer, seqs, want)
+		}
+	}
+}
+
+func TestCanReplay(t *testing.T) {
+	s := &Server{epoch: "current", seq: 10}
+	for seq := uint64(8); seq <= 10; seq++ {
+		s.history = append(s.history, payload.Frame{Type: payload.TypeUpdate, Epoch: s.epoch, Seq: seq})
+	}
+
+	tests := []struct {
+		name    string
+		epoch   string
+		seq     uint64
+		history bool
+		want    bool
+	}{
+		{"up to date", "current", 10, true, true},
+		{"last batch missed", "current", 9, true, true},
+		{"every buffered batch missed", "current", 7, true, true},
+		{"batches missed before the buffer", "current", 6, true, false},
+		{"future seq", "current", 11, true, false},
+		{"other epoch", "previous", 9, true, false},
+		{"no buffer", "current", 9, false, false},
+		{"no buffer, up to date", "current", 10, false, true},
+	}
+	for _, tt := range tests {
+		history := s.history
+		if !tt.history {
+			s.history = nil
+		}
+		if got := s.canReplay(&request{Type: requestResume, Epoch: tt.epoch, Seq: tt.seq}); got != tt.want {
+			t.Errorf("%s: canReplay() = %v, want %v", tt.name, got, tt.want)
+		}
+		s.history = history
+	}
+}
+
+func TestSnapshotOnConnect(t *testing.T) {
+	settings := testSettings()
+	settings.WebsocketResumeWait = 300 * time.Millisecond
+	s := newTestServer(t, settings, nil)
+	first, second, third := event(1, "FOOTBALL", "Premier League"), event(2, "FOOTBALL", "La Liga"), event(3, "TENNIS", "ATP Vienna")
+	s.send(t, first)
+
+	// Batches queued while the client may still resume are part of the
+	// snapshot, and not sent again.
+	conn := s.dial(t, "", nil)
+	s.send(t, second)
+	seq := s.send(t, third)
+
+	frame := readFrame(t, conn)
+	if frame.Type != payload.TypeSnapshot || frame.Epoch != s.epoch || frame.Seq != seq || !reflect.DeepEqual(keys(frame), keysOf(first, second, third)) {
+		t.Errorf("got %s %d %v, want snapshot %d of 3 events", frame.Type, frame.Seq, keys(frame), seq)
+	}
+	updated := event(1, "FOOTBALL", "Premier League")
+	updated.Time = 1
+	seq = s.send(t, updated)
+	if frame := readFrame(t, conn); frame.Type != payload.TypeUpdate || frame.Seq != seq || frame.Events[updated.StorageKey()].Time != 1 || len(frame.Events) != 1 {
+		t.Errorf("got %s %d %v, want update %d", frame.Type, frame.Seq, keys(frame), seq)
+	}
+	if stats := s.Stats(); stats.Snapshots != 1 || stats.Replays != 0 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestResume(t *testing.T) {
+	s := newTestServer(t, testSettings(), nil)
+	var events []helper.ProcessedData
+	for i := 1; i <= 5; i++ {
+		events = append(events, event(i, "FOOTBALL", "Premier League"))
+	}
+	for i, data := range events {
+		if i == 3 {
+			// The fourth batch updates the first event again.
+			s.send(t, data, events[0])
+			continue
+		}
+		s.send(t, data)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		kind   string
+		events []helper.ProcessedData
+	}{
+		{"replay", "resume=3&epoch=" + s.epoch, payload.TypeReplay, []helper.ProcessedData{events[0], events[3], events[4]}},
+		{"replay of every buffered batch", "resume=2&epoch=" + s.epoch, payload.TypeReplay, []helper.ProcessedData{events[0], events[2], events[3], events[4]}},
+		{"up to date", "resume=5&epoch=" + s.epoch, payload.TypeReplay, nil},
+		{"resume before the buffer", "resume=1&epoch=" + s.epoch, payload.TypeSnapshot, events},
+		{"other epoch", "resume=4&epoch=previous", payload.TypeSnapshot, events},
+		{"future seq", "resume=6&epoch=" + s.epoch, payload.TypeSnapshot, events},
+	}
+	for _, tt := range tests {
+		conn := s.dial(t, tt.query, nil)
+		frame := readFrame(t, conn)
+		if frame.Type != tt.kind || frame.Seq != 5 || !reflect.DeepEqual(keys(frame), keysOf(tt.events...)) {
+			t.Errorf("%s: got %s %d %v, want %s 5 %v", tt.name, frame.Type, frame.Seq, keys(frame), tt.kind, keysOf(tt.events...))
+		}
+		conn.Close()
+		waitFor(t, "client to disconnect", func() bool { return s.Stats().Clients == 0 })
+	}
+	if stats := s.Stats(); stats.Replays != 3 || stats.Snapshots != 3 {
+		t.Errorf("stats %+v", stats)
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(s.url+"?resume=latest", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("connect with an invalid resume: %v", err)
+	}
+}
+
+func TestResumeMessage(t *testing.T) {
+	settings := testSettings()
+	settings.WebsocketResumeWait = time.Second
+	s := newTestServer(t, settings, nil)
+	first, second, third := event(1, "FOOTBALL", "Premier League"), event(2, "FOOTBALL", "La Liga"), event(3, "TENNIS", "ATP Vienna")
+	s.send(t, first)
+	s.send(t, second)
+
+	conn := s.dial(t, "", nil)
+	ask := func(req request) payload.Frame {
+		t.Helper()
+		if err := conn.WriteJSON(req); err != nil {
+			t.Fatal(err)
+		}
+		return readFrame(t, conn)
+	}
+
+	if frame := ask(request{Type: requestResume, Epoch: s.epoch, Seq: 1}); frame.Type != payload.TypeReplay || frame.Seq != 2 || !reflect.DeepEqual(keys(frame), keysOf(second)) {
+		t.Errorf("resume: got %s %d %v", frame.Type, frame.Seq, keys(frame))
+	}
+	seq := s.send(t, third)
+	if frame := readFrame(t, conn); frame.Type != payload.TypeUpdate || frame.Seq != seq {
+		t.Errorf("got %s %d, want update %d", frame.Type, frame.Seq, seq)
+	}
+
+	// A client may resume again, after a gap, or ask for a snapshot at any
+	// time. Anything else is ignored.
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"subscribe"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if frame := ask(request{Type: requestResume, Epoch: s.epoch, Seq: 1}); frame.Type != payload.TypeReplay || frame.Seq != seq || !reflect.DeepEqual(keys(frame), keysOf(second, third)) {
+		t.Errorf("second resume: got %s %d %v", frame.Type, frame.Seq, keys(frame))
+	}
+	if frame := ask(request{Type: requestSnapshot}); frame.Type != payload.TypeSnapshot || frame.Seq != seq || !reflect.DeepEqual(keys(frame), keysOf(first, second, third)) {
+		t.Errorf("snapshot: got %s %d %v", frame.Type, frame.Seq, keys(frame))
+	}
+}
+
+func TestRateLimitedUpdatesAreMerged(t *testing.T) {
+	authenticator, store := newTestAuth(t)
+	s := newTestServer(t, testSettings(), authenticator)
+	_, token, err := store.Issue("limited", auth.Entitlements{MessagesPerMinute: 60}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := s.dial(t, "", http.Header{"X-Api-Key": {token}})
+	frames := make(chan payload.Frame, 100)
+	go func() {
+		defer close(frames)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var frame payload.Frame
+			if json.Unmarshal(data, &frame) == nil {
+				frames <- frame
+			}
+		}
+	}()
+	<-frames
+
+	// Updates go out one by one until the client runs out of messages.
+	var held helper.ProcessedData
+	for i := 1; held.EventID == 0; i++ {
+		if i > 100 {
+			t.Fatal("updates were never rate limited")
+		}
+		data := event(i, "FOOTBALL", "Premier League")
+		seq := s.send(t, data)
+		for sent := false; !sent && held.EventID == 0; {
+			select {
+			case frame := <-frames:
+				if frame.Type != payload.TypeUpdate || frame.Seq != seq {
+					t.Fatalf("got %s %d, want update %d", frame.Type, frame.Seq, seq)
+				}
+				sent = true
+			default:
+				if s.Stats().RateLimited > 0 {
+					held = data
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+
+	// The updates held back meanwhile follow as one replay.
+	second, third := event(1001, "TENNIS", "ATP Vienna"), event(1002, "TENNIS", "ATP Vienna")
+	s.send(t, second)
+	seq := s.send(t, third)
+	select {
+	case frame := <-frames:
+		if frame.Type != payload.TypeReplay || frame.Seq != seq || !reflect.DeepEqual(keys(frame), keysOf(held, second, third)) {
+			t.Errorf("got %s %d %v, want replay %d of the held updates", frame.Type, frame.Seq, keys(frame), seq)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("held updates were never sent")
+	}
+}
+
+func TestEntitlements(t *testing.T) {
+	authenticator, store := newTestAuth(t)
+	s := newTestServer(t, testSettings(), authenticator)
+	_, token, err := store.Issue("tennis", auth.Entitlements{Sports: []string{"TENNIS"}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	football, tennis := event(1, "FOOTBALL", "Premier League"), event(2, "TENNIS", "ATP Vienna")
+	s.send(t, football, tennis)
+
+	conn := s.dial(t, "api_key="+token, nil)
+	if frame := readFrame(t, conn); frame.Type != payload.TypeSnapshot || !reflect.DeepEqual(keys(frame), keysOf(tennis)) {
+		t.Errorf("snapshot: got %s %v", frame.Type, keys(frame))
+	}
+
+	nextTennis := event(3, "TENNIS", "WTA Linz")
+	seq := s.send(t, event(4, "FOOTBALL", "La Liga"), nextTennis)
+	if frame := readFrame(t, conn); frame.Seq != seq || !reflect.DeepEqual(keys(frame), keysOf(nextTennis)) {
+		t.Errorf("update: got %d %v", frame.Seq, keys(frame))
+	}
+	// Batches without any entitled event are still sent, so the client sees
+	// no gap in the sequence.
+	seq = s.send(t, football)
+	if frame := readFrame(t, conn); frame.Type != payload.TypeUpdate || frame.Seq != seq || len(frame.Events) != 0 {
+		t.Errorf("update: got %s %d %v", frame.Type, frame.Seq, keys(frame))
+	}
+
+	if err := conn.WriteJSON(request{Type: requestResume, Epoch: s.epoch, Seq: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if frame := readFrame(t, conn); frame.Type != payload.TypeReplay || !reflect.DeepEqual(keys(frame), keysOf(nextTennis)) {
+		t.Errorf("replay: got %s %v", frame.Type, keys(frame))
+	}
+}
@@ -4,6 +4,7 @@ import (
     "context"
     "encoding/json"
     "log"
+    "net/http"
     "os"
     "os/signal"
     "time"
@@ -16,14 +17,74 @@ const (
     reconnectDelay = 5 * time.Second
 )
 
+// frame is a message from the server: a snapshot of every event, a replay of
+// the events updated while disconnected, or an update. Events are keyed by
+// storage key.
+type frame struct {
+    Type   string                     `json:"type"`
+    Epoch  string                     `json:"epoch"`
+    Seq    uint64                     `json:"seq"`
+    Events map[string]json.RawMessage `json:"events"`
+}
+
+// feedState is the latest version of every event and the last batch seen, it
+// outlives connections so a reconnect only fetches what was missed.
+type feedState struct {
+    epoch  string
+    seq    uint64
+    events map[string]json.RawMessage
+}
+
+// resume asks the server for the batches after the last one seen, or for a
+// snapshot when nothing was seen yet.
+func (s *feedState) resume(conn *websocket.Conn) error {
+    if s.epoch == "" {
+        return conn.WriteJSON(map[string]interface{}{"type": "snapshot"})
+    }
+    return conn.WriteJSON(map[string]interface{}{"type": "resume", "epoch": s.epoch, "seq": s.seq})
+}
+
+// apply merges a frame into the state. It reports false when batches were
+// missed, the state is then kept until a resume fills the gap.
+func (s *feedState) apply(f frame) bool {
+    switch f.Type {
+    case "snapshot":
+        s.events = f.Events
+    case "replay":
+        if f.Epoch != s.epoch {
+            return false
+        }
+        for key, event := range f.Events {
+            s.events[key] = event
+        }
+    case "update":
+        if f.Epoch != s.epoch || f.Seq != s.seq+1 {
+            return false
+        }
+        for key, event := range f.Events {
+            s.events[key] = event
+        }
+    default:
+        return true
+    }
+    s.epoch, s.seq = f.Epoch, f.Seq
+    return true
+}
+
 func connectToServer(ctx context.Context) {
+    state := &feedState{events: make(map[string]json.RawMessage)}
+    header := http.Header{}
+    if apiKey := os.Getenv("API_KEY"); apiKey != "" {
+        header.Set("X-API-Key", apiKey)
+    }
+
     for {
         select {
         case <-ctx.Done():
             return
         default:
             log.Println("Attempting to connect to the server...")
-            conn, _, err := websocket.DefaultDialer.Dial(websocketURI, nil)
+            conn, _, err := websocket.DefaultDialer.Dial(websocketURI, header)
             if err != nil {
                 log.Printf("Error connecting to the server: %v", err)
                 time.Sleep(reconnectDelay)
@@ -31,6 +92,11 @@ func connectToServer(ctx context.Context) {
             }
             log.Println("Connected to the server")
 
+            if err := state.resume(conn); err != nil {
+                log.Printf("Error resuming from %d: %v", state.seq, err)
+            }
+
+            resuming := false
             for {
                 _, message, err := conn.ReadMessage()
                 if err != nil {
@@ -38,16 +104,30 @@ func connectToServer(ctx context.Context) {
                     break
                 }
 
-                var data map[string]interface{}
-                if err := json.Unmarshal(message, &data); err != nil {
+                var f frame
+                if err := json.Unmarshal(message, &f); err != nil {
                     log.Printf("Error parsing message: %v", err)
                     continue
                 }
 
-                log.Printf("Received data for %d matches", len(data))
+                if !state.apply(f) {
+                    // Batches were missed, ask once for what is missing
+                    // and drop the updates until the answer arrives.
+                    if !resuming {
+                        log.Printf("Missed updates after %d, resuming", state.seq)
+                        if err := state.resume(conn); err != nil {
+                            log.Printf("Error resuming from %d: %v", state.seq, err)
+                        }
+                        resuming = true
+                    }
+                    continue
+                }
+                if f.Type != "update" {
+                    resuming = false
+                }
+
+                log.Printf("Received %s %d for %d matches, tracking %d", f.Type, f.Seq, len(f.Events), len(state.events))
                 // Process the received data here
-                // For example, print the data:
-                log.Println(data)
             }
 
             conn.Close()
@@ -70,4 +150,4 @@ func main() {
     }()
 
     connectToServer(ctx)
-}
\ No newline at end of file
+}
@@ -0,0 +1,5 @@
+module test_task_view
+
+go 1.22.1
+
+require github.com/klauspost/compress v1.18.0
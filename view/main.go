@@ -1,74 +1,188 @@
 package main
 
 import (
+    "compress/gzip"
     "encoding/json"
     "fmt"
     "html/template"
+    "io"
     "io/ioutil"
     "log"
     "net/http"
     "os"
     "path/filepath"
+    "strconv"
     "strings"
     "time"
+
+    "github.com/klauspost/compress/zstd"
 )
 
+type Market struct {
+    Kind        string   `json:"kind"`
+    Stat        string   `json:"stat"`
+    Period      string   `json:"period"`
+    Scope       string   `json:"scope"`
+    Selection   string   `json:"selection"`
+    Line        *float64 `json:"line"`
+    Participant string   `json:"participant"`
+}
+
 type Outcome struct {
     TypeName string  `json:"type_name"`
     Type     string  `json:"type"`
-    Line     *string `json:"line"`
+    Market   *Market `json:"market"`
     Odds     float64 `json:"odds"`
+    Status   string  `json:"status"`
 }
 
 type OddsData struct {
+    MatchName     string    `json:"match_name"`
     HomeTeam      string    `json:"home_team"`
     AwayTeam      string    `json:"away_team"`
     Time          int64     `json:"time"`
-    EventID       string    `json:"event_id"`
+    EventID       int       `json:"event_id"`
     League        string    `json:"league"`
     Sport         string    `json:"sport"`
     CurrentMinute int       `json:"current_minute"`
     Outcomes      []Outcome `json:"outcomes"`
+    Players       []Player  `json:"players"`
+}
+
+type Player struct {
+    ID   string `json:"id"`
+    Name string `json:"name"`
 }
 
 type FormattedData struct {
     MatchName     string                 `json:"match_name"`
     Time          string                 `json:"time"`
-    EventID       string                 `json:"event_id"`
+    EventID       int                    `json:"event_id"`
     League        string                 `json:"league"`
     Sport         string                 `json:"sport"`
     CurrentMinute int                    `json:"current_minute"`
     FormattedData map[string]map[string][]string `json:"formatted_data"`
+    Alerts        []string               `json:"alerts"`
+}
+
+type Alert struct {
+    Type          string   `json:"type"`
+    Key           string   `json:"key"`
+    MatchName     string   `json:"match_name"`
+    Sport         string   `json:"sport"`
+    Operator      string   `json:"operator"`
+    Market        string   `json:"market"`
+    Codes         []string `json:"codes"`
+    From          float64  `json:"from"`
+    To            float64  `json:"to"`
+    ChangePercent float64  `json:"change_percent"`
+    Moves         []Move   `json:"moves"`
+    Time          int64    `json:"time"`
+}
+
+// Move is one line of a steam alert.
+type Move struct {
+    Code          string  `json:"code"`
+    From          float64 `json:"from"`
+    To            float64 `json:"to"`
+    ChangePercent float64 `json:"change_percent"`
+}
+
+// loadAlerts reads the latest alerts written by the parser, newest first.
+func loadAlerts() []Alert {
+    data, err := ioutil.ReadFile(filepath.Join("/odds_data", "alerts.json"))
+    if err != nil {
+        return nil
+    }
+
+    var alerts []Alert
+    if err := json.Unmarshal(data, &alerts); err != nil {
+        log.Printf("Invalid alerts: %v", err)
+        return nil
+    }
+    return alerts
+}
+
+func formatAlert(alert Alert) string {
+    at := time.Unix(alert.Time, 0).Format("15:04:05")
+    if len(alert.Moves) > 0 {
+        moves := make([]string, len(alert.Moves))
+        for i, move := range alert.Moves {
+            moves[i] = fmt.Sprintf("%s: %.2f -> %.2f", move.Code, move.From, move.To)
+        }
+        return fmt.Sprintf("%s %s %s (%+.2f%%) %s", at, alert.Type, strings.Join(moves, ", "), alert.ChangePercent, alert.Operator)
+    }
+    return fmt.Sprintf("%s %s %s: %.2f -> %.2f (%+.2f%%) %s", at,
+        alert.Type, strings.Join(alert.Codes, ", "), alert.From, alert.To, alert.ChangePercent, alert.Operator)
+}
+
+// periodName turns a canonical period code into a heading.
+func periodName(period string) string {
+    switch {
+    case period == "FT":
+        return "Match"
+    case period == "H1":
+        return "1H"
+    case period == "H2":
+        return "2H"
+    case strings.HasSuffix(period, "TB"):
+        return "Set " + strings.TrimSuffix(strings.TrimPrefix(period, "S"), "TB") + " Tie-break"
+    case strings.HasPrefix(period, "S") && strings.Contains(period, "G"):
+        set, game, _ := strings.Cut(strings.TrimPrefix(period, "S"), "G")
+        return "Set " + set + " Game " + game
+    case strings.HasPrefix(period, "S"):
+        return "Set " + strings.TrimPrefix(period, "S")
+    case strings.HasPrefix(period, "G"):
+        return "Group " + strings.TrimPrefix(period, "G")
+    }
+    return period
 }
 
 func formatOddsData(data OddsData) (FormattedData, error) {
+    matchName := fmt.Sprintf("%s vs %s", data.HomeTeam, data.AwayTeam)
+    if data.HomeTeam == "" && data.AwayTeam == "" {
+        // Outrights belong to a competition, not to a match.
+        matchName = data.MatchName
+    }
+
     formattedData := FormattedData{
-        MatchName:     fmt.Sprintf("%s vs %s", data.HomeTeam, data.AwayTeam),
+        MatchName:     matchName,
         Time:          time.Unix(data.Time, 0).Format("2006-01-02 15:04:05"),
         EventID:       data.EventID,
         League:        data.League,
         Sport:         data.Sport,
         CurrentMinute: data.CurrentMinute,
-        FormattedData: map[string]map[string][]string{
-            "Match": {},
-            "1H":    {},
-            "2H":    {},
-        },
+        FormattedData: map[string]map[string][]string{},
+    }
+
+    players := make(map[string]string, len(data.Players))
+    for _, player := range data.Players {
+        players[player.ID] = player.Name
     }
 
     for _, outcome := range data.Outcomes {
-        period := "Match"
-        if strings.HasPrefix(outcome.TypeName, "1H") {
-            period = "1H"
-        } else if strings.HasPrefix(outcome.TypeName, "2H") {
-            period = "2H"
+        // Closed outcomes are no longer offered.
+        if outcome.Market == nil || outcome.Status == "CLOSED" {
+            continue
+        }
+        period := periodName(outcome.Market.Period)
+        scope := outcome.Market.Scope
+        if outcome.Market.Participant != "" {
+            scope = players[outcome.Market.Participant]
+            if scope == "" {
+                scope = outcome.Market.Participant
+            }
+        }
+        betType := fmt.Sprintf("%s %s (%s)", outcome.Market.Kind, outcome.Market.Stat, scope)
+        formattedOutcome := fmt.Sprintf("%s: %s @ %.2f", outcome.Market.Selection, getLine(outcome.Market.Line), outcome.Odds)
+        if outcome.Status != "" && outcome.Status != "OPEN" {
+            // The last price of a suspended outcome cannot be bet on.
+            formattedOutcome = fmt.Sprintf("%s: %s %s (last %.2f)", outcome.Market.Selection, getLine(outcome.Market.Line),
+                strings.ToUpper(outcome.Status), outcome.Odds)
         }
 
-        betType := strings.TrimPrefix(strings.TrimPrefix(outcome.TypeName, "1H"), "2H")
-        formattedOutcome := fmt.Sprintf("%s: %s @ %.2f", outcome.Type, getLine(outcome.Line), outcome.Odds)
-
-        if _, exists := formattedData.FormattedData[period][betType]; !exists {
-            formattedData.FormattedData[period][betType] = []string{}
+        if _, exists := formattedData.FormattedData[period]; !exists {
+            formattedData.FormattedData[period] = map[string][]string{}
         }
         formattedData.FormattedData[period][betType] = append(formattedData.FormattedData[period][betType], formattedOutcome)
     }
@@ -76,11 +190,101 @@ func formatOddsData(data OddsData) (FormattedData, error) {
     return formattedData, nil
 }
 
-func getLine(line *string) string {
+func getLine(line *float64) string {
     if line == nil {
         return "N/A"
     }
-    return *line
+    return strconv.FormatFloat(*line, 'f', -1, 64)
+}
+
+type CatalogEntry struct {
+    Key       string `json:"key"`
+    Operator  string `json:"operator"`
+    EventID   int    `json:"event_id"`
+    MatchName string `json:"match_name"`
+    Sport     string `json:"sport"`
+    League    string `json:"league"`
+    StartTime int64  `json:"start_time"`
+}
+
+type FileLink struct {
+    FileName string
+    Label    string
+}
+
+// loadCatalog reads the parser's event catalog, keyed by storage key.
+func loadCatalog() map[string]CatalogEntry {
+    catalog := make(map[string]CatalogEntry)
+
+    data, err := ioutil.ReadFile(filepath.Join("/odds_data", "catalog.json"))
+    if err != nil {
+        return catalog
+    }
+
+    var entries []CatalogEntry
+    if err := json.Unmarshal(data, &entries); err != nil {
+        log.Printf("Invalid catalog: %v", err)
+        return catalog
+    }
+    for _, entry := range entries {
+        catalog[entry.Key] = entry
+    }
+    return catalog
+}
+
+// splitDataFile returns the storage key of an odds file, and for segments
+// rotated by retention, <key>.jsonl.<time> optionally compressed with gzip or
+// zstd, the rotation time.
+func splitDataFile(name string) (key, segment string, ok bool) {
+    key, rest, found := strings.Cut(name, ".jsonl")
+    if !found {
+        return "", "", false
+    }
+    if rest == "" {
+        return key, "", true
+    }
+    rest = strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(rest, "."), ".gz"), ".zst")
+    rotated, err := time.Parse("20060102T150405", rest)
+    if err != nil {
+        return "", "", false
+    }
+    return key, rotated.Format("2006-01-02 15:04"), true
+}
+
+// openDataFile opens an odds file, decompressing rotated segments.
+func openDataFile(path string) (io.ReadCloser, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    switch filepath.Ext(path) {
+    case ".gz":
+        r, err := gzip.NewReader(file)
+        if err != nil {
+            file.Close()
+            return nil, err
+        }
+        return readCloser{r, file}, nil
+    case ".zst":
+        r, err := zstd.NewReader(file)
+        if err != nil {
+            file.Close()
+            return nil, err
+        }
+        return readCloser{r.IOReadCloser(), file}, nil
+    }
+    return file, nil
+}
+
+// readCloser closes a decompressor and the file under it.
+type readCloser struct {
+    io.ReadCloser
+    file *os.File
+}
+
+func (r readCloser) Close() error {
+    r.ReadCloser.Close()
+    return r.file.Close()
 }
 
 func homeHandler(w http.ResponseWriter, r *http.Request) {
@@ -90,11 +294,26 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    var fileList []string
+    catalog := loadCatalog()
+
+    var fileList []FileLink
     for _, file := range files {
-        if filepath.Ext(file.Name()) == ".jsonl" {
-            fileList = append(fileList, file.Name())
+        key, segment, ok := splitDataFile(file.Name())
+        if !ok {
+            continue
         }
+        link := FileLink{FileName: file.Name(), Label: file.Name()}
+        if entry, ok := catalog[key]; ok {
+            link.Label = fmt.Sprintf("%s (%s, %s, %s) #%d", entry.MatchName, entry.Sport, entry.League,
+                time.Unix(entry.StartTime, 0).Format("2006-01-02 15:04"), entry.EventID)
+            if entry.Operator != "" {
+                link.Label += " @" + entry.Operator
+            }
+            if segment != "" {
+                link.Label += " [archived " + segment + "]"
+            }
+        }
+        fileList = append(fileList, link)
     }
 
     tmpl, err := template.New("home").Parse(homeTemplate)
@@ -106,6 +325,16 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
     tmpl.Execute(w, fileList)
 }
 
+func alertsHandler(w http.ResponseWriter, r *http.Request) {
+    tmpl, err := template.New("alerts").Parse(alertsTemplate)
+    if err != nil {
+        http.Error(w, "Failed to parse template", http.StatusInternalServerError)
+        return
+    }
+
+    tmpl.Execute(w, loadAlerts())
+}
+
 func getOddsHandler(w http.ResponseWriter, r *http.Request) {
     filename := r.URL.Query().Get("filename")
     if filename == "" {
@@ -129,8 +358,13 @@ func getLastLineHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    filePath := filepath.Join("/odds_data", filename)
-    file, err := os.Open(filePath)
+    key, _, ok := splitDataFile(filename)
+    if !ok {
+        http.Error(w, fmt.Sprintf("Not an odds file: %s", filename), http.StatusBadRequest)
+        return
+    }
+    filePath := filepath.Join("/odds_data", filepath.Base(filename))
+    file, err := openDataFile(filePath)
     if err != nil {
         http.Error(w, fmt.Sprintf("File not found: %s", filename), http.StatusNotFound)
         return
@@ -143,7 +377,8 @@ func getLastLineHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    lastLine := strings.TrimSpace(string(lines))
+    content := strings.TrimSpace(string(lines))
+    lastLine := content[strings.LastIndex(content, "\n")+1:]
     var data OddsData
     if err := json.Unmarshal([]byte(lastLine), &data); err != nil {
         http.Error(w, "Invalid JSON in the last line", http.StatusBadRequest)
@@ -155,6 +390,11 @@ func getLastLineHandler(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Error processing data", http.StatusInternalServerError)
         return
     }
+    for _, alert := range loadAlerts() {
+        if alert.Key == key {
+            formattedData.Alerts = append(formattedData.Alerts, formatAlert(alert))
+        }
+    }
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(formattedData)
@@ -178,9 +418,10 @@ const homeTemplate = `
 </head>
 <body>
     <h1>Odds Data Files</h1>
+    <p><a href="/alerts">Price alerts</a></p>
     <ul>
     {{range .}}
-        <li><a href="/get_odds?filename={{.}}">{{.}}</a></li>
+        <li><a href="/get_odds?filename={{.FileName}}">{{.Label}}</a></li>
     {{end}}
     </ul>
 </body>
@@ -204,12 +445,14 @@ const oddsTemplate = `
         .outcome { background-color: #f0f0f0; padding: 5px 10px; margin: 5px; border-radius: 5px; }
         .back-link { margin-top: 20px; }
         #error-message { color: red; }
+        .alert { color: #b35900; }
     </style>
 </head>
 <body>
     <h1 id="match-name"></h1>
     <p id="event-info"></p>
     <div id="odds-data"></div>
+    <ul id="alerts"></ul>
     <p id="error-message"></p>
     <div class="back-link">
         <a href="/">Back to file list</a>
@@ -217,7 +460,7 @@ const oddsTemplate = `
 
      <script>
         function updateOdds() {
-            fetch('/get_last_line?filename=' + encodeURIComponent('{{ filename }}'))
+            fetch('/get_last_line?filename=' + encodeURIComponent('{{ . }}'))
                 .then(response => response.json())
                 .then(data => {
                     if (data.error) {
@@ -243,6 +486,15 @@ const oddsTemplate = `
                         }
                     }
                     document.getElementById('odds-data').innerHTML = oddsHtml;
+
+                    const alertsList = document.getElementById('alerts');
+                    alertsList.replaceChildren();
+                    for (const alert of data.alerts || []) {
+                        const item = document.createElement('li');
+                        item.className = 'alert';
+                        item.textContent = alert;
+                        alertsList.appendChild(item);
+                    }
                 })
                 .catch(error => {
                     console.error('Error:', error);
@@ -257,8 +509,47 @@ const oddsTemplate = `
 </html>
 `
 
+const alertsTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta http-equiv="refresh" content="5">
+    <title>Price Alerts</title>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; padding: 20px; max-width: 1000px; margin: 0 auto; }
+        h1 { color: #333; }
+        table { border-collapse: collapse; width: 100%; }
+        td, th { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; }
+        .shortening { color: #008000; }
+        .drifting { color: #cc0000; }
+    </style>
+</head>
+<body>
+    <h1>Price Alerts</h1>
+    <table>
+        <tr><th>Time</th><th>Type</th><th>Match</th><th>Outcomes</th><th>From</th><th>To</th><th>Change</th></tr>
+    {{range .}}
+        <tr class="{{if lt .ChangePercent 0.0}}shortening{{else}}drifting{{end}}">
+            <td>{{.Time}}</td><td>{{.Type}}</td>
+            <td><a href="/get_odds?filename={{.Key}}.jsonl">{{.MatchName}}</a> {{.Operator}}</td>
+            {{if .Moves}}
+            <td>{{range .Moves}}{{.Code}}<br>{{end}}</td><td>{{range .Moves}}{{.From}}<br>{{end}}</td><td>{{range .Moves}}{{.To}}<br>{{end}}</td><td>{{.ChangePercent}}%</td>
+            {{else}}
+            <td>{{range .Codes}}{{.}}<br>{{end}}</td><td>{{.From}}</td><td>{{.To}}</td><td>{{.ChangePercent}}%</td>
+            {{end}}
+        </tr>
+    {{end}}
+    </table>
+    <p><a href="/">Back to file list</a></p>
+</body>
+</html>
+`
+
 func main() {
     http.HandleFunc("/", homeHandler)
+    http.HandleFunc("/alerts", alertsHandler)
     http.HandleFunc("/get_odds", getOddsHandler)
     http.HandleFunc("/get_last_line", getLastLineHandler)
 